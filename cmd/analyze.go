@@ -0,0 +1,273 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "os"
+    "os/signal"
+    "path/filepath"
+    "strings"
+    "syscall"
+    "time"
+
+    gp "github.com/google/gopacket/pcap"
+
+    "github.com/Tushar98644/PacketSentry/internal/ml"
+    "github.com/Tushar98644/PacketSentry/pkg/beacon"
+    "github.com/Tushar98644/PacketSentry/pkg/config"
+    "github.com/Tushar98644/PacketSentry/pkg/dns"
+    "github.com/Tushar98644/PacketSentry/pkg/features"
+    "github.com/Tushar98644/PacketSentry/pkg/flow"
+    "github.com/Tushar98644/PacketSentry/pkg/host"
+    "github.com/Tushar98644/PacketSentry/pkg/ioc"
+    "github.com/Tushar98644/PacketSentry/pkg/output"
+    "github.com/Tushar98644/PacketSentry/pkg/pcap"
+    "github.com/Tushar98644/PacketSentry/pkg/rules"
+    "github.com/Tushar98644/PacketSentry/pkg/scan"
+    "github.com/Tushar98644/PacketSentry/pkg/tlsfp"
+    "github.com/Tushar98644/PacketSentry/pkg/verdict"
+)
+
+// runAnalyze scores flows from capture files (analyze) or a live device
+// (capture) and writes them to every configured output.
+func runAnalyze(cfg *config.Config) int {
+    // On SIGINT or SIGTERM the flows still open are flushed, scored and
+    // written like any other.
+    ctx, stop := signalContext()
+    defer stop()
+
+    // A daemon rotates its outputs on SIGHUP; hup stays nil otherwise.
+    var hup chan os.Signal
+    if cfg.Daemon {
+        hup = make(chan os.Signal, 1)
+        signal.Notify(hup, syscall.SIGHUP)
+        defer signal.Stop(hup)
+    }
+
+    if cfg.LiveCapture && !cfg.Daemon {
+        devs, err := gp.FindAllDevs()
+        if err != nil {
+            log.Fatalf("could not list devices: %v", err)
+        }
+        fmt.Println("Available devices:")
+        for _, d := range devs {
+            fmt.Printf("  - %s: %s\n", d.Name, d.Description)
+        }
+        fmt.Printf("Using device: %s\n\n", cfg.Device)
+    }
+
+    src, err := pcap.Open(cfg)
+    if err != nil {
+        log.Fatalf("could not open packet source: %v", err)
+    }
+    defer src.Close()
+    if files := src.Files(); len(files) > 1 {
+        fmt.Printf("Reading %d capture files merged by timestamp\n", len(files))
+    }
+
+    model, err := ml.Load(cfg.ModelPath, features.Units())
+    if err != nil {
+        log.Fatalf("could not load ML model: %v", err)
+    }
+    fmt.Printf("Loaded %s model from %s (%d features)\n", model.ModelType, cfg.ModelPath, len(model.Features))
+
+    policy := verdict.Policy{Threshold: model.Threshold, Bands: model.SeverityBands}
+    if cfg.Threshold != nil {
+        policy.Threshold = *cfg.Threshold
+    }
+    if cfg.SeverityBands != "" {
+        // Already checked by Validate.
+        policy.Bands, _ = verdict.ParseBands(cfg.SeverityBands)
+    }
+    policy.AlertLabel, _ = verdict.ParseSeverity(cfg.AlertSeverity)
+    fmt.Printf("Malicious above %.3f", policy.Threshold)
+    if len(policy.Bands) > 0 {
+        fmt.Printf(", severity bands %s", policy.Bands)
+    }
+    fmt.Println()
+
+    var blocklist tlsfp.Blocklist
+    if cfg.TLSBlocklist != "" {
+        if blocklist, err = tlsfp.LoadBlocklist(cfg.TLSBlocklist); err != nil {
+            log.Fatalf("could not load TLS blocklist: %v", err)
+        }
+        fmt.Printf("Loaded %d TLS fingerprints from %s\n", len(blocklist), cfg.TLSBlocklist)
+    }
+
+    var ruleSet rules.Set
+    if cfg.RulesDir != "" {
+        if ruleSet, err = rules.Load(cfg.RulesDir); err != nil {
+            log.Fatalf("could not load rules: %v", err)
+        }
+        fmt.Printf("Loaded %d rules from %s\n", len(ruleSet), cfg.RulesDir)
+    }
+
+    var iocs *ioc.Store
+    // reload ticks while there are indicators to reload; it stays nil
+    // otherwise.
+    var reload <-chan time.Time
+    if len(cfg.IOCPaths) > 0 {
+        if iocs, err = ioc.Open(cfg.IOCPaths); err != nil {
+            log.Fatalf("could not load indicators: %v", err)
+        }
+        fmt.Printf("Loaded %d indicators from %s\n", iocs.Set().Len(), strings.Join(cfg.IOCPaths, ", "))
+        if cfg.IOCReload > 0 {
+            ticker := time.NewTicker(cfg.IOCReload)
+            defer ticker.Stop()
+            reload = ticker.C
+        }
+    }
+
+    th := dns.DefaultThresholds()
+    th.DGAScore, th.NXDomainBurst, th.NXDomainWindow, th.TunnelPayload, th.TunnelNameLen =
+        cfg.DGAThreshold, cfg.NXDomainBurst, cfg.NXDomainWindow, cfg.DNSTunnelBytes, cfg.DNSTunnelName
+    dnsDetector := dns.NewDetector(th)
+    beacons := beacon.NewDetector(beacon.Thresholds{
+        Window: cfg.BeaconWindow, MinFlows: cfg.BeaconMinFlows, Score: cfg.BeaconScore,
+    })
+    scans := scan.NewDetector(scan.Thresholds{
+        Window: cfg.ScanWindow, Ports: cfg.ScanPorts, Hosts: cfg.SweepHosts, HalfOpen: cfg.SYNFlood,
+    })
+
+    baseName := runName(cfg, src.Files())
+    nameOnly := strings.TrimSuffix(baseName, filepath.Ext(baseName))
+    csvDir := "data/raw"
+
+    if err := os.MkdirAll(csvDir, os.ModePerm); err != nil {
+        log.Fatalf("could not create directory %s: %v", csvDir, err)
+    }
+
+    csvPath := filepath.Join(csvDir, fmt.Sprintf("%s_features.csv", nameOnly))
+    resultsBase := filepath.Join("data/results", baseName)
+    if err := os.MkdirAll("data/results", os.ModePerm); err != nil {
+        log.Fatalf("could not create directory data/results: %v", err)
+    }
+    hosts := host.NewTracker(cfg.LocalNetworks(), cfg.HostWindow)
+    out, err := openOutputs(cfg, csvPath, resultsBase, hosts)
+    if err != nil {
+        log.Fatalf("could not create outputs: %v", err)
+    }
+
+    packetCh, capture := pcap.ReadPackets(ctx, src, pcap.LimitsFromConfig(cfg))
+    flows := flow.AggregateStream(packetCh, flow.Options{
+        IdleTimeout:   cfg.IdleTimeout,
+        ActiveTimeout: cfg.ActiveTimeout,
+        WallClock:     cfg.LiveCapture,
+    })
+
+    // Flows arrive as soon as they finish, so scoring keeps pace with a
+    // live capture instead of waiting for it to end. On shutdown the
+    // capture stops and the aggregator flushes its active flows, which are
+    // scored here before the channel closes.
+    i, malicious := 0, 0
+    // A daemon whose outputs could not be rotated has nowhere left to
+    // write, so it stops as if interrupted and exits with an error.
+    var rotateErr error
+loop:
+    for {
+        var f *flow.Flow
+        select {
+        case next, ok := <-flows:
+            if !ok {
+                break loop
+            }
+            f = next
+        case <-hup:
+            fmt.Println("Rotating outputs")
+            if rotateErr = out.rotate(time.Now()); rotateErr != nil {
+                log.Printf("could not rotate outputs, stopping: %v", rotateErr)
+                stop()
+                // The flows flushed on the way out have nowhere to go,
+                // but the aggregator blocks until they are taken.
+                dropped := 0
+                for range flows {
+                    dropped++
+                }
+                log.Printf("dropped %d flows still open", dropped)
+                break loop
+            }
+            continue
+        case <-reload:
+            if ok, err := iocs.Reload(); err != nil {
+                log.Printf("could not reload indicators, keeping the old ones: %v", err)
+            } else if ok {
+                fmt.Printf("Reloaded %d indicators\n", iocs.Set().Len())
+            }
+            continue
+        }
+
+        ftr := features.FromFlow(f)
+        hosts.Observe(&ftr)
+        raw, err := ftr.Vector(model.FeatureNames())
+        if err != nil {
+            log.Printf("skipping flow %d, feature error: %v", i+1, err)
+            i++
+            continue
+        }
+        prob, contribs, err := model.PredictExplain(raw, cfg.ExplainTop)
+        if err != nil {
+            log.Printf("skipping flow %d, prediction error: %v", i+1, err)
+            i++
+            continue
+        }
+
+        var alerts []verdict.Alert
+        for _, m := range blocklist.Match(f.TLS) {
+            alerts = append(alerts, verdict.Alert{Source: "tls-blocklist", Detail: m.String(), Severity: verdict.High})
+        }
+        alerts = append(alerts, dnsDetector.Check(f.SrcIP, f.DNS, f.LastSeen)...)
+        alerts = append(alerts, beacons.Check(beacon.NewKey(f.SrcIP, f.DstIP, f.DstPort, f.Protocol), f.FirstSeen, f.ByteCount)...)
+        alerts = append(alerts, ruleSet.Match(ftr)...)
+        alerts = append(alerts, iocs.Set().Check(ftr)...)
+
+        label, severity := policy.Verdict(prob, alerts)
+        if label == verdict.LabelMalicious {
+            malicious++
+        }
+        // A daemon only reports the flows that need attention.
+        if !cfg.Daemon || label == verdict.LabelMalicious {
+            fmt.Printf("Flow %d: %s:%d -> %s:%d (%s, %s) %s %.3f\n", i+1, f.SrcIP, f.SrcPort, f.DstIP, f.DstPort, f.Protocol, f.EndReason, label, prob)
+            for _, a := range alerts {
+                fmt.Printf("  alert %s\n", a)
+            }
+        }
+
+        err = out.write(output.Result{
+            FlowID:        i + 1,
+            Features:      ftr,
+            Probability:   prob,
+            Label:         label,
+            Severity:      severity,
+            Contributions: contribs,
+            Alerts:        alerts,
+        })
+        if err != nil {
+            log.Printf("error writing results for flow %d: %v", i+1, err)
+        }
+
+        for _, e := range scans.Check(ftr) {
+            fmt.Printf("Event %s\n", e)
+            if err := out.writeEvent(e); err != nil {
+                log.Printf("error writing %s event: %v", e.Kind, err)
+            }
+        }
+        i++
+    }
+    if ctx.Err() != nil {
+        fmt.Println("Shutting down")
+    }
+    fmt.Printf("Capture stopped: %s\n", capture)
+
+    if err := out.close(); err != nil {
+        log.Fatalf("%v", err)
+    }
+    fmt.Printf("%d of %d flows labeled malicious\n", malicious, i)
+    if rotateErr != nil {
+        return exitError
+    }
+    if malicious > 0 {
+        return exitMalicious
+    }
+    return exitClean
+}
+
@@ -0,0 +1,42 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "os"
+
+    "github.com/Tushar98644/PacketSentry/pkg/config"
+    "github.com/Tushar98644/PacketSentry/pkg/crypto"
+)
+
+func runEncrypt(cfg *config.Config) int {
+    data, err := os.ReadFile(cfg.InPath)
+    if err != nil {
+        log.Fatalf("encrypt: cannot read %s: %v", cfg.InPath, err)
+    }
+    ct, err := crypto.Encrypt(data, cfg.Key)
+    if err != nil {
+        log.Fatalf("encrypt: failed: %v", err)
+    }
+    if err := os.WriteFile(cfg.OutPath, ct, 0644); err != nil {
+        log.Fatalf("encrypt: write failed: %v", err)
+    }
+    fmt.Printf("Encrypted output written to %s\n", cfg.OutPath)
+    return exitClean
+}
+
+func runDecrypt(cfg *config.Config) int {
+    data, err := os.ReadFile(cfg.InPath)
+    if err != nil {
+        log.Fatalf("decrypt: cannot read %s: %v", cfg.InPath, err)
+    }
+    plain, err := crypto.Decrypt(data, cfg.Key)
+    if err != nil {
+        log.Fatalf("decrypt: failed: %v", err)
+    }
+    if err := os.WriteFile(cfg.OutPath, plain, 0644); err != nil {
+        log.Fatalf("decrypt: write failed: %v", err)
+    }
+    fmt.Printf("Decrypted output written to %s\n", cfg.OutPath)
+    return exitClean
+}
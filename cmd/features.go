@@ -0,0 +1,75 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "os"
+    "path/filepath"
+    "text/tabwriter"
+
+    "github.com/Tushar98644/PacketSentry/pkg/config"
+    "github.com/Tushar98644/PacketSentry/pkg/features"
+    "github.com/Tushar98644/PacketSentry/pkg/flow"
+    "github.com/Tushar98644/PacketSentry/pkg/host"
+    "github.com/Tushar98644/PacketSentry/pkg/output"
+    "github.com/Tushar98644/PacketSentry/pkg/pcap"
+)
+
+// runFeatures writes the features of every flow in the capture files to a
+// CSV without scoring them.
+func runFeatures(cfg *config.Config) int {
+    if cfg.ListFeatures {
+        w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+        for _, c := range features.Columns() {
+            fmt.Fprintf(w, "%s\t%s\n", c.Name, c.Unit)
+        }
+        w.Flush()
+        return exitClean
+    }
+
+    ctx, stop := signalContext()
+    defer stop()
+
+    src, err := pcap.Open(cfg)
+    if err != nil {
+        log.Fatalf("could not open packet source: %v", err)
+    }
+    defer src.Close()
+
+    path := cfg.FeaturesOut
+    if path == "" {
+        path = filepath.Join("data/raw", runName(cfg, src.Files())+"_features.csv")
+    }
+    if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+        log.Fatalf("could not create directory %s: %v", filepath.Dir(path), err)
+    }
+    fw, err := output.NewFlowFeaturesWriter(path)
+    if err != nil {
+        log.Fatalf("error writing CSV: %v", err)
+    }
+
+    packetCh, capture := pcap.ReadPackets(ctx, src, pcap.LimitsFromConfig(cfg))
+    flows := flow.AggregateStream(packetCh, flow.Options{
+        IdleTimeout:   cfg.IdleTimeout,
+        ActiveTimeout: cfg.ActiveTimeout,
+    })
+
+    // Host features are filled in as analyze does, so that models can be
+    // trained on them.
+    hosts := host.NewTracker(cfg.LocalNetworks(), cfg.HostWindow)
+    n := 0
+    for f := range flows {
+        ftr := features.FromFlow(f)
+        hosts.Observe(&ftr)
+        if err := fw.Write(ftr); err != nil {
+            log.Fatalf("error writing CSV: %v", err)
+        }
+        n++
+    }
+    if err := fw.Close(); err != nil {
+        log.Fatalf("error writing CSV: %v", err)
+    }
+    fmt.Printf("Capture stopped: %s\n", capture)
+    fmt.Printf("Wrote features of %d flows to %s\n", n, path)
+    return exitClean
+}
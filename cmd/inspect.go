@@ -0,0 +1,72 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "os"
+    "sort"
+    "text/tabwriter"
+
+    "github.com/Tushar98644/PacketSentry/internal/ml"
+    "github.com/Tushar98644/PacketSentry/pkg/config"
+    "github.com/Tushar98644/PacketSentry/pkg/features"
+)
+
+// runInspectModel loads the model the way analyze would, so a bundle that
+// prints here is one PacketSentry accepts, and describes it.
+func runInspectModel(cfg *config.Config) int {
+    model, err := ml.Load(cfg.ModelPath, features.Units())
+    if err != nil {
+        log.Fatalf("could not load ML model: %v", err)
+    }
+
+    w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+    fmt.Fprintf(w, "Model:\t%s\n", cfg.ModelPath)
+    fmt.Fprintf(w, "Type:\t%s\n", model.ModelType)
+    if model.Version == 0 {
+        fmt.Fprintf(w, "Format:\tlegacy parameter directory\n")
+    } else {
+        fmt.Fprintf(w, "Bundle version:\t%d\n", model.Version)
+        fmt.Fprintf(w, "Checksum:\t%s (verified)\n", model.Checksum)
+    }
+    fmt.Fprintf(w, "Threshold:\t%.3f\n", model.Threshold)
+    if len(model.SeverityBands) > 0 {
+        fmt.Fprintf(w, "Severity bands:\t%s\n", model.SeverityBands)
+    }
+
+    t := model.Training
+    for _, kv := range [][2]string{
+        {"Trained at", t.TrainedAt},
+        {"Source", t.Source},
+        {"Notes", t.Notes},
+    } {
+        if kv[1] != "" {
+            fmt.Fprintf(w, "%s:\t%s\n", kv[0], kv[1])
+        }
+    }
+    if t.Samples > 0 {
+        fmt.Fprintf(w, "Samples:\t%d\n", t.Samples)
+    }
+    metrics := make([]string, 0, len(t.Metrics))
+    for name := range t.Metrics {
+        metrics = append(metrics, name)
+    }
+    sort.Strings(metrics)
+    for _, name := range metrics {
+        fmt.Fprintf(w, "Metric %s:\t%.4f\n", name, t.Metrics[name])
+    }
+    w.Flush()
+
+    fmt.Printf("\nFeatures (%d):\n", len(model.Features))
+    w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+    units := features.Units()
+    for i, f := range model.Features {
+        fmt.Fprintf(w, "  %d\t%s\t%s", i+1, f.Name, units[f.Name])
+        if f.Std != 0 {
+            fmt.Fprintf(w, "\tmean %.4g\tstd %.4g", f.Mean, f.Std)
+        }
+        fmt.Fprintln(w)
+    }
+    w.Flush()
+    return exitClean
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github.com/Tushar98644/PacketSentry/pkg/config"
+    "github.com/Tushar98644/PacketSentry/pkg/crypto"
+    "github.com/Tushar98644/PacketSentry/pkg/host"
+    "github.com/Tushar98644/PacketSentry/pkg/output"
+    "github.com/Tushar98644/PacketSentry/pkg/scan"
+)
+
+// outputs are the files a run writes: the features CSV, every result
+// format, the host-level events and the host profiles. A daemon rotates
+// them on SIGHUP.
+type outputs struct {
+    cfg         *config.Config
+    featPath    string
+    resultsBase string
+    // hosts are profiled for the whole run; each finish writes what they
+    // look like by then.
+    hosts       *host.Tracker
+
+    feat    *output.FlowFeaturesWriter
+    results output.Writer
+    events  *output.EventsWriter
+    // flows and nevents count what was written since the outputs were
+    // opened.
+    flows   int
+    nevents int
+    // finished is set once the writers are closed, so that outputs a
+    // failed rotation left behind are not finished twice.
+    finished bool
+}
+
+func openOutputs(cfg *config.Config, featPath, resultsBase string, hosts *host.Tracker) (*outputs, error) {
+    o := &outputs{cfg: cfg, featPath: featPath, resultsBase: resultsBase, hosts: hosts}
+    return o, o.open()
+}
+
+func (o *outputs) open() error {
+    feat, err := output.NewFlowFeaturesWriter(o.featPath)
+    if err != nil {
+        return err
+    }
+
+    var writers []output.Writer
+    for _, format := range o.cfg.Formats {
+        w, err := output.NewWriter(format, o.resultsBase, o.cfg.ExplainTop)
+        if err != nil {
+            feat.Close()
+            output.MultiWriter(writers...).Close()
+            return fmt.Errorf("could not create %s output: %w", format, err)
+        }
+        writers = append(writers, w)
+    }
+
+    events, err := output.NewEventsWriter(output.EventsPath(o.resultsBase))
+    if err != nil {
+        feat.Close()
+        output.MultiWriter(writers...).Close()
+        return err
+    }
+
+    o.feat, o.results, o.events = feat, output.MultiWriter(writers...), events
+    o.flows, o.nevents, o.finished = 0, 0, false
+    return nil
+}
+
+func (o *outputs) write(r output.Result) error {
+    if err := o.feat.Write(r.Features); err != nil {
+        return err
+    }
+    o.flows++
+    return o.results.Write(r)
+}
+
+func (o *outputs) writeEvent(e scan.Event) error {
+    o.nevents++
+    return o.events.Write(e)
+}
+
+// close finishes every output where it was written, unless a failed
+// rotation already did.
+func (o *outputs) close() error {
+    if o.finished {
+        return nil
+    }
+    return o.finish(func(path string) string { return path })
+}
+
+// rotate finishes the current outputs, moves them aside under names stamped
+// with t and starts new ones at the original paths. The stamp has
+// one-second resolution, so a rotation within the same second as an
+// earlier one adds a counter rather than overwrite its files.
+func (o *outputs) rotate(t time.Time) error {
+    stamp := t.Format("20060102T150405")
+    for n := 1; o.rotated(stamp); n++ {
+        stamp = fmt.Sprintf("%s-%d", t.Format("20060102T150405"), n)
+    }
+    err := o.finish(func(path string) string {
+        return stamped(path, stamp)
+    })
+    if err != nil {
+        return err
+    }
+    return o.open()
+}
+
+// stamped returns path with stamp added before its extension.
+func stamped(path, stamp string) string {
+    ext := filepath.Ext(path)
+    return strings.TrimSuffix(path, ext) + "-" + stamp + ext
+}
+
+// rotated reports whether any output was already rotated under stamp,
+// encrypted or not.
+func (o *outputs) rotated(stamp string) bool {
+    paths := []string{o.featPath, output.EventsPath(o.resultsBase), output.HostsPath(o.resultsBase)}
+    for _, format := range o.cfg.Formats {
+        paths = append(paths, output.Path(format, o.resultsBase))
+    }
+    for _, p := range paths {
+        for _, to := range []string{stamped(p, stamp), stamped(p, stamp) + ".enc"} {
+            if _, err := os.Stat(to); err == nil {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// finish closes the writers, moves each file to rename(path) and, with an
+// encryption key, replaces the result data files by their encrypted form.
+// The chart is a view for the analyst and is never encrypted.
+func (o *outputs) finish(rename func(string) string) error {
+    o.finished = true
+    if err := errors.Join(o.feat.Close(), o.results.Close(), o.events.Close()); err != nil {
+        return fmt.Errorf("could not finish outputs: %w", err)
+    }
+
+    featPath, err := move(o.featPath, rename)
+    if err != nil {
+        return err
+    }
+    fmt.Printf("Features written to %s\n", featPath)
+
+    for _, format := range o.cfg.Formats {
+        path, err := move(output.Path(format, o.resultsBase), rename)
+        if err != nil {
+            return err
+        }
+        fmt.Printf("Wrote %d flows to %s\n", o.flows, path)
+
+        if o.cfg.EncryptKey == "" || format == output.FormatChart {
+            continue
+        }
+        if err := encryptFile(path, o.cfg.EncryptKey); err != nil {
+            return err
+        }
+    }
+
+    path, err := move(output.EventsPath(o.resultsBase), rename)
+    if err != nil {
+        return err
+    }
+    fmt.Printf("Wrote %d events to %s\n", o.nevents, path)
+    if o.cfg.EncryptKey != "" {
+        if err := encryptFile(path, o.cfg.EncryptKey); err != nil {
+            return err
+        }
+    }
+
+    path = rename(output.HostsPath(o.resultsBase))
+    if err := output.WriteHostsCSV(path, o.hosts.Profiles()); err != nil {
+        return err
+    }
+    fmt.Printf("Wrote %d host profiles to %s\n", o.hosts.Len(), path)
+    if o.cfg.EncryptKey != "" {
+        return encryptFile(path, o.cfg.EncryptKey)
+    }
+    return nil
+}
+
+func move(path string, rename func(string) string) (string, error) {
+    to := rename(path)
+    if to == path {
+        return path, nil
+    }
+    if err := os.Rename(path, to); err != nil {
+        return "", fmt.Errorf("rotate %s: %w", path, err)
+    }
+    return to, nil
+}
+
+// encryptFile replaces path by path.enc, encrypted with key.
+func encryptFile(path, key string) error {
+    pt, err := os.ReadFile(path)
+    if err != nil {
+        return fmt.Errorf("encrypt: cannot read %s: %w", path, err)
+    }
+    ct, err := crypto.Encrypt(pt, key)
+    if err != nil {
+        return fmt.Errorf("encrypt: failed: %w", err)
+    }
+    encPath := path + ".enc"
+    if err := os.WriteFile(encPath, ct, 0644); err != nil {
+        return fmt.Errorf("encrypt: write failed: %w", err)
+    }
+    os.Remove(path)
+    fmt.Printf("Encrypted output written to %s\n", encPath)
+    return nil
+}
@@ -4,10 +4,16 @@ go 1.23.0
 
 toolchain go1.23.9
 
-require github.com/google/gopacket v1.1.19
+require (
+	github.com/go-echarts/go-echarts/v2 v2.5.4
+	github.com/google/gopacket v1.1.19
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/crypto v0.38.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
-	github.com/go-echarts/go-echarts/v2 v2.5.4 // indirect
-	golang.org/x/crypto v0.38.0 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	golang.org/x/net v0.21.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
 )
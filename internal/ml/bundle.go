@@ -0,0 +1,282 @@
+package ml
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "math"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "github.com/Tushar98644/PacketSentry/pkg/verdict"
+)
+
+// Identifiers of the model bundle file format.
+const (
+    BundleFormat  = "packetsentry-model"
+    BundleVersion = 1
+)
+
+// DefaultThreshold is the malicious cut-off used when a model does not
+// carry its own.
+const DefaultThreshold = 0.5
+
+// FeatureSpec is one model input: its name and unit as produced by the
+// features package and, for linear models, its scaler parameters.
+type FeatureSpec struct {
+    Name string  `json:"name"`
+    Unit string  `json:"unit,omitempty"`
+    Mean float64 `json:"mean,omitempty"`
+    Std  float64 `json:"std,omitempty"`
+}
+
+// TrainingInfo records where and how a bundle's model was trained.
+type TrainingInfo struct {
+    TrainedAt string             `json:"trained_at,omitempty"`
+    Source    string             `json:"source,omitempty"`
+    Samples   int                `json:"samples,omitempty"`
+    Metrics   map[string]float64 `json:"metrics,omitempty"`
+    Notes     string             `json:"notes,omitempty"`
+}
+
+// bundleFile is the on-disk envelope. Checksum is the SHA-256 of the
+// payload exactly as it appears in the file.
+type bundleFile struct {
+    Format   string          `json:"format"`
+    Version  int             `json:"version"`
+    Checksum string          `json:"checksum"`
+    Payload  json.RawMessage `json:"payload"`
+}
+
+type bundlePayload struct {
+    Metadata
+    Features  []FeatureSpec   `json:"features"`
+    Threshold float64         `json:"threshold"`
+    // SeverityBands maps severity names to the probability each starts at.
+    SeverityBands map[string]float64 `json:"severity_bands,omitempty"`
+    Training  TrainingInfo    `json:"training"`
+    // Model is {"weights": [...], "intercept": x} for logistic regression,
+    // or the tree dump in Format for ensembles.
+    Model     json.RawMessage `json:"model"`
+}
+
+// Bundle is a loaded model together with everything needed to feed it and
+// read its output: feature names in input order, units, threshold and
+// severity bands.
+type Bundle struct {
+    Classifier
+    Version       int
+    Checksum      string
+    ModelType     string
+    Features      []FeatureSpec
+    Threshold     float64
+    SeverityBands verdict.Bands
+    Training      TrainingInfo
+}
+
+// FeatureNames returns the model's input features in vector order.
+func (b *Bundle) FeatureNames() []string {
+    names := make([]string, len(b.Features))
+    for i, f := range b.Features {
+        names[i] = f.Name
+    }
+    return names
+}
+
+// Contribution is how much one feature pushed a prediction towards
+// malicious (positive) or benign (negative).
+type Contribution struct {
+    Feature string
+    Value   float64
+}
+
+// PredictExplain scores x and returns up to top features ranked by the
+// size of their contribution. Models that cannot explain themselves return
+// no contributions.
+func (b *Bundle) PredictExplain(x []float64, top int) (float64, []Contribution, error) {
+    exp, ok := b.Classifier.(Explainer)
+    if !ok || top <= 0 {
+        prob, err := b.Predict(x)
+        return prob, nil, err
+    }
+
+    prob, contribs, err := exp.PredictExplain(x)
+    if err != nil {
+        return 0, nil, err
+    }
+
+    ranked := make([]Contribution, len(contribs))
+    for i, v := range contribs {
+        ranked[i] = Contribution{Feature: b.Features[i].Name, Value: v}
+    }
+    sort.SliceStable(ranked, func(i, j int) bool {
+        return math.Abs(ranked[i].Value) > math.Abs(ranked[j].Value)
+    })
+    if len(ranked) > top {
+        ranked = ranked[:top]
+    }
+    return prob, ranked, nil
+}
+
+// Load loads a model from path, which is either a bundle file or a legacy
+// parameter directory such as ml/parameters. units maps every feature the
+// caller can supply to its unit; models that need anything else, or expect
+// a different unit, are refused.
+func Load(path string, units map[string]string) (*Bundle, error) {
+    info, err := os.Stat(path)
+    if err != nil {
+        return nil, err
+    }
+    if info.IsDir() {
+        return loadDir(path, units)
+    }
+    return LoadBundle(path, units)
+}
+
+// LoadBundle reads and verifies a bundle file written by
+// scripts/ml/generate_model.py.
+func LoadBundle(path string, units map[string]string) (*Bundle, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+
+    var file bundleFile
+    if err := json.Unmarshal(data, &file); err != nil {
+        return nil, fmt.Errorf("parse %s: %w", path, err)
+    }
+    if file.Format != BundleFormat {
+        return nil, fmt.Errorf("%s: not a model bundle (format %q)", path, file.Format)
+    }
+    if file.Version != BundleVersion {
+        return nil, fmt.Errorf("%s: unsupported bundle version %d, want %d", path, file.Version, BundleVersion)
+    }
+
+    sum := sha256.Sum256(file.Payload)
+    want := "sha256:" + hex.EncodeToString(sum[:])
+    if !strings.EqualFold(file.Checksum, want) {
+        return nil, fmt.Errorf("%s: checksum mismatch: file says %s, payload is %s", path, file.Checksum, want)
+    }
+
+    var p bundlePayload
+    if err := json.Unmarshal(file.Payload, &p); err != nil {
+        return nil, fmt.Errorf("parse %s payload: %w", path, err)
+    }
+
+    b := &Bundle{
+        Version:   file.Version,
+        Checksum:  want,
+        ModelType: p.ModelType,
+        Features:  p.Features,
+        Threshold: p.Threshold,
+        Training:  p.Training,
+    }
+    if err := checkFeatures(b.Features, units); err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+
+    switch p.ModelType {
+    case TypeLogisticRegression:
+        b.Classifier, err = newLinearModel(p.Model, b.Features)
+    case TypeRandomForest, TypeGradientBoosting:
+        b.Classifier, err = newTreeEnsemble(p.Metadata, p.Model, b.FeatureNames())
+    default:
+        err = fmt.Errorf("unknown model_type %q", p.ModelType)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+
+    if b.Threshold == 0 {
+        b.Threshold = DefaultThreshold
+    }
+    if b.Threshold < 0 || b.Threshold > 1 {
+        return nil, fmt.Errorf("%s: threshold %v outside [0, 1]", path, b.Threshold)
+    }
+    if len(p.SeverityBands) > 0 {
+        if b.SeverityBands, err = verdict.NewBands(p.SeverityBands); err != nil {
+            return nil, fmt.Errorf("%s: %w", path, err)
+        }
+    }
+    return b, nil
+}
+
+// newLinearModel builds a logistic regression from a bundle's model
+// section, taking the scaler parameters from its feature list.
+func newLinearModel(raw json.RawMessage, feats []FeatureSpec) (*Model, error) {
+    var lin struct {
+        Weights   []float64 `json:"weights"`
+        Intercept float64   `json:"intercept"`
+    }
+    if err := json.Unmarshal(raw, &lin); err != nil {
+        return nil, fmt.Errorf("parse model: %w", err)
+    }
+    if len(lin.Weights) != len(feats) {
+        return nil, fmt.Errorf("%d weights for %d features", len(lin.Weights), len(feats))
+    }
+
+    m := &Model{Weights: lin.Weights, Intercept: lin.Intercept}
+    for _, f := range feats {
+        m.Means = append(m.Means, f.Mean)
+        m.Stds = append(m.Stds, f.Std)
+    }
+    return m, nil
+}
+
+// loadDir wraps a legacy parameter directory in a Bundle, taking the
+// feature names from its features.txt.
+func loadDir(paramsDir string, units map[string]string) (*Bundle, error) {
+    names, err := readLines(filepath.Join(paramsDir, "features.txt"))
+    if err != nil {
+        return nil, err
+    }
+
+    b := &Bundle{Threshold: DefaultThreshold}
+    for _, n := range names {
+        b.Features = append(b.Features, FeatureSpec{Name: n})
+    }
+    if err := checkFeatures(b.Features, units); err != nil {
+        return nil, fmt.Errorf("%s: %w", paramsDir, err)
+    }
+
+    meta, err := readMetadata(paramsDir)
+    if err != nil {
+        return nil, err
+    }
+    b.ModelType = meta.ModelType
+
+    b.Classifier, err = LoadClassifier(paramsDir)
+    if err != nil {
+        return nil, err
+    }
+    if n := b.Classifier.NumFeatures(); n != len(names) {
+        return nil, fmt.Errorf("%s: features.txt lists %d features, model has %d", paramsDir, len(names), n)
+    }
+    return b, nil
+}
+
+// checkFeatures refuses models whose features the caller cannot supply,
+// whose units disagree, or that list a feature twice.
+func checkFeatures(feats []FeatureSpec, units map[string]string) error {
+    if len(feats) == 0 {
+        return fmt.Errorf("model lists no features")
+    }
+    seen := make(map[string]bool, len(feats))
+    for _, f := range feats {
+        unit, ok := units[f.Name]
+        if !ok {
+            return fmt.Errorf("unknown feature %q", f.Name)
+        }
+        if f.Unit != "" && f.Unit != unit {
+            return fmt.Errorf("feature %q has unit %q, want %q", f.Name, f.Unit, unit)
+        }
+        if seen[f.Name] {
+            return fmt.Errorf("feature %q listed twice", f.Name)
+        }
+        seen[f.Name] = true
+    }
+    return nil
+}
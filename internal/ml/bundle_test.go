@@ -0,0 +1,199 @@
+package ml
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "math"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// testUnits are the features a caller of the tests can supply.
+var testUnits = map[string]string{"a": "ms", "b": "packets", "c": "bytes"}
+
+// linearPayload is a bundle payload holding a logistic regression over a
+// and b.
+const linearPayload = `{
+    "model_type": "logistic_regression",
+    "features": [
+        {"name": "a", "unit": "ms", "mean": 10, "std": 2},
+        {"name": "b", "unit": "packets", "mean": 0, "std": 1}
+    ],
+    "threshold": 0.7,
+    "training": {"source": "test", "samples": 2},
+    "model": {"weights": [1, -0.5], "intercept": 0.25}
+}`
+
+// payloadChecksum returns the bundle checksum of payload.
+func payloadChecksum(payload string) string {
+    sum := sha256.Sum256([]byte(payload))
+    return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// writeBundle writes a bundle file with the given envelope fields to a
+// temporary directory and returns its path. An empty checksum is replaced
+// by the payload's.
+func writeBundle(t *testing.T, format string, version int, checksum, payload string) string {
+    t.Helper()
+    if checksum == "" {
+        checksum = payloadChecksum(payload)
+    }
+    path := filepath.Join(t.TempDir(), "model.json")
+    data := fmt.Sprintf(`{"format": %q, "version": %d, "checksum": %q, "payload": %s}`, format, version, checksum, payload)
+    if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+// writeDir writes a legacy parameter directory holding files and returns
+// its path.
+func writeDir(t *testing.T, files map[string]string) string {
+    t.Helper()
+    dir := t.TempDir()
+    for name, content := range files {
+        if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return dir
+}
+
+func TestLoadBundle(t *testing.T) {
+    path := writeBundle(t, BundleFormat, BundleVersion, "", linearPayload)
+    b, err := Load(path, testUnits)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if b.ModelType != TypeLogisticRegression || b.Threshold != 0.7 || b.Training.Samples != 2 {
+        t.Errorf("bundle = %+v", b)
+    }
+    if got := strings.Join(b.FeatureNames(), ","); got != "a,b" {
+        t.Errorf("features %s, want a,b", got)
+    }
+    prob, err := b.Predict([]float64{14, 1})
+    if err != nil {
+        t.Fatal(err)
+    }
+    // (14-10)/2*1 + (1-0)/1*-0.5 + 0.25
+    if want := Sigmoid(1.75); math.Abs(prob-want) > 1e-12 {
+        t.Errorf("Predict = %v, want %v", prob, want)
+    }
+
+    // The checksum covers the payload as written, whitespace included.
+    compact := writeBundle(t, BundleFormat, BundleVersion, "", strings.Join(strings.Fields(linearPayload), ""))
+    if _, err := Load(compact, testUnits); err != nil {
+        t.Errorf("compact payload: %v", err)
+    }
+
+    noThreshold := strings.Replace(linearPayload, `"threshold": 0.7,`, "", 1)
+    b, err = Load(writeBundle(t, BundleFormat, BundleVersion, "", noThreshold), testUnits)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if b.Threshold != DefaultThreshold {
+        t.Errorf("threshold %v, want the default %v", b.Threshold, DefaultThreshold)
+    }
+}
+
+func TestLoadBundleErrors(t *testing.T) {
+    features := func(list string) string {
+        return strings.Replace(linearPayload, `[
+        {"name": "a", "unit": "ms", "mean": 10, "std": 2},
+        {"name": "b", "unit": "packets", "mean": 0, "std": 1}
+    ]`, list, 1)
+    }
+    tests := []struct {
+        name     string
+        format   string
+        version  int
+        checksum string
+        payload  string
+        err      string
+    }{
+        {"checksum mismatch", BundleFormat, BundleVersion, "sha256:" + strings.Repeat("0", 64), linearPayload, "checksum mismatch"},
+        {"payload edited", BundleFormat, BundleVersion, payloadChecksum(linearPayload),
+            strings.Replace(linearPayload, "0.25", "2.5", 1), "checksum mismatch"},
+        {"other format", "onnx", BundleVersion, "", linearPayload, `not a model bundle (format "onnx")`},
+        {"newer version", BundleFormat, BundleVersion + 1, "", linearPayload, "unsupported bundle version"},
+        {"unknown feature", BundleFormat, BundleVersion, "",
+            features(`[{"name": "a", "unit": "ms"}, {"name": "d"}]`), `unknown feature "d"`},
+        {"duplicate feature", BundleFormat, BundleVersion, "",
+            features(`[{"name": "a", "unit": "ms"}, {"name": "a", "unit": "ms"}]`), `feature "a" listed twice`},
+        {"unit mismatch", BundleFormat, BundleVersion, "",
+            features(`[{"name": "a", "unit": "s"}, {"name": "b"}]`), `feature "a" has unit "s", want "ms"`},
+        {"no features", BundleFormat, BundleVersion, "", features(`[]`), "no features"},
+        {"weights and features disagree", BundleFormat, BundleVersion, "",
+            features(`[{"name": "a"}, {"name": "b"}, {"name": "c"}]`), "2 weights for 3 features"},
+        {"unknown model type", BundleFormat, BundleVersion, "",
+            strings.Replace(linearPayload, "logistic_regression", "svm", 1), `unknown model_type "svm"`},
+        {"threshold out of range", BundleFormat, BundleVersion, "",
+            strings.Replace(linearPayload, "0.7", "1.5", 1), "threshold 1.5 outside [0, 1]"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            path := writeBundle(t, tt.format, tt.version, tt.checksum, tt.payload)
+            _, err := Load(path, testUnits)
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Errorf("Load = %v, want an error containing %q", err, tt.err)
+            }
+        })
+    }
+}
+
+func TestLoadDir(t *testing.T) {
+    linear := map[string]string{
+        "features.txt":  "a\nb\n",
+        "weights.txt":   "1\n-0.5\n",
+        "intercept.txt": "0.25\n",
+        "mean.txt":      "10\n0\n",
+        "std.txt":       "2\n1\n",
+    }
+    b, err := Load(writeDir(t, linear), testUnits)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if b.ModelType != TypeLogisticRegression || b.Threshold != DefaultThreshold {
+        t.Errorf("bundle = %+v", b)
+    }
+    if got := strings.Join(b.FeatureNames(), ","); got != "a,b" {
+        t.Errorf("features %s, want a,b", got)
+    }
+    prob, err := b.Predict([]float64{14, 1})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if want := Sigmoid(1.75); math.Abs(prob-want) > 1e-12 {
+        t.Errorf("Predict = %v, want %v", prob, want)
+    }
+
+    tests := []struct {
+        name  string
+        files map[string]string
+        err   string
+    }{
+        {"unknown feature", map[string]string{"features.txt": "a\nd\n"}, `unknown feature "d"`},
+        {"duplicate feature", map[string]string{"features.txt": "a\na\n"}, `feature "a" listed twice`},
+        {"feature count", map[string]string{"features.txt": "a\nb\nc\n"}, "features.txt lists 3 features, model has 2"},
+        {"empty features.txt", map[string]string{"features.txt": ""}, "no features"},
+        {"unknown model type", map[string]string{"metadata.json": `{"model_type": "svm"}`}, `unknown model_type "svm"`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            files := make(map[string]string)
+            for name, content := range linear {
+                files[name] = content
+            }
+            for name, content := range tt.files {
+                files[name] = content
+            }
+            _, err := Load(writeDir(t, files), testUnits)
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Errorf("Load = %v, want an error containing %q", err, tt.err)
+            }
+        })
+    }
+}
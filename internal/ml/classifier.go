@@ -0,0 +1,91 @@
+package ml
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+)
+
+// Classifier scores one flow's raw feature vector and returns the
+// probability that the flow is malicious.
+type Classifier interface {
+    Predict(features []float64) (float64, error)
+    // NumFeatures is the length of the feature vector Predict expects.
+    NumFeatures() int
+}
+
+// Explainer is implemented by classifiers that can attribute a prediction
+// to their input features. contribs has one entry per feature, in the units
+// of the model's decision function.
+type Explainer interface {
+    PredictExplain(features []float64) (prob float64, contribs []float64, err error)
+}
+
+// Model types recorded in a parameter directory's metadata.json.
+const (
+    TypeLogisticRegression = "logistic_regression"
+    TypeRandomForest       = "random_forest"
+    TypeGradientBoosting   = "gradient_boosting"
+)
+
+// Tree formats accepted for ensemble models.
+const (
+    FormatSklearn = "sklearn"
+    FormatXGBoost = "xgboost"
+)
+
+// Metadata describes the model stored in a parameter directory.
+type Metadata struct {
+    ModelType string `json:"model_type"`
+    // Format is the tree dump format of ensemble models.
+    Format    string `json:"format,omitempty"`
+    // TreesFile is the ensemble's tree dump, relative to the directory.
+    TreesFile string `json:"trees_file,omitempty"`
+    // BaseMargin is the log-odds a boosted ensemble starts from before its
+    // trees are added: sklearn's init estimator, or for XGBoost the logit
+    // of base_score, which is a probability.
+    BaseMargin float64 `json:"base_margin,omitempty"`
+    // LearningRate scales each boosted tree's output. XGBoost dumps already
+    // include it in the leaf values, so it is only needed for sklearn.
+    LearningRate float64 `json:"learning_rate,omitempty"`
+}
+
+// LoadClassifier loads the model in paramsDir, choosing the model type from
+// its metadata.json. Directories without metadata hold the original
+// logistic-regression text files.
+func LoadClassifier(paramsDir string) (Classifier, error) {
+    meta, err := readMetadata(paramsDir)
+    if err != nil {
+        return nil, err
+    }
+
+    switch meta.ModelType {
+    case TypeLogisticRegression:
+        return LoadModel(paramsDir)
+    case TypeRandomForest, TypeGradientBoosting:
+        return LoadTreeEnsemble(paramsDir, meta)
+    default:
+        return nil, fmt.Errorf("%s: unknown model_type %q", paramsDir, meta.ModelType)
+    }
+}
+
+// readMetadata reads paramsDir/metadata.json, defaulting to logistic
+// regression when the file does not exist.
+func readMetadata(paramsDir string) (Metadata, error) {
+    meta := Metadata{ModelType: TypeLogisticRegression}
+
+    path := filepath.Join(paramsDir, "metadata.json")
+    data, err := os.ReadFile(path)
+    if errors.Is(err, os.ErrNotExist) {
+        return meta, nil
+    }
+    if err != nil {
+        return meta, err
+    }
+    if err := json.Unmarshal(data, &meta); err != nil {
+        return meta, fmt.Errorf("parse %s: %w", path, err)
+    }
+    return meta, nil
+}
@@ -9,6 +9,7 @@ import (
     "strings"
 )
 
+// Model is a logistic-regression classifier over standardized features.
 type Model struct {
     Weights   []float64 
     Intercept float64
@@ -87,6 +88,10 @@ func Sigmoid(z float64) float64 {
     return 1.0 / (1.0 + math.Exp(-z))
 }
 
+func (m *Model) NumFeatures() int {
+    return len(m.Weights)
+}
+
 func (m *Model) Predict(features []float64) (float64, error) {
     if len(features) != len(m.Weights) {
         return 0, fmt.Errorf("feature length %d, want %d", len(features), len(m.Weights))
@@ -103,3 +108,25 @@ func (m *Model) Predict(features []float64) (float64, error) {
 
     return Sigmoid(z), nil
 }
+
+// PredictExplain is Predict that also returns each feature's contribution
+// to the log-odds, Weights[i] times the scaled feature value. Together
+// with Intercept they sum to the logit of the returned probability.
+func (m *Model) PredictExplain(features []float64) (float64, []float64, error) {
+    if len(features) != len(m.Weights) {
+        return 0, nil, fmt.Errorf("feature length %d, want %d", len(features), len(m.Weights))
+    }
+
+    contribs := make([]float64, len(features))
+    z := m.Intercept
+    for i, x := range features {
+        if m.Stds[i] == 0 {
+            continue
+        }
+        xScaled := (x - m.Means[i]) / m.Stds[i]
+        contribs[i] = m.Weights[i] * xScaled
+        z += contribs[i]
+    }
+
+    return Sigmoid(z), contribs, nil
+}
@@ -0,0 +1,323 @@
+package ml
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "math"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// Tree is a binary decision tree in flat array form, the way scikit-learn
+// stores it. Node 0 is the root and node i is a leaf when Left[i] < 0.
+type Tree struct {
+    Left      []int
+    Right     []int
+    Feature   []int
+    Threshold []float64
+    // Missing is the child taken when the feature value is NaN.
+    Missing   []int
+    // Value is the leaf output: the malicious-class probability for a
+    // random forest, or the raw margin for a boosted tree.
+    Value     []float64
+}
+
+// TreeEnsemble is a random forest or a gradient-boosted set of trees.
+type TreeEnsemble struct {
+    Trees        []Tree
+    // Boosted sums the tree margins and applies a sigmoid; otherwise the
+    // per-tree probabilities are averaged, as in a random forest.
+    Boosted      bool
+    BaseMargin   float64
+    LearningRate float64
+    // StrictLess splits on x < threshold, as XGBoost does, instead of
+    // scikit-learn's x <= threshold.
+    StrictLess   bool
+
+    nFeatures    int
+}
+
+// LoadTreeEnsemble loads the tree dump named in meta from paramsDir.
+// features.txt in the same directory fixes the feature vector length and
+// resolves XGBoost splits that refer to features by name.
+func LoadTreeEnsemble(paramsDir string, meta Metadata) (*TreeEnsemble, error) {
+    names, err := readLines(filepath.Join(paramsDir, "features.txt"))
+    if err != nil {
+        return nil, err
+    }
+
+    treesFile := meta.TreesFile
+    if treesFile == "" {
+        treesFile = "trees.json"
+    }
+    path := filepath.Join(paramsDir, treesFile)
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+
+    e, err := newTreeEnsemble(meta, data, names)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    return e, nil
+}
+
+// newTreeEnsemble builds an ensemble from a tree dump in meta.Format over
+// the named features.
+func newTreeEnsemble(meta Metadata, dump []byte, names []string) (*TreeEnsemble, error) {
+    e := &TreeEnsemble{
+        Boosted:      meta.ModelType == TypeGradientBoosting,
+        BaseMargin:   meta.BaseMargin,
+        LearningRate: meta.LearningRate,
+        nFeatures:    len(names),
+    }
+    if e.LearningRate == 0 {
+        e.LearningRate = 1
+    }
+
+    var err error
+    switch meta.Format {
+    case FormatSklearn:
+        e.Trees, err = parseSklearnTrees(dump)
+    case FormatXGBoost:
+        e.StrictLess = true
+        e.Trees, err = parseXGBoostTrees(dump, names)
+    default:
+        return nil, fmt.Errorf("unknown tree format %q, want %q or %q", meta.Format, FormatSklearn, FormatXGBoost)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("parse trees: %w", err)
+    }
+    if len(e.Trees) == 0 {
+        return nil, fmt.Errorf("no trees")
+    }
+
+    for i := range e.Trees {
+        if err := e.Trees[i].validate(e.nFeatures); err != nil {
+            return nil, fmt.Errorf("tree %d: %w", i, err)
+        }
+    }
+    return e, nil
+}
+
+func (e *TreeEnsemble) NumFeatures() int {
+    return e.nFeatures
+}
+
+func (e *TreeEnsemble) Predict(features []float64) (float64, error) {
+    if len(features) != e.nFeatures {
+        return 0, fmt.Errorf("feature length %d, want %d", len(features), e.nFeatures)
+    }
+
+    var sum float64
+    for i := range e.Trees {
+        sum += e.Trees[i].leaf(features, e.StrictLess)
+    }
+
+    if e.Boosted {
+        return Sigmoid(e.BaseMargin + e.LearningRate*sum), nil
+    }
+    return sum / float64(len(e.Trees)), nil
+}
+
+// leaf walks the tree for x and returns the value of the leaf it lands in.
+func (t *Tree) leaf(x []float64, strictLess bool) float64 {
+    i := 0
+    for t.Left[i] >= 0 {
+        v, th := x[t.Feature[i]], t.Threshold[i]
+        switch {
+        case math.IsNaN(v):
+            i = t.Missing[i]
+        case v < th || (!strictLess && v == th):
+            i = t.Left[i]
+        default:
+            i = t.Right[i]
+        }
+    }
+    return t.Value[i]
+}
+
+// validate checks that every index in t is in range and that children
+// always come after their parent, so leaf cannot loop.
+func (t *Tree) validate(nFeatures int) error {
+    n := len(t.Left)
+    if n == 0 {
+        return fmt.Errorf("empty tree")
+    }
+    for _, arr := range [][]int{t.Right, t.Feature, t.Missing} {
+        if len(arr) != n {
+            return fmt.Errorf("node arrays have different lengths")
+        }
+    }
+    if len(t.Threshold) != n || len(t.Value) != n {
+        return fmt.Errorf("node arrays have different lengths")
+    }
+
+    for i := 0; i < n; i++ {
+        if t.Left[i] < 0 {
+            continue
+        }
+        for _, c := range []int{t.Left[i], t.Right[i], t.Missing[i]} {
+            if c <= i || c >= n {
+                return fmt.Errorf("node %d has invalid child %d", i, c)
+            }
+        }
+        if t.Feature[i] < 0 || t.Feature[i] >= nFeatures {
+            return fmt.Errorf("node %d splits on feature %d, model has %d", i, t.Feature[i], nFeatures)
+        }
+    }
+    return nil
+}
+
+// sklearnTree is one estimator's tree_ arrays as written by
+// scripts/ml/generate_model.py. value holds one number per node.
+type sklearnTree struct {
+    ChildrenLeft  []int     `json:"children_left"`
+    ChildrenRight []int     `json:"children_right"`
+    Feature       []int     `json:"feature"`
+    Threshold     []float64 `json:"threshold"`
+    Value         []float64 `json:"value"`
+}
+
+func parseSklearnTrees(data []byte) ([]Tree, error) {
+    var dump struct {
+        Trees []sklearnTree `json:"trees"`
+    }
+    if err := json.Unmarshal(data, &dump); err != nil {
+        return nil, err
+    }
+
+    trees := make([]Tree, len(dump.Trees))
+    for i, st := range dump.Trees {
+        // scikit-learn has no missing-value branch in these arrays; NaN
+        // goes right, just as a failed x <= threshold comparison would.
+        trees[i] = Tree{
+            Left:      st.ChildrenLeft,
+            Right:     st.ChildrenRight,
+            Feature:   st.Feature,
+            Threshold: st.Threshold,
+            Missing:   st.ChildrenRight,
+            Value:     st.Value,
+        }
+    }
+    return trees, nil
+}
+
+// xgbNode is a node of XGBoost's get_dump(dump_format="json") output.
+type xgbNode struct {
+    NodeID         int       `json:"nodeid"`
+    Split          string    `json:"split"`
+    SplitCondition float64   `json:"split_condition"`
+    Yes            int       `json:"yes"`
+    No             int       `json:"no"`
+    Missing        int       `json:"missing"`
+    Leaf           *float64  `json:"leaf"`
+    Children       []xgbNode `json:"children"`
+}
+
+// parseXGBoostTrees reads a JSON array of XGBoost tree dumps and flattens
+// each nested tree into arrays in depth-first order, so that children come
+// after their parent. Node IDs are remapped to those positions: pruned
+// trees leave gaps in the IDs. Splits name features either as f<index> or
+// by the names in features.txt.
+func parseXGBoostTrees(data []byte, names []string) ([]Tree, error) {
+    var roots []xgbNode
+    if err := json.Unmarshal(data, &roots); err != nil {
+        return nil, err
+    }
+
+    index := make(map[string]int, len(names))
+    for i, n := range names {
+        index[n] = i
+    }
+
+    trees := make([]Tree, len(roots))
+    for i := range roots {
+        var nodes []*xgbNode
+        var collect func(n *xgbNode)
+        collect = func(n *xgbNode) {
+            nodes = append(nodes, n)
+            for c := range n.Children {
+                collect(&n.Children[c])
+            }
+        }
+        collect(&roots[i])
+
+        pos := make(map[int]int, len(nodes))
+        for p, n := range nodes {
+            if _, dup := pos[n.NodeID]; dup {
+                return nil, fmt.Errorf("tree %d: duplicate node id %d", i, n.NodeID)
+            }
+            pos[n.NodeID] = p
+        }
+        child := func(id int) (int, error) {
+            p, ok := pos[id]
+            if !ok {
+                return 0, fmt.Errorf("tree %d: unknown child node id %d", i, id)
+            }
+            return p, nil
+        }
+
+        size := len(nodes)
+        t := Tree{
+            Left:      make([]int, size),
+            Right:     make([]int, size),
+            Feature:   make([]int, size),
+            Threshold: make([]float64, size),
+            Missing:   make([]int, size),
+            Value:     make([]float64, size),
+        }
+        for p, n := range nodes {
+            if n.Leaf != nil {
+                t.Left[p], t.Right[p], t.Missing[p] = -1, -1, -1
+                t.Value[p] = *n.Leaf
+                continue
+            }
+
+            feat, ok := index[n.Split]
+            if !ok {
+                idx, err := strconv.Atoi(strings.TrimPrefix(n.Split, "f"))
+                if err != nil || !strings.HasPrefix(n.Split, "f") {
+                    return nil, fmt.Errorf("tree %d: unknown split feature %q", i, n.Split)
+                }
+                feat = idx
+            }
+            var err error
+            if t.Left[p], err = child(n.Yes); err != nil {
+                return nil, err
+            }
+            if t.Right[p], err = child(n.No); err != nil {
+                return nil, err
+            }
+            if t.Missing[p], err = child(n.Missing); err != nil {
+                return nil, err
+            }
+            t.Feature[p] = feat
+            t.Threshold[p] = n.SplitCondition
+        }
+        trees[i] = t
+    }
+    return trees, nil
+}
+
+// readLines returns the non-empty, trimmed lines of path.
+func readLines(path string) ([]string, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, fmt.Errorf("open %s: %w", path, err)
+    }
+    defer f.Close()
+
+    var lines []string
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        if line := strings.TrimSpace(scanner.Text()); line != "" {
+            lines = append(lines, line)
+        }
+    }
+    return lines, scanner.Err()
+}
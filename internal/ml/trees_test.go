@@ -0,0 +1,93 @@
+package ml
+
+import (
+    "math"
+    "strings"
+    "testing"
+)
+
+// xgbPruned is an XGBoost dump of a pruned tree: nodes 3 and 4 were pruned
+// away, leaving a gap in the IDs.
+const xgbPruned = `[{"nodeid": 0, "split": "a", "split_condition": 10, "yes": 1, "no": 2, "missing": 2, "children": [
+    {"nodeid": 1, "leaf": -1},
+    {"nodeid": 2, "split": "f1", "split_condition": 5, "yes": 5, "no": 6, "missing": 5, "children": [
+        {"nodeid": 5, "leaf": 0.5},
+        {"nodeid": 6, "leaf": 2}
+    ]}
+]}]`
+
+const sklearnStump = `{"trees": [{
+    "children_left": [1, -1, -1], "children_right": [2, -1, -1],
+    "feature": [0, -2, -2], "threshold": [10, -2, -2], "value": [0, 0.2, 0.9]
+}]}`
+
+func TestTreeEnsemblePredict(t *testing.T) {
+    names := []string{"a", "b"}
+    xgb := Metadata{ModelType: TypeGradientBoosting, Format: FormatXGBoost}
+    forest := Metadata{ModelType: TypeRandomForest, Format: FormatSklearn}
+
+    tests := []struct {
+        name string
+        meta Metadata
+        dump string
+        x    []float64
+        want float64
+    }{
+        {"xgboost left leaf", xgb, xgbPruned, []float64{9, 0}, Sigmoid(-1)},
+        // XGBoost splits on x < threshold, so the threshold goes right.
+        {"xgboost at threshold", xgb, xgbPruned, []float64{10, 5}, Sigmoid(2)},
+        {"xgboost past gap", xgb, xgbPruned, []float64{11, 4}, Sigmoid(0.5)},
+        {"xgboost missing", xgb, xgbPruned, []float64{11, math.NaN()}, Sigmoid(0.5)},
+        {"sklearn at threshold", forest, sklearnStump, []float64{10, 0}, 0.2},
+        {"sklearn right", forest, sklearnStump, []float64{11, 0}, 0.9},
+        {"sklearn missing goes right", forest, sklearnStump, []float64{math.NaN(), 0}, 0.9},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            e, err := newTreeEnsemble(tt.meta, []byte(tt.dump), names)
+            if err != nil {
+                t.Fatal(err)
+            }
+            got, err := e.Predict(tt.x)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if math.Abs(got-tt.want) > 1e-12 {
+                t.Errorf("Predict(%v) = %v, want %v", tt.x, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestTreeEnsembleInvalid(t *testing.T) {
+    names := []string{"a", "b"}
+    xgb := Metadata{ModelType: TypeGradientBoosting, Format: FormatXGBoost}
+    forest := Metadata{ModelType: TypeRandomForest, Format: FormatSklearn}
+
+    tests := []struct {
+        name string
+        meta Metadata
+        dump string
+        err  string
+    }{
+        {"unknown child", xgb, `[{"nodeid": 0, "split": "a", "split_condition": 1, "yes": 1, "no": 7, "missing": 1, "children": [
+            {"nodeid": 1, "leaf": 0}, {"nodeid": 2, "leaf": 1}]}]`, "unknown child node id 7"},
+        {"duplicate id", xgb, `[{"nodeid": 0, "split": "a", "split_condition": 1, "yes": 1, "no": 1, "missing": 1, "children": [
+            {"nodeid": 1, "leaf": 0}, {"nodeid": 1, "leaf": 1}]}]`, "duplicate node id 1"},
+        {"unknown feature", xgb, `[{"nodeid": 0, "split": "c", "split_condition": 1, "yes": 1, "no": 2, "missing": 1, "children": [
+            {"nodeid": 1, "leaf": 0}, {"nodeid": 2, "leaf": 1}]}]`, `unknown split feature "c"`},
+        {"feature out of range", xgb, `[{"nodeid": 0, "split": "f2", "split_condition": 1, "yes": 1, "no": 2, "missing": 1, "children": [
+            {"nodeid": 1, "leaf": 0}, {"nodeid": 2, "leaf": 1}]}]`, "splits on feature 2"},
+        {"loop", forest, `{"trees": [{"children_left": [1, 0], "children_right": [1, 0],
+            "feature": [0, 0], "threshold": [1, 1], "value": [0, 0]}]}`, "invalid child"},
+        {"no trees", forest, `{"trees": []}`, "no trees"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := newTreeEnsemble(tt.meta, []byte(tt.dump), names)
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Errorf("error = %v, want one containing %q", err, tt.err)
+            }
+        })
+    }
+}
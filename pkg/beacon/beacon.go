@@ -0,0 +1,269 @@
+// Package beacon finds periodic check-ins: series of flows from one host to
+// the same destination that start at regular intervals and carry similar
+// amounts of data, as command-and-control implants produce.
+package beacon
+
+import (
+    "fmt"
+    "math"
+    "net"
+    "sort"
+    "time"
+
+    "github.com/Tushar98644/PacketSentry/pkg/verdict"
+)
+
+// Source is the alert source of beacons.
+const Source = "beacon"
+
+const (
+    // maxEvents bounds the flows remembered per destination; the oldest
+    // go first.
+    maxEvents = 256
+    // pruneEvery is how many checks pass between sweeps of destinations
+    // that fell out of the window.
+    pruneEvery = 1024
+    // binsPerPeriod is the resolution of the series the autocorrelation
+    // is computed on, and maxBins its length.
+    binsPerPeriod = 8
+    maxBins       = 4096
+)
+
+// Thresholds tune the Detector. A zero Score disables it.
+type Thresholds struct {
+    // Window is how far back flows to a destination are considered.
+    Window   time.Duration
+    // MinFlows is the number of flows in the window a beacon needs.
+    MinFlows int
+    // Score is the confidence (0 to 1) at which a series is reported.
+    Score    float64
+}
+
+// DefaultThresholds catch a check-in every few minutes within an hour.
+func DefaultThresholds() Thresholds {
+    return Thresholds{Window: time.Hour, MinFlows: 6, Score: 0.8}
+}
+
+// Key groups the flows of one conversation between two hosts.
+type Key struct {
+    Src, Dst string
+    DstPort  uint16
+    Protocol string
+}
+
+// NewKey builds the Key of a flow.
+func NewKey(src, dst net.IP, dstPort uint16, protocol string) Key {
+    return Key{Src: src.String(), Dst: dst.String(), DstPort: dstPort, Protocol: protocol}
+}
+
+func (k Key) String() string {
+    return fmt.Sprintf("%s -> %s/%s", k.Src, net.JoinHostPort(k.Dst, fmt.Sprint(k.DstPort)), k.Protocol)
+}
+
+// Detector keeps the recent flows of every Key and scores how periodic
+// they are.
+type Detector struct {
+    th     Thresholds
+    series map[Key][]event
+    checks int
+}
+
+type event struct {
+    start time.Time
+    bytes int
+}
+
+func NewDetector(th Thresholds) *Detector {
+    return &Detector{th: th, series: make(map[Key][]event)}
+}
+
+// Score describes how periodic a series of flows is. Each part runs from 0
+// to 1; Confidence combines them.
+type Score struct {
+    Flows    int
+    // Period is the median interval between flow starts.
+    Period   time.Duration
+    // Jitter is the median absolute deviation of the intervals over
+    // their median.
+    Jitter   float64
+    // Regularity is 1 - Jitter, and Autocorrelation how strongly the
+    // timeline of flow starts repeats after one Period.
+    Regularity      float64
+    Autocorrelation float64
+    // SizeConsistency is 1 - the same ratio for the flows' byte counts.
+    SizeConsistency float64
+    // Coverage is the share of the flows one per Period over the span of
+    // the series would make that were seen. Bursts of flows have a short
+    // Period but long gaps, and so a low Coverage.
+    Coverage        float64
+    // Confidence weighs regularity, autocorrelation and size consistency,
+    // and is scaled down when fewer than half the expected flows were
+    // seen.
+    Confidence      float64
+}
+
+// Check records a flow of key that started at start and carried bytes,
+// and returns a beacon alert if the flows of key in the window are
+// periodic enough. Flows must be checked roughly in time order.
+func (d *Detector) Check(key Key, start time.Time, bytes int) []verdict.Alert {
+    if d.th.Score <= 0 {
+        return nil
+    }
+    events := d.add(key, event{start: start, bytes: bytes})
+    if d.checks++; d.checks%pruneEvery == 0 {
+        d.prune(start)
+    }
+    if len(events) < max(d.th.MinFlows, 3) {
+        return nil
+    }
+
+    s := score(events)
+    if s.Confidence < d.th.Score {
+        return nil
+    }
+    severity := verdict.Medium
+    if s.Confidence >= 0.95 {
+        severity = verdict.High
+    }
+    return []verdict.Alert{{
+        Source: Source,
+        Detail: fmt.Sprintf("%d flows %s every %s (jitter %.1f%%, confidence %.2f)",
+            s.Flows, key, s.Period.Round(time.Millisecond), 100*s.Jitter, s.Confidence),
+        Severity: severity,
+    }}
+}
+
+// add inserts e into the series of key, in start order, drops the flows
+// that fell out of the window and returns the series.
+func (d *Detector) add(key Key, e event) []event {
+    events := d.series[key]
+    i := sort.Search(len(events), func(i int) bool { return events[i].start.After(e.start) })
+    events = append(events, event{})
+    copy(events[i+1:], events[i:])
+    events[i] = e
+
+    newest := events[len(events)-1].start
+    drop := 0
+    for drop < len(events) && newest.Sub(events[drop].start) > d.th.Window {
+        drop++
+    }
+    drop = max(drop, len(events)-maxEvents)
+    events = events[drop:]
+    d.series[key] = events
+    return events
+}
+
+// prune forgets destinations with no flow in the window before now.
+func (d *Detector) prune(now time.Time) {
+    for k, events := range d.series {
+        if now.Sub(events[len(events)-1].start) > d.th.Window {
+            delete(d.series, k)
+        }
+    }
+}
+
+// score rates a series of at least three flows sorted by start.
+func score(events []event) Score {
+    intervals := make([]float64, len(events)-1)
+    for i := range intervals {
+        intervals[i] = float64(events[i+1].start.Sub(events[i].start))
+    }
+    sizes := make([]float64, len(events))
+    for i, e := range events {
+        sizes[i] = float64(e.bytes)
+    }
+
+    period, jitter := medianDeviation(intervals)
+    _, sizeSpread := medianDeviation(sizes)
+    s := Score{
+        Flows:           len(events),
+        Period:          time.Duration(period),
+        Jitter:          jitter,
+        Regularity:      1 - min(jitter, 1),
+        SizeConsistency: 1 - min(sizeSpread, 1),
+    }
+    if period > 0 {
+        s.Autocorrelation = autocorrelation(events, period)
+        span := float64(events[len(events)-1].start.Sub(events[0].start))
+        s.Coverage = min(float64(len(intervals))*period/span, 1)
+    }
+    s.Confidence = (0.4*s.Regularity + 0.35*s.Autocorrelation + 0.25*s.SizeConsistency) * min(2*s.Coverage, 1)
+    return s
+}
+
+// medianDeviation returns the median of xs and the median absolute
+// deviation from it relative to the median, which a few missed or extra
+// check-ins barely move.
+func medianDeviation(xs []float64) (median, spread float64) {
+    median = medianOf(xs)
+    if median == 0 {
+        return 0, 1
+    }
+    dev := make([]float64, len(xs))
+    for i, x := range xs {
+        dev[i] = math.Abs(x - median)
+    }
+    return median, medianOf(dev) / median
+}
+
+func medianOf(xs []float64) float64 {
+    s := append([]float64(nil), xs...)
+    sort.Float64s(s)
+    n := len(s)
+    if n%2 == 1 {
+        return s[n/2]
+    }
+    return (s[n/2-1] + s[n/2]) / 2
+}
+
+// autocorrelation bins the flow starts into a timeline with binsPerPeriod
+// bins per period, smooths it over three bins to allow for jitter, and
+// returns its correlation with itself one period later. A series that
+// repeats scores close to 1, one with starts at random near 0.
+func autocorrelation(events []event, period float64) float64 {
+    span := float64(events[len(events)-1].start.Sub(events[0].start))
+    width := period / binsPerPeriod
+    if span/width >= maxBins {
+        width = span / (maxBins - 1)
+    }
+    bins := make([]float64, int(span/width)+1)
+    for _, e := range events {
+        bins[int(float64(e.start.Sub(events[0].start))/width)]++
+    }
+
+    // Periods shorter than two bins happen only when the timeline was
+    // coarsened to fit maxBins, and cannot be told apart from noise.
+    lag := int(math.Round(period / width))
+    if lag < 2 || lag >= len(bins) {
+        return 0
+    }
+    smooth := make([]float64, len(bins))
+    for t := range bins {
+        for u := max(t-1, 0); u <= min(t+1, len(bins)-1); u++ {
+            smooth[t] += bins[u]
+        }
+    }
+    return min(max(correlation(smooth[:len(bins)-lag], smooth[lag:]), 0), 1)
+}
+
+// correlation returns the Pearson correlation of x and y, zero if either
+// is constant.
+func correlation(x, y []float64) float64 {
+    var mx, my float64
+    for i := range x {
+        mx += x[i]
+        my += y[i]
+    }
+    mx /= float64(len(x))
+    my /= float64(len(y))
+    var cov, vx, vy float64
+    for i := range x {
+        cov += (x[i] - mx) * (y[i] - my)
+        vx += (x[i] - mx) * (x[i] - mx)
+        vy += (y[i] - my) * (y[i] - my)
+    }
+    if vx == 0 || vy == 0 {
+        return 0
+    }
+    return cov / math.Sqrt(vx*vy)
+}
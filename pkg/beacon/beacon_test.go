@@ -0,0 +1,155 @@
+package beacon
+
+import (
+    "fmt"
+    "math/rand"
+    "net"
+    "testing"
+    "time"
+
+    "github.com/Tushar98644/PacketSentry/pkg/verdict"
+)
+
+var base = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var key = NewKey(net.ParseIP("10.0.0.5"), net.ParseIP("203.0.113.7"), 443, "TCP")
+
+// flow is one flow of a series: when it started, relative to base, and
+// how many bytes it carried.
+type flow struct {
+    at    time.Duration
+    bytes int
+}
+
+func periodic(n int, period time.Duration, jitter float64, sizes func(i int) int, rng *rand.Rand) []flow {
+    fs := make([]flow, n)
+    for i := range fs {
+        off := time.Duration((rng.Float64()*2 - 1) * jitter * float64(period))
+        fs[i] = flow{time.Duration(i)*period + off, sizes(i)}
+    }
+    return fs
+}
+
+func same(int) int { return 1200 }
+
+func TestDetector(t *testing.T) {
+    rng := rand.New(rand.NewSource(1))
+    random := make([]flow, 40)
+    for i, at := 0, time.Duration(0); i < len(random); i++ {
+        at += time.Duration(rng.ExpFloat64() * float64(time.Minute))
+        random[i] = flow{at, 500 + rng.Intn(5000)}
+    }
+    var bursts []flow
+    for b := 0; b < 4; b++ {
+        for i := 0; i < 5; i++ {
+            bursts = append(bursts, flow{time.Duration(b)*15*time.Minute + time.Duration(i)*time.Second, 1200})
+        }
+    }
+    missed := periodic(30, time.Minute, 0, same, rng)
+    missed = append(missed[:10], missed[13:]...)
+
+    tests := []struct {
+        name     string
+        th       Thresholds
+        flows    []flow
+        severity verdict.Severity
+    }{
+        {"exact", DefaultThresholds(), periodic(20, time.Minute, 0, same, rng), verdict.High},
+        {"jittered", DefaultThresholds(), periodic(30, 5*time.Minute, 0.05, same, rng), verdict.High},
+        {"heavily jittered", DefaultThresholds(), periodic(30, time.Minute, 0.25, same, rng), verdict.Medium},
+        {"missed check-ins", DefaultThresholds(), missed, verdict.High},
+        // Timing alone makes a beacon, but not a certain one.
+        {"sizes vary", DefaultThresholds(), periodic(20, time.Minute, 0, func(i int) int { return 100 + rng.Intn(100000) }, rng), verdict.Medium},
+        {"sizes and timing vary", DefaultThresholds(), periodic(20, time.Minute, 0.25, func(i int) int { return 100 + rng.Intn(100000) }, rng), verdict.None},
+        {"random", DefaultThresholds(), random, verdict.None},
+        {"bursts", DefaultThresholds(), bursts, verdict.None},
+        {"too few flows", DefaultThresholds(), periodic(5, time.Minute, 0, same, rng), verdict.None},
+        {"slower than window", DefaultThresholds(), periodic(10, 2*time.Hour, 0, same, rng), verdict.None},
+        {"disabled", Thresholds{Window: time.Hour, MinFlows: 6}, periodic(20, time.Minute, 0, same, rng), verdict.None},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            d := NewDetector(tt.th)
+            var last []verdict.Alert
+            for _, f := range tt.flows {
+                last = d.Check(key, base.Add(f.at), f.bytes)
+            }
+            var got verdict.Severity
+            if len(last) > 0 {
+                got = last[0].Severity
+            }
+            if got != tt.severity {
+                events := d.series[key]
+                t.Errorf("severity %s, want %s (score %+v)", got, tt.severity, score(events))
+            }
+        })
+    }
+}
+
+func TestScore(t *testing.T) {
+    rng := rand.New(rand.NewSource(2))
+    toEvents := func(fs []flow) []event {
+        es := make([]event, len(fs))
+        for i, f := range fs {
+            es[i] = event{base.Add(f.at), f.bytes}
+        }
+        return es
+    }
+
+    s := score(toEvents(periodic(20, time.Minute, 0, same, rng)))
+    if s.Period != time.Minute || s.Jitter != 0 || s.Regularity != 1 || s.SizeConsistency != 1 || s.Coverage != 1 {
+        t.Errorf("exact beacon scored %+v", s)
+    }
+    if s.Autocorrelation < 0.9 || s.Confidence < 0.95 {
+        t.Errorf("exact beacon: autocorrelation %.2f, confidence %.2f", s.Autocorrelation, s.Confidence)
+    }
+
+    // Jitter grows with the spread of the intervals, and confidence falls.
+    prev := 2.0
+    for _, j := range []float64{0, 0.1, 0.3, 0.6} {
+        s := score(toEvents(periodic(60, time.Minute, j, same, rng)))
+        if s.Confidence >= prev {
+            t.Errorf("jitter %.1f: confidence %.2f, not below %.2f", j, s.Confidence, prev)
+        }
+        prev = s.Confidence
+    }
+}
+
+func TestOrderAndBounds(t *testing.T) {
+    d := NewDetector(DefaultThresholds())
+    // Flows finish, and are checked, out of start order: every second
+    // one before the one that started ahead of it.
+    var alerts []verdict.Alert
+    for i := 0; i < 20; i++ {
+        at := time.Duration(i^1) * time.Minute
+        alerts = d.Check(key, base.Add(at), 1200)
+    }
+    if len(alerts) != 1 {
+        t.Errorf("alerts = %v, want a beacon", alerts)
+    }
+    events := d.series[key]
+    for i := 1; i < len(events); i++ {
+        if events[i].start.Before(events[i-1].start) {
+            t.Fatalf("series out of order at %d", i)
+        }
+    }
+
+    for i := 0; i < 2*maxEvents; i++ {
+        d.Check(key, base.Add(time.Hour+time.Duration(i)*time.Second), 1200)
+    }
+    if n := len(d.series[key]); n != maxEvents {
+        t.Errorf("%d flows kept, want %d", n, maxEvents)
+    }
+}
+
+func TestPrune(t *testing.T) {
+    d := NewDetector(DefaultThresholds())
+    for i := 0; i < pruneEvery; i++ {
+        k := NewKey(net.ParseIP("10.0.0.5"), net.ParseIP(fmt.Sprintf("203.0.%d.%d", i/256, i%256)), 443, "TCP")
+        d.Check(k, base.Add(time.Duration(i)*10*time.Second), 1200)
+    }
+    // The sweep keeps the destinations seen within the hour before it.
+    if n := len(d.series); n > 361 {
+        t.Errorf("%d destinations kept, want at most 361", n)
+    }
+}
@@ -0,0 +1,359 @@
+package config
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+
+    "github.com/Tushar98644/PacketSentry/pkg/output"
+)
+
+// Subcommands of the packetsentry binary.
+const (
+    CmdAnalyze      = "analyze"
+    CmdCapture      = "capture"
+    CmdFeatures     = "features"
+    CmdInspectModel = "inspect-model"
+    CmdEncrypt      = "encrypt"
+    CmdDecrypt      = "decrypt"
+)
+
+// Command describes a subcommand for its help text and binds its flags.
+type Command struct {
+    Name    string
+    // Args is the positional part of the usage line.
+    Args    string
+    Summary string
+    // NoFile commands take no configuration file or environment
+    // variables; all their settings come from flags.
+    NoFile  bool
+    flags   func(cfg *Config, fs *flag.FlagSet)
+}
+
+var commands = []Command{
+    {
+        Name:    CmdAnalyze,
+        Args:    "[flags] [capture files, globs or directories...]",
+        Summary: "Score the flows in capture files and write the results.",
+        flags: func(cfg *Config, fs *flag.FlagSet) {
+            cfg.fileFlags(fs)
+            cfg.limitFlags(fs)
+            cfg.flowFlags(fs)
+            cfg.hostFlags(fs)
+            cfg.scoringFlags(fs)
+        },
+    },
+    {
+        Name:    CmdCapture,
+        Args:    "[flags]",
+        Summary: "Capture from a network device and score flows as they finish.",
+        flags: func(cfg *Config, fs *flag.FlagSet) {
+            cfg.deviceFlags(fs)
+            cfg.limitFlags(fs)
+            cfg.flowFlags(fs)
+            cfg.hostFlags(fs)
+            cfg.scoringFlags(fs)
+        },
+    },
+    {
+        Name:    CmdFeatures,
+        Args:    "[flags] [capture files, globs or directories...]",
+        Summary: "Extract flow features from capture files into a CSV, e.g. for training.",
+        flags: func(cfg *Config, fs *flag.FlagSet) {
+            cfg.fileFlags(fs)
+            cfg.limitFlags(fs)
+            cfg.flowFlags(fs)
+            cfg.hostFlags(fs)
+            fs.StringVar(&cfg.FeaturesOut, "o", cfg.FeaturesOut,
+                "CSV file to write (default data/raw/<name>_features.csv)")
+            fs.BoolVar(&cfg.ListFeatures, "list", cfg.ListFeatures,
+                "list the feature columns and their units instead of reading packets")
+        },
+    },
+    {
+        Name:    CmdInspectModel,
+        Args:    "[flags]",
+        Summary: "Verify a model bundle and print its features, threshold and training metadata.",
+        flags: func(cfg *Config, fs *flag.FlagSet) {
+            cfg.modelFlags(fs)
+        },
+    },
+    {
+        Name:    CmdEncrypt,
+        Args:    "-key passphrase -in file -out file.enc",
+        Summary: "Encrypt a file with a passphrase (AES-GCM).",
+        NoFile:  true,
+        flags: func(cfg *Config, fs *flag.FlagSet) {
+            cfg.cryptFlags(fs, "plaintext file to encrypt", "encrypted file to write")
+        },
+    },
+    {
+        Name:    CmdDecrypt,
+        Args:    "-key passphrase -in file.enc -out file",
+        Summary: "Decrypt a results file written with -encrypt-key.",
+        NoFile:  true,
+        flags: func(cfg *Config, fs *flag.FlagSet) {
+            cfg.cryptFlags(fs, "encrypted .enc file to read", "plaintext file to write")
+        },
+    },
+}
+
+// Commands returns every subcommand in help order.
+func Commands() []Command {
+    return commands
+}
+
+// Parse sets cfg up for the named subcommand and parses its arguments.
+// Settings come from the configuration file, then PACKETSENTRY_*
+// environment variables, then flags, each overriding the one before.
+// It returns flag.ErrHelp when help was asked for. Errors are printed to
+// stderr, those in the arguments along with the command's usage, as the
+// flag package does.
+func (cfg *Config) Parse(name string, args []string) error {
+    var cmd *Command
+    for i := range commands {
+        if commands[i].Name == name {
+            cmd = &commands[i]
+        }
+    }
+    if cmd == nil {
+        return report(fmt.Errorf("unknown command %q", name))
+    }
+    cfg.Command = name
+    cfg.LiveCapture = name == CmdCapture
+
+    if !cmd.NoFile {
+        if path := configPath(args); path != "" {
+            if err := cfg.LoadFile(path); err != nil {
+                return report(err)
+            }
+        }
+        // Environment variables go through a flag set of their own, so
+        // their values are parsed like flags and become the defaults of
+        // the flag set that parses args.
+        if err := applyEnv(cfg.flagSet(cmd)); err != nil {
+            return report(err)
+        }
+    }
+    fs := cfg.flagSet(cmd)
+
+    // Flags may follow the positional arguments, as in
+    // "analyze a.pcap -format ndjson", so parsing resumes after each one.
+    var rest []string
+    for {
+        if err := fs.Parse(args); err != nil {
+            return err
+        }
+        if fs.NArg() == 0 {
+            break
+        }
+        rest = append(rest, fs.Arg(0))
+        args = fs.Args()[1:]
+    }
+
+    switch name {
+    case CmdAnalyze, CmdFeatures:
+        cfg.Inputs = append(cfg.Inputs, rest...)
+    default:
+        if len(rest) > 0 {
+            // Reported like a flag error: message, then usage.
+            err := fmt.Errorf("%s takes no arguments, got %q", name, strings.Join(rest, " "))
+            fmt.Fprintln(fs.Output(), err)
+            fs.Usage()
+            return err
+        }
+    }
+    return nil
+}
+
+func report(err error) error {
+    fmt.Fprintf(os.Stderr, "packetsentry: %v\n", err)
+    return err
+}
+
+// flagSet returns a flag set for cmd bound to cfg, with cfg's current
+// values as defaults.
+func (cfg *Config) flagSet(cmd *Command) *flag.FlagSet {
+    fs := flag.NewFlagSet("packetsentry "+cmd.Name, flag.ContinueOnError)
+    fs.Usage = func() {
+        out := fs.Output()
+        fmt.Fprintf(out, "Usage: packetsentry %s %s\n\n%s\n\nFlags:\n", cmd.Name, cmd.Args, cmd.Summary)
+        fs.PrintDefaults()
+    }
+    if !cmd.NoFile {
+        fs.String("config", "",
+            "YAML configuration file (also "+EnvPrefix+"CONFIG); every flag can also be set as "+EnvPrefix+"<FLAG>")
+    }
+    cmd.flags(cfg, fs)
+    return fs
+}
+
+// fileFlags choose the capture files to read.
+func (cfg *Config) fileFlags(fs *flag.FlagSet) {
+    fs.Var(&listFlag{list: &cfg.Inputs}, "input",
+        "capture files, globs or directories to read, comma-separated or repeated; "+
+            "pcap/pcapng and .gz/.zst are detected automatically (arguments are added too)")
+
+    fs.StringVar(&cfg.FileName, "fname", cfg.FileName,
+        "without inputs, read packets/<fname>.pcap; also the base name of outputs when several files are read")
+}
+
+// deviceFlags choose the live capture device.
+func (cfg *Config) deviceFlags(fs *flag.FlagSet) {
+    fs.StringVar(&cfg.Device, "device", cfg.Device,
+        "network device to capture packets from")
+
+    fs.Func("snaplen", fmt.Sprintf("`bytes` captured per packet (default %d)", cfg.SnapshotLen), func(v string) error {
+        n, err := strconv.ParseInt(v, 10, 32)
+        if err != nil {
+            return err
+        }
+        cfg.SnapshotLen = int32(n)
+        return nil
+    })
+
+    fs.BoolVar(&cfg.Promiscuous, "promisc", cfg.Promiscuous,
+        "put the device into promiscuous mode")
+
+    fs.DurationVar(&cfg.Timeout, "timeout", cfg.Timeout,
+        "how long the device buffers packets before handing them over")
+
+    fs.StringVar(&cfg.FileName, "fname", cfg.FileName,
+        "base name of the output files")
+
+    fs.BoolVar(&cfg.Daemon, "daemon", cfg.Daemon,
+        "run as a long-lived capture: only malicious flows are printed and SIGHUP rotates the outputs")
+}
+
+// limitFlags bound how much is read and which packets are kept.
+func (cfg *Config) limitFlags(fs *flag.FlagSet) {
+    fs.IntVar(&cfg.MaxPackets, "max", cfg.MaxPackets,
+        "maximum number of packets to process (0 for no limit)")
+
+    fs.Int64Var(&cfg.MaxBytes, "max-bytes", cfg.MaxBytes,
+        "stop after reading this many bytes of packet data (0 for no limit)")
+
+    fs.DurationVar(&cfg.Duration, "duration", cfg.Duration,
+        "stop after capturing for this long; for files, measured from the first packet's timestamp (0 for no limit)")
+
+    fs.StringVar(&cfg.BPFFilter, "bpf", cfg.BPFFilter,
+        "BPF filter expression applied to the device or file, e.g. \"tcp or udp\"")
+}
+
+// flowFlags control when flows are exported.
+func (cfg *Config) flowFlags(fs *flag.FlagSet) {
+    fs.DurationVar(&cfg.IdleTimeout, "idle-timeout", cfg.IdleTimeout,
+        "export a flow after it has seen no packets for this long (0 disables)")
+
+    fs.DurationVar(&cfg.ActiveTimeout, "active-timeout", cfg.ActiveTimeout,
+        "export a flow after it has been open this long (0 disables)")
+}
+
+func (cfg *Config) modelFlags(fs *flag.FlagSet) {
+    fs.StringVar(&cfg.ModelPath, "model", cfg.ModelPath,
+        "model bundle file, or a legacy parameter directory such as ml/parameters")
+}
+
+// scoringFlags control the model, verdicts and result outputs.
+func (cfg *Config) scoringFlags(fs *flag.FlagSet) {
+    cfg.modelFlags(fs)
+
+    fs.IntVar(&cfg.ExplainTop, "explain-top", cfg.ExplainTop,
+        "number of top contributing features reported per flow (0 disables)")
+
+    fs.Func("threshold", "`probability` above which a flow is labeled malicious (default the model's threshold)", func(v string) error {
+        p, err := strconv.ParseFloat(v, 64)
+        if err != nil {
+            return err
+        }
+        cfg.Threshold = &p
+        return nil
+    })
+
+    fs.StringVar(&cfg.SeverityBands, "severity-bands", cfg.SeverityBands,
+        "probability at which each severity starts, e.g. \"low=0.5,medium=0.7,high=0.85,critical=0.95\" "+
+            "(empty uses the model's bands, if any)")
+
+    cfg.detectionFlags(fs)
+
+    fs.Var(&listFlag{list: &cfg.Formats}, "format",
+        "result outputs to write, comma-separated: "+strings.Join(output.Formats(), ", "))
+
+    fs.StringVar(&cfg.EncryptKey, "encrypt-key", cfg.EncryptKey,
+        "passphrase to encrypt the result files with (optional)")
+}
+
+// hostFlags choose the local hosts that are profiled.
+func (cfg *Config) hostFlags(fs *flag.FlagSet) {
+    fs.StringVar(&cfg.LocalIP, "local-ip", cfg.LocalIP,
+        "local addresses or CIDR networks, comma-separated, whose hosts are profiled "+
+            "(default private, link-local and loopback addresses)")
+
+    fs.DurationVar(&cfg.HostWindow, "host-window", cfg.HostWindow,
+        "how far back destinations count towards a host's fan-out and new-destination rate")
+}
+
+// detectionFlags tune the detections that raise alerts besides the model.
+func (cfg *Config) detectionFlags(fs *flag.FlagSet) {
+    fs.StringVar(&cfg.AlertSeverity, "alert-severity", cfg.AlertSeverity,
+        "severity from which an alert labels a flow malicious; weaker alerts only raise its severity "+
+            "(none lets every alert label it)")
+
+    fs.StringVar(&cfg.TLSBlocklist, "tls-blocklist", cfg.TLSBlocklist,
+        "file of JA3, JA3S or JA4 fingerprints, one per line with an optional description; "+
+            "flows that match are malicious (optional)")
+
+    fs.Float64Var(&cfg.DGAThreshold, "dga-threshold", cfg.DGAThreshold,
+        "DGA score (0-1) at which a queried DNS name raises an alert (0 disables)")
+
+    fs.IntVar(&cfg.NXDomainBurst, "nxdomain-burst", cfg.NXDomainBurst,
+        "NXDOMAIN responses to one client within -nxdomain-window that raise an alert (0 disables)")
+
+    fs.DurationVar(&cfg.NXDomainWindow, "nxdomain-window", cfg.NXDomainWindow,
+        "window over which NXDOMAIN responses are counted")
+
+    fs.IntVar(&cfg.DNSTunnelBytes, "dns-tunnel-bytes", cfg.DNSTunnelBytes,
+        "TXT or NULL answer size, in bytes, that raises a DNS tunneling alert (0 disables)")
+
+    fs.IntVar(&cfg.DNSTunnelName, "dns-tunnel-name-len", cfg.DNSTunnelName,
+        "queried name length, in characters, that raises a DNS tunneling alert (0 disables)")
+
+    fs.StringVar(&cfg.RulesDir, "rules", cfg.RulesDir,
+        "directory of YAML rule files matched against every flow (optional)")
+
+    fs.Var(&listFlag{list: &cfg.IOCPaths}, "ioc",
+        "threat-intelligence lists of IPs, CIDRs, domains and JA3 hashes, comma-separated or repeated; "+
+            "flat files or STIX 2 bundles (.json), or directories of them (optional)")
+
+    fs.DurationVar(&cfg.IOCReload, "ioc-reload", cfg.IOCReload,
+        "how often the -ioc lists are reloaded if they changed on disk (0 disables)")
+
+    fs.DurationVar(&cfg.BeaconWindow, "beacon-window", cfg.BeaconWindow,
+        "window over which flows from one host to the same destination are checked for beaconing")
+
+    fs.IntVar(&cfg.BeaconMinFlows, "beacon-min-flows", cfg.BeaconMinFlows,
+        "flows within -beacon-window a beacon needs")
+
+    fs.Float64Var(&cfg.BeaconScore, "beacon-score", cfg.BeaconScore,
+        "periodicity confidence (0-1) at which a beacon alert is raised (0 disables)")
+
+    fs.DurationVar(&cfg.ScanWindow, "scan-window", cfg.ScanWindow,
+        "window over which probes and half-open connections are counted for scan events")
+
+    fs.IntVar(&cfg.ScanPorts, "scan-ports", cfg.ScanPorts,
+        "distinct ports of one host a source may probe within -scan-window before a vertical scan is reported (0 disables)")
+
+    fs.IntVar(&cfg.SweepHosts, "sweep-hosts", cfg.SweepHosts,
+        "distinct hosts a source may probe on one port within -scan-window before a horizontal sweep is reported (0 disables)")
+
+    fs.IntVar(&cfg.SYNFlood, "syn-flood", cfg.SYNFlood,
+        "half-open TCP connections to one service within -scan-window that are reported as a SYN flood (0 disables)")
+}
+
+func (cfg *Config) cryptFlags(fs *flag.FlagSet, in, out string) {
+    fs.StringVar(&cfg.Key, "key", cfg.Key, "passphrase")
+    fs.StringVar(&cfg.InPath, "in", cfg.InPath, in)
+    fs.StringVar(&cfg.OutPath, "out", cfg.OutPath, out)
+}
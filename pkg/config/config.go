@@ -1,88 +1,340 @@
 package config
 
 import (
-    "flag"
+    "errors"
     "fmt"
+    "slices"
+    "strings"
     "time"
+
+    "github.com/google/gopacket/layers"
+    "github.com/google/gopacket/pcap"
+
+    "github.com/Tushar98644/PacketSentry/pkg/beacon"
+    "github.com/Tushar98644/PacketSentry/pkg/dns"
+    "github.com/Tushar98644/PacketSentry/pkg/host"
+    "github.com/Tushar98644/PacketSentry/pkg/output"
+    "github.com/Tushar98644/PacketSentry/pkg/scan"
+    "github.com/Tushar98644/PacketSentry/pkg/verdict"
 )
 
 type Config struct {
+    // Command is the subcommand being run; one of the Cmd* constants.
+    Command       string
+
     LiveCapture   bool  
+    Daemon        bool
     FileName      string 
+    Inputs        []string
     MaxPackets    int
-    LocalIPKnown  bool 
+    MaxBytes      int64
+    Duration      time.Duration
     LocalIP       string
+    // HostWindow is how far back host profiles count destinations.
+    HostWindow    time.Duration
 
     Device        string
     SnapshotLen   int32
     Promiscuous   bool
     Timeout       time.Duration
+    BPFFilter     string
+
+    IdleTimeout   time.Duration
+    ActiveTimeout time.Duration
+
+    ModelPath     string
+    ExplainTop    int
+    // Threshold overrides the model's decision threshold when set.
+    Threshold     *float64
+    SeverityBands string
+    Formats       []string
+
+    // TLSBlocklist is a file of JA3, JA3S and JA4 fingerprints that mark
+    // a flow malicious whatever the model says.
+    TLSBlocklist  string
+    // DNS detection thresholds; zero disables a detection.
+    DGAThreshold   float64
+    NXDomainBurst  int
+    NXDomainWindow time.Duration
+    DNSTunnelBytes int
+    DNSTunnelName  int
+    // RulesDir is a directory of YAML rule files; flows matching a rule
+    // are malicious whatever the model says.
+    RulesDir       string
+    // IOCPaths are threat-intelligence lists, files or directories, of
+    // addresses, networks, domains and JA3 hashes; IOCReload is how often
+    // they are checked for changes, zero for never.
+    IOCPaths       []string
+    IOCReload      time.Duration
+    // Beaconing: flows from one host to the same destination within
+    // BeaconWindow, at least BeaconMinFlows of them, are reported when
+    // their periodicity scores BeaconScore; zero disables.
+    BeaconWindow   time.Duration
+    BeaconMinFlows int
+    BeaconScore    float64
+    // AlertSeverity is the severity from which an alert labels a flow
+    // malicious whatever the model scored.
+    AlertSeverity  string
+    // Scan detection: within ScanWindow, a source probing ScanPorts ports
+    // of one host or SweepHosts hosts on one port, or SYNFlood half-open
+    // connections to one service, is reported; zero disables each.
+    ScanWindow     time.Duration
+    ScanPorts      int
+    SweepHosts     int
+    SYNFlood       int
+
+    // FeaturesOut is where the features command writes its CSV; empty
+    // means data/raw/<name>_features.csv.
+    FeaturesOut   string
+    // ListFeatures makes the features command print the feature columns
+    // instead of reading any packets.
+    ListFeatures  bool
 
     EncryptKey  string `flag:"encrypt-key" help:"Passphrase to encrypt output files (optional)"`
-    DecryptKey  string `flag:"decrypt-key" help:"Passphrase to decrypt an encrypted results file"`
-    DecryptMode bool   `flag:"decrypt"     help:"Run in decryption mode (reads .enc, writes plaintext)"`
-    DecryptIn   string `flag:"in"          help:"Input .enc file path (required in decrypt mode)"`
-    DecryptOut  string `flag:"out"         help:"Output plaintext file path (required in decrypt mode)"`
+
+    // Key, InPath and OutPath are the passphrase and files of the encrypt
+    // and decrypt commands.
+    Key         string
+    InPath      string
+    OutPath     string
 }
 
 func New() *Config {
     return &Config{
-        LiveCapture:  true,
         FileName:     "capture",
-        MaxPackets:   10,
-        LocalIPKnown: false,
+        MaxPackets:   0,
+        MaxBytes:     0,
+        Duration:     0,
         LocalIP:      "",
+        HostWindow:   host.DefaultWindow,
         Device:      "en0",
         SnapshotLen: 1024,
         Promiscuous: false,
         Timeout:     30 * time.Second,
+        IdleTimeout:   60 * time.Second,
+        ActiveTimeout: 30 * time.Minute,
+        ModelPath:     "ml/model.json",
+        ExplainTop:    3,
+        Formats:       []string{output.FormatCSV, output.FormatChart},
+        DGAThreshold:   dns.DefaultThresholds().DGAScore,
+        NXDomainBurst:  dns.DefaultThresholds().NXDomainBurst,
+        NXDomainWindow: dns.DefaultThresholds().NXDomainWindow,
+        DNSTunnelBytes: dns.DefaultThresholds().TunnelPayload,
+        DNSTunnelName:  dns.DefaultThresholds().TunnelNameLen,
+        IOCReload:      5 * time.Minute,
+        BeaconWindow:   beacon.DefaultThresholds().Window,
+        BeaconMinFlows: beacon.DefaultThresholds().MinFlows,
+        BeaconScore:    beacon.DefaultThresholds().Score,
+        AlertSeverity:  verdict.High.String(),
+        ScanWindow:     scan.DefaultThresholds().Window,
+        ScanPorts:      scan.DefaultThresholds().Ports,
+        SweepHosts:     scan.DefaultThresholds().Hosts,
+        SYNFlood:       scan.DefaultThresholds().HalfOpen,
     }
 }
 
-// ParseFlags binds command-line flags to the Config fields.
-// Call this before using any values in cfg.
-func (cfg *Config) ParseFlags() {
-    flag.BoolVar(&cfg.LiveCapture, "live", cfg.LiveCapture,
-        "true to capture live packets, false to read from pcap file")
+// stringList is a flag.Value that collects comma-separated values across
+// repeated uses of a flag.
+type stringList []string
 
-    flag.StringVar(&cfg.FileName, "fname", cfg.FileName,
-        "base name of the pcap file (no .pcap extension)")
+func (l *stringList) String() string {
+    return strings.Join(*l, ",")
+}
 
-    flag.IntVar(&cfg.MaxPackets, "max", cfg.MaxPackets,
-        "maximum number of packets to process")
+func (l *stringList) Set(v string) error {
+    for _, s := range strings.Split(v, ",") {
+        if s = strings.TrimSpace(s); s != "" {
+            *l = append(*l, s)
+        }
+    }
+    return nil
+}
 
-    flag.BoolVar(&cfg.LocalIPKnown, "local-known", cfg.LocalIPKnown,
-        "set to true if you will supply a local IP")
+// listFlag is a stringList flag whose first use replaces the list it
+// started with, such as a default or a value from the configuration file,
+// and whose later uses add to it.
+type listFlag struct {
+    list *[]string
+    set  bool
+}
 
-    flag.StringVar(&cfg.LocalIP, "local-ip", cfg.LocalIP,
-        "your local IP address (required if local-known=true)")
+func (l *listFlag) String() string {
+    if l.list == nil {
+        return ""
+    }
+    return strings.Join(*l.list, ",")
+}
 
-    flag.StringVar(&cfg.Device, "device", cfg.Device,
-        "network device to capture packets from")
+func (l *listFlag) Set(v string) error {
+    if !l.set {
+        *l.list, l.set = nil, true
+    }
+    return (*stringList)(l.list).Set(v)
+}
 
-    flag.StringVar(&cfg.EncryptKey, "encrypt-key", "", "Passphrase to encrypt output files (optional)")
-    flag.StringVar(&cfg.DecryptKey, "decrypt-key", "", "Passphrase to decrypt an encrypted results file")
-    flag.BoolVar(&cfg.DecryptMode, "decrypt", false, "Run in decryption mode (reads .enc, writes plaintext)")
-    flag.StringVar(&cfg.DecryptIn, "in", "", "Input .enc file path (required in decrypt mode)")
-    flag.StringVar(&cfg.DecryptOut, "out", "", "Output plaintext file path (required in decrypt mode)")
+// Validate checks the settings that matter to cfg.Command and reports
+// every problem it finds, not just the first.
+func (cfg *Config) Validate() error {
+    var checks []func() []error
+    switch cfg.Command {
+    case CmdAnalyze:
+        checks = []func() []error{cfg.validateFiles, cfg.validateLimits, cfg.validateFlows, cfg.validateHosts, cfg.validateScoring}
+    case CmdCapture:
+        checks = []func() []error{cfg.validateDevice, cfg.validateLimits, cfg.validateFlows, cfg.validateHosts, cfg.validateScoring}
+    case CmdFeatures:
+        if cfg.ListFeatures {
+            return nil
+        }
+        checks = []func() []error{cfg.validateFiles, cfg.validateLimits, cfg.validateFlows, cfg.validateHosts}
+    case CmdEncrypt, CmdDecrypt:
+        checks = []func() []error{cfg.validateCrypt}
+    case CmdInspectModel:
+        checks = []func() []error{cfg.validateModel}
+    default:
+        return fmt.Errorf("unknown command %q", cfg.Command)
+    }
 
-    flag.Parse()
+    var errs []error
+    for _, check := range checks {
+        errs = append(errs, check()...)
+    }
+    return errors.Join(errs...)
 }
 
-func (cfg *Config) Validate() error {
-    if !cfg.LiveCapture && cfg.FileName == "" {
-        return fmt.Errorf("fname must be set when live=false")
+func (cfg *Config) validateFiles() []error {
+    if cfg.FileName == "" && len(cfg.Inputs) == 0 {
+        return []error{fmt.Errorf("no capture files: pass them as arguments, with -input, or name one with -fname")}
+    }
+    return nil
+}
+
+func (cfg *Config) validateDevice() []error {
+    var errs []error
+    if cfg.Device == "" {
+        errs = append(errs, fmt.Errorf("device must be set"))
+    }
+    if cfg.SnapshotLen <= 0 {
+        errs = append(errs, fmt.Errorf("snaplen must be positive"))
+    }
+    if cfg.Timeout < 0 {
+        errs = append(errs, fmt.Errorf("timeout must not be negative"))
+    }
+    return errs
+}
+
+func (cfg *Config) validateLimits() []error {
+    var errs []error
+    if cfg.MaxPackets < 0 || cfg.MaxBytes < 0 || cfg.Duration < 0 {
+        errs = append(errs, fmt.Errorf("max, max-bytes and duration must not be negative"))
+    }
+    if cfg.BPFFilter != "" && cfg.SnapshotLen > 0 {
+        if err := ValidateBPF(cfg.BPFFilter, int(cfg.SnapshotLen)); err != nil {
+            errs = append(errs, err)
+        }
+    }
+    return errs
+}
+
+func (cfg *Config) validateFlows() []error {
+    if cfg.IdleTimeout < 0 || cfg.ActiveTimeout < 0 {
+        return []error{fmt.Errorf("idle-timeout and active-timeout must not be negative")}
+    }
+    return nil
+}
+
+func (cfg *Config) validateHosts() []error {
+    var errs []error
+    if _, err := host.ParseNetworks(cfg.LocalIP); err != nil {
+        errs = append(errs, fmt.Errorf("local-ip: %w", err))
+    }
+    if cfg.HostWindow <= 0 {
+        errs = append(errs, fmt.Errorf("host-window must be positive"))
+    }
+    return errs
+}
+
+// LocalNetworks returns the networks given with -local-ip, or none, which
+// stands for the private ranges. It is checked by Validate.
+func (cfg *Config) LocalNetworks() host.Networks {
+    nets, _ := host.ParseNetworks(cfg.LocalIP)
+    return nets
+}
+
+func (cfg *Config) validateModel() []error {
+    if cfg.ModelPath == "" {
+        return []error{fmt.Errorf("model must be set")}
     }
-    if cfg.LocalIPKnown && cfg.LocalIP == "" {
-        return fmt.Errorf("local-ip must be provided when local-known=true")
+    return nil
+}
+
+func (cfg *Config) validateScoring() []error {
+    errs := cfg.validateModel()
+    if cfg.ExplainTop < 0 {
+        errs = append(errs, fmt.Errorf("explain-top must not be negative"))
+    }
+    if cfg.Threshold != nil && (*cfg.Threshold < 0 || *cfg.Threshold > 1) {
+        errs = append(errs, fmt.Errorf("threshold must be between 0 and 1"))
     }
-    if cfg.DecryptMode {
-        if cfg.DecryptKey == "" || cfg.DecryptIn == "" || cfg.DecryptOut == "" {
-            return fmt.Errorf("decrypt mode requires --decrypt-key, --in, and --out")
+    if cfg.SeverityBands != "" {
+        if _, err := verdict.ParseBands(cfg.SeverityBands); err != nil {
+            errs = append(errs, fmt.Errorf("invalid severity-bands: %w", err))
         }
     }
-    if cfg.EncryptKey != "" && cfg.DecryptMode {
-        return fmt.Errorf("--encrypt-key cannot be used with --decrypt")
+    if cfg.DGAThreshold < 0 || cfg.DGAThreshold > 1 {
+        errs = append(errs, fmt.Errorf("dga-threshold must be between 0 and 1"))
+    }
+    if cfg.NXDomainBurst < 0 || cfg.DNSTunnelBytes < 0 || cfg.DNSTunnelName < 0 {
+        errs = append(errs, fmt.Errorf("nxdomain-burst, dns-tunnel-bytes and dns-tunnel-name-len must not be negative"))
+    }
+    if cfg.NXDomainBurst > 0 && cfg.NXDomainWindow <= 0 {
+        errs = append(errs, fmt.Errorf("nxdomain-window must be positive"))
+    }
+    if cfg.BeaconScore < 0 || cfg.BeaconScore > 1 {
+        errs = append(errs, fmt.Errorf("beacon-score must be between 0 and 1"))
+    }
+    if cfg.BeaconScore > 0 && (cfg.BeaconWindow <= 0 || cfg.BeaconMinFlows < 3) {
+        errs = append(errs, fmt.Errorf("beacon-window must be positive and beacon-min-flows at least 3"))
+    }
+    if cfg.ScanPorts < 0 || cfg.SweepHosts < 0 || cfg.SYNFlood < 0 {
+        errs = append(errs, fmt.Errorf("scan-ports, sweep-hosts and syn-flood must not be negative"))
+    }
+    if cfg.ScanWindow <= 0 && (cfg.ScanPorts > 0 || cfg.SweepHosts > 0 || cfg.SYNFlood > 0) {
+        errs = append(errs, fmt.Errorf("scan-window must be positive"))
+    }
+    if _, err := verdict.ParseSeverity(cfg.AlertSeverity); err != nil {
+        errs = append(errs, fmt.Errorf("alert-severity: %w", err))
+    }
+    if cfg.IOCReload < 0 {
+        errs = append(errs, fmt.Errorf("ioc-reload must not be negative"))
+    }
+    if len(cfg.Formats) == 0 {
+        errs = append(errs, fmt.Errorf("format must name at least one output"))
+    }
+    for _, f := range cfg.Formats {
+        if !slices.Contains(output.Formats(), f) {
+            errs = append(errs, fmt.Errorf("unknown format %q, want one of %s", f, strings.Join(output.Formats(), ", ")))
+        }
+    }
+    return errs
+}
+
+func (cfg *Config) validateCrypt() []error {
+    if cfg.Key == "" || cfg.InPath == "" || cfg.OutPath == "" {
+        return []error{fmt.Errorf("%s requires -key, -in and -out", cfg.Command)}
+    }
+    if cfg.InPath == cfg.OutPath {
+        return []error{fmt.Errorf("-in and -out must be different files")}
+    }
+    return nil
+}
+
+// ValidateBPF compiles expr for Ethernet so that syntax errors are reported
+// before any device or file is opened. Each device or file still compiles
+// the filter for its own link type when it is opened, which catches the
+// expressions that only fail there.
+func ValidateBPF(expr string, snaplen int) error {
+    if _, err := pcap.CompileBPFFilter(layers.LinkTypeEthernet, snaplen, expr); err != nil {
+        return fmt.Errorf("invalid bpf filter %q: %w", expr, err)
     }
     return nil
 }
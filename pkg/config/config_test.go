@@ -0,0 +1,175 @@
+package config
+
+import (
+    "errors"
+    "flag"
+    "os"
+    "slices"
+    "strings"
+    "testing"
+    "time"
+)
+
+// quiet sends what Parse prints about bad arguments to /dev/null for the
+// rest of the test.
+func quiet(t *testing.T) {
+    t.Helper()
+    null, err := os.Open(os.DevNull)
+    if err != nil {
+        t.Fatal(err)
+    }
+    stderr := os.Stderr
+    os.Stderr = null
+    t.Cleanup(func() {
+        os.Stderr = stderr
+        null.Close()
+    })
+}
+
+func ptr[T any](v T) *T {
+    return &v
+}
+
+func TestParseArgs(t *testing.T) {
+    tests := []struct {
+        name    string
+        command string
+        args    []string
+        inputs  []string
+        formats []string
+        max     int
+        err     string
+    }{
+        {"no arguments", CmdAnalyze, nil, nil, []string{"csv", "chart"}, 0, ""},
+        {"flags first", CmdAnalyze, []string{"-max", "5", "a.pcap", "b.pcap"},
+            []string{"a.pcap", "b.pcap"}, []string{"csv", "chart"}, 5, ""},
+        {"flags after arguments", CmdAnalyze, []string{"a.pcap", "-format", "ndjson", "b.pcap", "-max=5"},
+            []string{"a.pcap", "b.pcap"}, []string{"ndjson"}, 5, ""},
+        {"input flag and arguments", CmdFeatures, []string{"-input", "x.pcap,y.pcap", "a.pcap"},
+            []string{"x.pcap", "y.pcap", "a.pcap"}, []string{"csv", "chart"}, 0, ""},
+        {"after --", CmdAnalyze, []string{"a.pcap", "--", "-max"},
+            []string{"a.pcap", "-max"}, []string{"csv", "chart"}, 0, ""},
+        {"arguments to capture", CmdCapture, []string{"-device", "eth0", "a.pcap"},
+            nil, nil, 0, `capture takes no arguments, got "a.pcap"`},
+        {"unknown flag after arguments", CmdAnalyze, []string{"a.pcap", "-nope"},
+            nil, nil, 0, "flag provided but not defined: -nope"},
+        {"flag of another command", CmdEncrypt, []string{"-format", "csv"},
+            nil, nil, 0, "flag provided but not defined: -format"},
+        {"unknown command", "scan", nil, nil, nil, 0, `unknown command "scan"`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            quiet(t)
+            cfg := New()
+            err := cfg.Parse(tt.command, tt.args)
+            if tt.err != "" {
+                if err == nil || !strings.Contains(err.Error(), tt.err) {
+                    t.Fatalf("Parse = %v, want an error containing %q", err, tt.err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            if cfg.Command != tt.command {
+                t.Errorf("Command = %q, want %q", cfg.Command, tt.command)
+            }
+            if !slices.Equal(cfg.Inputs, tt.inputs) || !slices.Equal(cfg.Formats, tt.formats) || cfg.MaxPackets != tt.max {
+                t.Errorf("inputs %q, formats %q, max %d, want %q, %q, %d",
+                    cfg.Inputs, cfg.Formats, cfg.MaxPackets, tt.inputs, tt.formats, tt.max)
+            }
+        })
+    }
+
+    quiet(t)
+    if err := New().Parse(CmdAnalyze, []string{"-h"}); !errors.Is(err, flag.ErrHelp) {
+        t.Errorf("Parse(-h) = %v, want flag.ErrHelp", err)
+    }
+}
+
+func TestValidate(t *testing.T) {
+    tests := []struct {
+        name    string
+        command string
+        edit    func(cfg *Config)
+        errs    []string
+    }{
+        {"defaults", CmdAnalyze, func(cfg *Config) {}, nil},
+        {"every problem", CmdAnalyze, func(cfg *Config) {
+            cfg.MaxPackets = -1
+            cfg.IdleTimeout = -time.Second
+            cfg.Threshold = ptr(2.0)
+            cfg.Formats = []string{"csv", "xml"}
+        }, []string{
+            "max, max-bytes and duration must not be negative",
+            "idle-timeout and active-timeout must not be negative",
+            "threshold must be between 0 and 1",
+            `unknown format "xml"`,
+        }},
+        {"dns tunnel name length", CmdAnalyze, func(cfg *Config) {
+            cfg.DNSTunnelName = -1
+        }, []string{"nxdomain-burst, dns-tunnel-bytes and dns-tunnel-name-len must not be negative"}},
+        {"bad bpf filter", CmdAnalyze, func(cfg *Config) {
+            cfg.BPFFilter = "tcp and and"
+        }, []string{`invalid bpf filter "tcp and and"`}},
+        {"capture checks the device, not files", CmdCapture, func(cfg *Config) {
+            cfg.FileName = ""
+            cfg.Device = ""
+            cfg.SnapshotLen = 0
+        }, []string{"device must be set", "snaplen must be positive"}},
+        {"analyze checks files, not the device", CmdAnalyze, func(cfg *Config) {
+            cfg.FileName = ""
+            cfg.Device = ""
+        }, []string{"no capture files"}},
+        {"features skips scoring", CmdFeatures, func(cfg *Config) {
+            cfg.Threshold = ptr(2.0)
+            cfg.ModelPath = ""
+        }, nil},
+        {"features -list skips everything", CmdFeatures, func(cfg *Config) {
+            cfg.ListFeatures = true
+            cfg.MaxPackets = -1
+        }, nil},
+        {"inspect-model", CmdInspectModel, func(cfg *Config) {
+            cfg.ModelPath = ""
+            cfg.MaxPackets = -1
+        }, []string{"model must be set"}},
+        {"encrypt", CmdEncrypt, func(cfg *Config) {
+            cfg.Threshold = ptr(2.0)
+        }, []string{"encrypt requires -key, -in and -out"}},
+        {"decrypt in place", CmdDecrypt, func(cfg *Config) {
+            cfg.Key, cfg.InPath, cfg.OutPath = "k", "a", "a"
+        }, []string{"-in and -out must be different files"}},
+        {"unknown command", "scan", func(cfg *Config) {}, []string{`unknown command "scan"`}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            cfg := New()
+            cfg.Command = tt.command
+            tt.edit(cfg)
+            err := cfg.Validate()
+            if len(tt.errs) == 0 {
+                if err != nil {
+                    t.Fatalf("Validate = %v", err)
+                }
+                return
+            }
+            if err == nil {
+                t.Fatalf("Validate = nil, want %q", tt.errs)
+            }
+            var got []error
+            if joined, ok := err.(interface{ Unwrap() []error }); ok {
+                got = joined.Unwrap()
+            } else {
+                got = []error{err}
+            }
+            if len(got) != len(tt.errs) {
+                t.Fatalf("Validate returned %d errors, want %d:\n%v", len(got), len(tt.errs), err)
+            }
+            for i, e := range got {
+                if !strings.Contains(e.Error(), tt.errs[i]) {
+                    t.Errorf("error %d = %q, want it to contain %q", i, e, tt.errs[i])
+                }
+            }
+        })
+    }
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+    "time"
+
+    "gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the environment variable of every flag: -idle-timeout
+// is read from PACKETSENTRY_IDLE_TIMEOUT. PACKETSENTRY_CONFIG names the
+// configuration file when -config is not given.
+const EnvPrefix = "PACKETSENTRY_"
+
+// File is the layout of a YAML configuration file. Every setting is
+// optional; the ones left out keep their defaults.
+type File struct {
+    Capture    CaptureSection    `yaml:"capture"`
+    Flows      FlowsSection      `yaml:"flows"`
+    Model      ModelSection      `yaml:"model"`
+    Output     OutputSection     `yaml:"output"`
+    Detection  DetectionSection  `yaml:"detection"`
+    Encryption EncryptionSection `yaml:"encryption"`
+}
+
+type CaptureSection struct {
+    Device      *string        `yaml:"device"`
+    SnapshotLen *int32         `yaml:"snaplen"`
+    Promiscuous *bool          `yaml:"promiscuous"`
+    Timeout     *time.Duration `yaml:"timeout"`
+    BPFFilter   *string        `yaml:"bpf"`
+    MaxPackets  *int           `yaml:"max_packets"`
+    MaxBytes    *int64         `yaml:"max_bytes"`
+    Duration    *time.Duration `yaml:"duration"`
+    Daemon      *bool          `yaml:"daemon"`
+    LocalIP     *string        `yaml:"local_ip"`
+}
+
+type FlowsSection struct {
+    IdleTimeout   *time.Duration `yaml:"idle_timeout"`
+    ActiveTimeout *time.Duration `yaml:"active_timeout"`
+    HostWindow    *time.Duration `yaml:"host_window"`
+}
+
+type ModelSection struct {
+    Path          *string  `yaml:"path"`
+    Threshold     *float64 `yaml:"threshold"`
+    SeverityBands *string  `yaml:"severity_bands"`
+    ExplainTop    *int     `yaml:"explain_top"`
+}
+
+type OutputSection struct {
+    // Name is the base name of the output files, like -fname.
+    Name    *string  `yaml:"name"`
+    Formats []string `yaml:"formats"`
+}
+
+type DetectionSection struct {
+    AlertSeverity  *string        `yaml:"alert_severity"`
+    TLSBlocklist   *string        `yaml:"tls_blocklist"`
+    DGAThreshold   *float64       `yaml:"dga_threshold"`
+    NXDomainBurst  *int           `yaml:"nxdomain_burst"`
+    NXDomainWindow *time.Duration `yaml:"nxdomain_window"`
+    DNSTunnelBytes *int           `yaml:"dns_tunnel_bytes"`
+    DNSTunnelName  *int           `yaml:"dns_tunnel_name_len"`
+    Rules          *string        `yaml:"rules"`
+    IOC            []string       `yaml:"ioc"`
+    IOCReload      *time.Duration `yaml:"ioc_reload"`
+    BeaconWindow   *time.Duration `yaml:"beacon_window"`
+    BeaconMinFlows *int           `yaml:"beacon_min_flows"`
+    BeaconScore    *float64       `yaml:"beacon_score"`
+    ScanWindow     *time.Duration `yaml:"scan_window"`
+    ScanPorts      *int           `yaml:"scan_ports"`
+    SweepHosts     *int           `yaml:"sweep_hosts"`
+    SYNFlood       *int           `yaml:"syn_flood"`
+}
+
+type EncryptionSection struct {
+    Key *string `yaml:"key"`
+}
+
+// LoadFile applies the settings in the YAML file at path to cfg. Unknown
+// keys are errors, so that a misspelt setting is not silently ignored.
+func (cfg *Config) LoadFile(path string) error {
+    f, err := os.Open(path)
+    if err != nil {
+        return fmt.Errorf("open config: %w", err)
+    }
+    defer f.Close()
+
+    var file File
+    dec := yaml.NewDecoder(f)
+    dec.KnownFields(true)
+    if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+        return fmt.Errorf("parse %s: %w", path, err)
+    }
+    cfg.apply(file)
+    return nil
+}
+
+func (cfg *Config) apply(file File) {
+    set(&cfg.Device, file.Capture.Device)
+    set(&cfg.SnapshotLen, file.Capture.SnapshotLen)
+    set(&cfg.Promiscuous, file.Capture.Promiscuous)
+    set(&cfg.Timeout, file.Capture.Timeout)
+    set(&cfg.BPFFilter, file.Capture.BPFFilter)
+    set(&cfg.MaxPackets, file.Capture.MaxPackets)
+    set(&cfg.MaxBytes, file.Capture.MaxBytes)
+    set(&cfg.Duration, file.Capture.Duration)
+    set(&cfg.Daemon, file.Capture.Daemon)
+    set(&cfg.LocalIP, file.Capture.LocalIP)
+
+    set(&cfg.IdleTimeout, file.Flows.IdleTimeout)
+    set(&cfg.ActiveTimeout, file.Flows.ActiveTimeout)
+    set(&cfg.HostWindow, file.Flows.HostWindow)
+
+    set(&cfg.ModelPath, file.Model.Path)
+    if file.Model.Threshold != nil {
+        cfg.Threshold = file.Model.Threshold
+    }
+    set(&cfg.SeverityBands, file.Model.SeverityBands)
+    set(&cfg.ExplainTop, file.Model.ExplainTop)
+
+    set(&cfg.FileName, file.Output.Name)
+    if file.Output.Formats != nil {
+        cfg.Formats = file.Output.Formats
+    }
+
+    set(&cfg.TLSBlocklist, file.Detection.TLSBlocklist)
+    set(&cfg.DGAThreshold, file.Detection.DGAThreshold)
+    set(&cfg.NXDomainBurst, file.Detection.NXDomainBurst)
+    set(&cfg.NXDomainWindow, file.Detection.NXDomainWindow)
+    set(&cfg.DNSTunnelBytes, file.Detection.DNSTunnelBytes)
+    set(&cfg.DNSTunnelName, file.Detection.DNSTunnelName)
+    set(&cfg.RulesDir, file.Detection.Rules)
+    if file.Detection.IOC != nil {
+        cfg.IOCPaths = file.Detection.IOC
+    }
+    set(&cfg.IOCReload, file.Detection.IOCReload)
+    set(&cfg.BeaconWindow, file.Detection.BeaconWindow)
+    set(&cfg.BeaconMinFlows, file.Detection.BeaconMinFlows)
+    set(&cfg.BeaconScore, file.Detection.BeaconScore)
+    set(&cfg.AlertSeverity, file.Detection.AlertSeverity)
+    set(&cfg.ScanWindow, file.Detection.ScanWindow)
+    set(&cfg.ScanPorts, file.Detection.ScanPorts)
+    set(&cfg.SweepHosts, file.Detection.SweepHosts)
+    set(&cfg.SYNFlood, file.Detection.SYNFlood)
+
+    set(&cfg.EncryptKey, file.Encryption.Key)
+}
+
+func set[T any](dst *T, v *T) {
+    if v != nil {
+        *dst = *v
+    }
+}
+
+// configPath finds the configuration file named by -config in args, or
+// else by PACKETSENTRY_CONFIG. The flag itself is parsed later with the
+// others; it is looked up first because the file supplies their defaults.
+func configPath(args []string) string {
+    for i, a := range args {
+        if a == "--" {
+            break
+        }
+        name, val, hasVal := strings.Cut(strings.TrimLeft(a, "-"), "=")
+        if !strings.HasPrefix(a, "-") || name != "config" {
+            continue
+        }
+        if hasVal {
+            return val
+        }
+        if i+1 < len(args) {
+            return args[i+1]
+        }
+    }
+    return os.Getenv(EnvPrefix + "CONFIG")
+}
+
+// applyEnv sets every flag of fs that has an environment variable, parsing
+// the value exactly as the flag would.
+func applyEnv(fs *flag.FlagSet) error {
+    var errs []error
+    fs.VisitAll(func(f *flag.Flag) {
+        if f.Name == "config" {
+            return
+        }
+        name := EnvPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+        val, ok := os.LookupEnv(name)
+        if !ok {
+            return
+        }
+        if err := fs.Set(f.Name, val); err != nil {
+            errs = append(errs, fmt.Errorf("%s: invalid value %q: %w", name, val, err))
+        }
+    })
+    return errors.Join(errs...)
+}
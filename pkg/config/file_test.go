@@ -0,0 +1,166 @@
+package config
+
+import (
+    "flag"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+    "testing"
+    "time"
+)
+
+// writeFile writes a configuration file to a temporary directory and
+// returns its path.
+func writeFile(t *testing.T, content string) string {
+    t.Helper()
+    path := filepath.Join(t.TempDir(), "packetsentry.yaml")
+    if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+func TestPrecedence(t *testing.T) {
+    const file = `
+flows:
+  idle_timeout: 10s
+  active_timeout: 5m
+model:
+  threshold: 0.6
+  explain_top: 5
+output:
+  formats: [ndjson, csv]
+`
+    // settings are the values each case checks.
+    type settings struct {
+        idle, active time.Duration
+        threshold    float64
+        explainTop   int
+        formats      string
+    }
+    tests := []struct {
+        name string
+        env  map[string]string
+        args []string
+        want settings
+    }{
+        {"file", nil, nil, settings{10 * time.Second, 5 * time.Minute, 0.6, 5, "ndjson,csv"}},
+        {"environment over file", map[string]string{
+            "PACKETSENTRY_IDLE_TIMEOUT": "20s",
+            "PACKETSENTRY_FORMAT":       "chart",
+        }, nil, settings{20 * time.Second, 5 * time.Minute, 0.6, 5, "chart"}},
+        {"flags over environment", map[string]string{
+            "PACKETSENTRY_IDLE_TIMEOUT": "20s",
+            "PACKETSENTRY_EXPLAIN_TOP":  "7",
+        }, []string{"-idle-timeout", "30s", "-format", "csv"},
+            settings{30 * time.Second, 5 * time.Minute, 0.6, 7, "csv"}},
+        {"flags after arguments", nil, []string{"a.pcap", "-threshold", "0.9"},
+            settings{10 * time.Second, 5 * time.Minute, 0.9, 5, "ndjson,csv"}},
+        {"zero threshold", map[string]string{"PACKETSENTRY_THRESHOLD": "0.8"}, []string{"-threshold", "0"},
+            settings{10 * time.Second, 5 * time.Minute, 0, 5, "ndjson,csv"}},
+    }
+    for _, tt := range tests {
+        for _, byEnv := range []bool{false, true} {
+            name := tt.name
+            if byEnv {
+                name += " via PACKETSENTRY_CONFIG"
+            }
+            t.Run(name, func(t *testing.T) {
+                path := writeFile(t, file)
+                args := tt.args
+                if byEnv {
+                    t.Setenv("PACKETSENTRY_CONFIG", path)
+                } else {
+                    args = append([]string{"-config", path}, args...)
+                }
+                for k, v := range tt.env {
+                    t.Setenv(k, v)
+                }
+
+                cfg := New()
+                if err := cfg.Parse(CmdAnalyze, args); err != nil {
+                    t.Fatal(err)
+                }
+                if cfg.Threshold == nil {
+                    t.Fatal("threshold not set")
+                }
+                got := settings{cfg.IdleTimeout, cfg.ActiveTimeout, *cfg.Threshold, cfg.ExplainTop, strings.Join(cfg.Formats, ",")}
+                if got != tt.want {
+                    t.Errorf("got %+v, want %+v", got, tt.want)
+                }
+            })
+        }
+    }
+}
+
+func TestParseErrors(t *testing.T) {
+    tests := []struct {
+        name string
+        file string
+        env  map[string]string
+        err  string
+    }{
+        {"unknown key", "capture:\n  devise: eth0\n", nil, "field devise not found"},
+        {"unknown section", "captur:\n  device: eth0\n", nil, "field captur not found"},
+        {"wrong type", "flows:\n  idle_timeout: soon\n", nil, "cannot unmarshal !!str `soon`"},
+        {"bad environment value", "", map[string]string{"PACKETSENTRY_MAX": "lots"}, `PACKETSENTRY_MAX: invalid value "lots"`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            quiet(t)
+            for k, v := range tt.env {
+                t.Setenv(k, v)
+            }
+            err := New().Parse(CmdAnalyze, []string{"-config", writeFile(t, tt.file)})
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Errorf("Parse = %v, want an error containing %q", err, tt.err)
+            }
+        })
+    }
+
+    // An empty file keeps every default.
+    cfg := New()
+    if err := cfg.Parse(CmdAnalyze, []string{"-config", writeFile(t, "")}); err != nil {
+        t.Fatal(err)
+    }
+    if cfg.IdleTimeout != New().IdleTimeout || cfg.Threshold != nil {
+        t.Errorf("idle timeout %v, threshold %v after an empty file", cfg.IdleTimeout, cfg.Threshold)
+    }
+
+    // A threshold of 0 in the file is kept, not taken for unset.
+    cfg = New()
+    if err := cfg.Parse(CmdAnalyze, []string{"-config", writeFile(t, "model:\n  threshold: 0\n")}); err != nil {
+        t.Fatal(err)
+    }
+    if cfg.Threshold == nil || *cfg.Threshold != 0 {
+        t.Errorf("threshold %v, want 0", cfg.Threshold)
+    }
+}
+
+func TestListFlag(t *testing.T) {
+    tests := []struct {
+        name string
+        args []string
+        want []string
+    }{
+        {"unused", nil, []string{"a", "b"}},
+        {"first use replaces", []string{"-x", "c"}, []string{"c"}},
+        {"later uses add", []string{"-x", "c,d", "-x", "e"}, []string{"c", "d", "e"}},
+        {"blanks dropped", []string{"-x", " c, ,d "}, []string{"c", "d"}},
+        {"empty clears", []string{"-x", ""}, nil},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            list := []string{"a", "b"}
+            fs := flag.NewFlagSet("test", flag.ContinueOnError)
+            fs.Var(&listFlag{list: &list}, "x", "")
+            if err := fs.Parse(tt.args); err != nil {
+                t.Fatal(err)
+            }
+            if !slices.Equal(list, tt.want) {
+                t.Errorf("list = %q, want %q", list, tt.want)
+            }
+        })
+    }
+}
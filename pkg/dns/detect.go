@@ -0,0 +1,137 @@
+package dns
+
+import (
+    "fmt"
+    "net"
+    "time"
+
+    "github.com/Tushar98644/PacketSentry/pkg/verdict"
+)
+
+// Alert sources raised by the Detector.
+const (
+    SourceDGA      = "dns-dga"
+    SourceNXDomain = "dns-nxdomain-burst"
+    SourceTunnel   = "dns-tunnel"
+)
+
+// pruneEvery is how many NXDOMAIN checks pass between sweeps of clients
+// whose responses all fell out of the window.
+const pruneEvery = 1024
+
+// Thresholds tune the Detector. A zero value disables that detection.
+type Thresholds struct {
+    // DGAScore is the Score at which a queried name is reported.
+    DGAScore       float64
+    // NXDomainBurst is the number of NXDOMAIN responses one client may
+    // get within NXDomainWindow before it is reported; malware cycling
+    // through generated names hits many that are not registered.
+    NXDomainBurst  int
+    NXDomainWindow time.Duration
+    // TunnelPayload is the size of TXT or NULL answer, and TunnelNameLen
+    // the length of queried name, at which a flow looks like a tunnel.
+    TunnelPayload  int
+    TunnelNameLen  int
+}
+
+// DefaultThresholds are tuned to stay quiet on ordinary browsing.
+func DefaultThresholds() Thresholds {
+    return Thresholds{
+        DGAScore:       0.7,
+        NXDomainBurst:  10,
+        NXDomainWindow: time.Minute,
+        TunnelPayload:  250,
+        TunnelNameLen:  100,
+    }
+}
+
+// Detector turns the DNS records of flows into alerts. NXDOMAIN bursts
+// span flows, since every lookup usually gets its own, so the Detector
+// keeps recent NXDOMAIN responses per client.
+type Detector struct {
+    th     Thresholds
+    nx     map[string][]nxEvent
+    checks int
+}
+
+type nxEvent struct {
+    at time.Time
+    n  int
+}
+
+func NewDetector(th Thresholds) *Detector {
+    return &Detector{th: th, nx: make(map[string][]nxEvent)}
+}
+
+// Check returns the alerts for a flow from client whose DNS records are
+// info, ending at time at. Flows must be checked roughly in time order.
+func (d *Detector) Check(client net.IP, info *Info, at time.Time) []verdict.Alert {
+    if info == nil {
+        return nil
+    }
+    var alerts []verdict.Alert
+
+    if d.th.DGAScore > 0 && info.DGAScore >= d.th.DGAScore {
+        alerts = append(alerts, verdict.Alert{
+            Source:   SourceDGA,
+            Detail:   fmt.Sprintf("%s scores %.2f", info.DGAName, info.DGAScore),
+            Severity: verdict.Medium,
+        })
+    }
+
+    if d.th.TunnelPayload > 0 && info.MaxTunnelPayload >= d.th.TunnelPayload {
+        alerts = append(alerts, verdict.Alert{
+            Source:   SourceTunnel,
+            Detail:   fmt.Sprintf("%d byte TXT/NULL answer", info.MaxTunnelPayload),
+            Severity: verdict.High,
+        })
+    } else if d.th.TunnelNameLen > 0 && info.MaxNameLen >= d.th.TunnelNameLen {
+        alerts = append(alerts, verdict.Alert{
+            Source:   SourceTunnel,
+            Detail:   fmt.Sprintf("%d character query name", info.MaxNameLen),
+            Severity: verdict.High,
+        })
+    }
+
+    if d.th.NXDomainBurst > 0 && info.NXDomain > 0 {
+        if n := d.addNXDomain(client.String(), info.NXDomain, at); n >= d.th.NXDomainBurst {
+            alerts = append(alerts, verdict.Alert{
+                Source:   SourceNXDomain,
+                Detail:   fmt.Sprintf("%d NXDOMAIN responses to %s within %s", n, client, d.th.NXDomainWindow),
+                Severity: verdict.Medium,
+            })
+        }
+    }
+    return alerts
+}
+
+// addNXDomain records n NXDOMAIN responses to client at time at and
+// returns how many it got within the window.
+func (d *Detector) addNXDomain(client string, n int, at time.Time) int {
+    events := d.nx[client]
+    i := 0
+    for i < len(events) && at.Sub(events[i].at) > d.th.NXDomainWindow {
+        i++
+    }
+    events = append(events[i:], nxEvent{at: at, n: n})
+    d.nx[client] = events
+    if d.checks++; d.checks%pruneEvery == 0 {
+        d.prune(at)
+    }
+
+    total := 0
+    for _, e := range events {
+        total += e.n
+    }
+    return total
+}
+
+// prune forgets the clients whose last NXDOMAIN response is older than
+// the window as of now.
+func (d *Detector) prune(now time.Time) {
+    for k, events := range d.nx {
+        if now.Sub(events[len(events)-1].at) > d.th.NXDomainWindow {
+            delete(d.nx, k)
+        }
+    }
+}
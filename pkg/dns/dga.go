@@ -0,0 +1,76 @@
+package dns
+
+import (
+    "strings"
+
+    "github.com/Tushar98644/PacketSentry/pkg/stats"
+)
+
+// commonBigrams are the letter pairs frequent in English and in the words
+// people pick for domain names. Generated names are mostly made of the
+// others.
+var commonBigrams = func() map[string]bool {
+    const list = "th he in er an re on at en nd ti es or te of ed is it al ar st to nt ng " +
+        "se ha as ou io le ve co me de hi ri ro ic ne ea ra ce li ch ll be ma si om ur " +
+        "ca el ta la ns di fo ho pe ec pr no ct us ac ot il tr ly nc et ut ss so rs un " +
+        "lo wa ge ie wh ee wi em ad ol rt po we na ul ni ts mo ow pa im mi ai sh ir su " +
+        "id os iv ia am fi ci vi pl ig tu ev ld ry mp fe bl ab gh ty op wo sa ay ex ke " +
+        "fr oo av ag if ap gr od bo sp rd do uc bu ei ov by rm ep tt oc fa ef cu rn sc " +
+        "gi da yo cr cl du ga qu ue ff ba ey ls va um pp ua up lu go ht ru ug ds lt pi " +
+        "rc rr eg au ck ew mu br bi pt ak pu ui rg ib tl ny ki rk ys ob mm fu ph og ms " +
+        "ye ud mb ip ub oi rl gu dr hr cc tw ft wn nu af hu nn eo vo rv nf xp gn sm fl " +
+        "iz ok nl my gl aw ju oa eq sy sl ps jo lf nv je nk kn gs dy hy ze ks xt bs ik " +
+        "dd cy rp sk xi oe oy ws lv dl rf eu dg wr xa yi nm eb rb tm xc eh tc gy ja hn " +
+        "yp za"
+    m := make(map[string]bool)
+    for _, b := range strings.Fields(list) {
+        m[b] = true
+    }
+    return m
+}()
+
+// Score rates how likely name is to come from a domain generation
+// algorithm, from 0 to 1. It looks at the longest label below the top
+// level domain: its share of uncommon letter pairs, which weighs most, its
+// character entropy and its length.
+func Score(name string) float64 {
+    label := scoredLabel(name)
+    if len(label) < 2 {
+        return 0
+    }
+
+    rare := 0
+    for i := 0; i+1 < len(label); i++ {
+        // Pairs with digits or hyphens are uncommon by definition.
+        if !commonBigrams[label[i:i+2]] {
+            rare++
+        }
+    }
+    rareShare := float64(rare) / float64(len(label)-1)
+    entropy := min(stats.Entropy([]byte(label))/4, 1)
+    length := min(max(float64(len(label)-8)/12, 0), 1)
+
+    return 0.5*rareShare + 0.25*entropy + 0.25*length
+}
+
+// scoredLabel returns the longest label of name other than the top level
+// domain.
+func scoredLabel(name string) string {
+    labels := strings.Split(strings.TrimSuffix(strings.ToLower(name), "."), ".")
+    if len(labels) > 1 {
+        labels = labels[:len(labels)-1]
+    }
+    var longest string
+    for _, l := range labels {
+        if len(l) > len(longest) {
+            longest = l
+        }
+    }
+    return longest
+}
+
+// LabelEntropy returns the Shannon entropy, in bits per character, of the
+// label of name that Score looks at.
+func LabelEntropy(name string) float64 {
+    return stats.Entropy([]byte(scoredLabel(name)))
+}
@@ -0,0 +1,135 @@
+// Package dns records the DNS messages of a flow and scores them for
+// domain generation algorithms, NXDOMAIN bursts and tunneling.
+package dns
+
+import (
+    "fmt"
+    "net"
+    "strings"
+
+    "github.com/google/gopacket/layers"
+)
+
+// maxRecorded bounds the names and addresses kept per flow; the counters
+// keep counting past it.
+const maxRecorded = 32
+
+// Query is one queried name and record type.
+type Query struct {
+    Name string
+    Type string
+}
+
+// Info holds the DNS messages seen in one flow.
+type Info struct {
+    Queries   []Query
+    // Answers are the A and AAAA addresses in the responses.
+    Answers   []net.IP
+    // RCodes counts the responses by response code, e.g. "NXDOMAIN".
+    RCodes    map[string]int
+
+    QueryCount    int
+    ResponseCount int
+    NXDomain      int
+
+    // MaxNameLen is the length of the longest queried name, and
+    // MaxTunnelPayload the largest TXT or NULL record answered, the usual
+    // carriers of data tunneled through DNS.
+    MaxNameLen       int
+    MaxTunnelPayload int
+
+    // DGAScore is the highest Score of the queried names, for DGAName.
+    DGAScore float64
+    DGAName  string
+}
+
+// Add records one DNS message.
+func (info *Info) Add(msg *layers.DNS) {
+    if msg.QR {
+        info.ResponseCount++
+        rcode := rcodeName(msg.ResponseCode)
+        if info.RCodes == nil {
+            info.RCodes = make(map[string]int)
+        }
+        info.RCodes[rcode]++
+        if msg.ResponseCode == layers.DNSResponseCodeNXDomain {
+            info.NXDomain++
+        }
+    } else {
+        info.QueryCount++
+    }
+
+    // Responses repeat the question, which is recorded once.
+    for _, q := range msg.Questions {
+        info.addQuery(Query{Name: strings.ToLower(string(q.Name)), Type: typeName(q.Type)})
+    }
+
+    for _, rr := range msg.Answers {
+        switch rr.Type {
+        case layers.DNSTypeA, layers.DNSTypeAAAA:
+            if len(info.Answers) < maxRecorded && !containsIP(info.Answers, rr.IP) {
+                info.Answers = append(info.Answers, rr.IP)
+            }
+        case layers.DNSTypeTXT, layers.DNSTypeNULL:
+            info.MaxTunnelPayload = max(info.MaxTunnelPayload, len(rr.Data))
+        }
+    }
+}
+
+func (info *Info) addQuery(q Query) {
+    info.MaxNameLen = max(info.MaxNameLen, len(q.Name))
+    for _, seen := range info.Queries {
+        if seen == q {
+            return
+        }
+    }
+    if score := Score(q.Name); score > info.DGAScore {
+        info.DGAScore, info.DGAName = score, q.Name
+    }
+    if len(info.Queries) < maxRecorded {
+        info.Queries = append(info.Queries, q)
+    }
+}
+
+func containsIP(ips []net.IP, ip net.IP) bool {
+    for _, x := range ips {
+        if x.Equal(ip) {
+            return true
+        }
+    }
+    return false
+}
+
+// typeName names a record type, using the TYPE<n> form of RFC 3597 for
+// types gopacket does not know.
+func typeName(t layers.DNSType) string {
+    switch t {
+    case 64:
+        return "SVCB"
+    case 65:
+        return "HTTPS"
+    }
+    if name := t.String(); name != "Unknown" {
+        return name
+    }
+    return fmt.Sprintf("TYPE%d", uint16(t))
+}
+
+// rcodeName names a response code as in dig output, e.g. NXDOMAIN.
+func rcodeName(c layers.DNSResponseCode) string {
+    switch c {
+    case layers.DNSResponseCodeNoErr:
+        return "NOERROR"
+    case layers.DNSResponseCodeFormErr:
+        return "FORMERR"
+    case layers.DNSResponseCodeServFail:
+        return "SERVFAIL"
+    case layers.DNSResponseCodeNXDomain:
+        return "NXDOMAIN"
+    case layers.DNSResponseCodeNotImp:
+        return "NOTIMP"
+    case layers.DNSResponseCodeRefused:
+        return "REFUSED"
+    }
+    return fmt.Sprintf("RCODE%d", uint8(c))
+}
@@ -0,0 +1,97 @@
+package dns
+
+import (
+    "fmt"
+    "net"
+    "testing"
+    "time"
+)
+
+func TestScore(t *testing.T) {
+    tests := []struct {
+        name string
+        low  bool
+    }{
+        {"www.google.com", true},
+        {"mail.example.org", true},
+        {"weather.forecast.co", true},
+        {"xjw7qkzv3pt9rmb2.com", false},
+        {"qzxkvbnwpljhgfdtr.net", false},
+    }
+    for _, tt := range tests {
+        s := Score(tt.name)
+        if s < 0 || s > 1 {
+            t.Errorf("Score(%q) = %.2f, out of range", tt.name, s)
+        }
+        if low := s < DefaultThresholds().DGAScore; low != tt.low {
+            t.Errorf("Score(%q) = %.2f, want below threshold %v", tt.name, s, tt.low)
+        }
+    }
+    if Score("a.com") != 0 {
+        t.Error("a one-letter label scores")
+    }
+}
+
+func TestDetector(t *testing.T) {
+    base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    client := net.ParseIP("10.0.0.5")
+
+    tests := []struct {
+        name  string
+        info  Info
+        want  string
+    }{
+        {"quiet", Info{DGAScore: 0.2, MaxNameLen: 14}, ""},
+        {"dga", Info{DGAScore: 0.9, DGAName: "xjw7qkzv3pt9rmb2.com"}, SourceDGA},
+        {"tunnel payload", Info{MaxTunnelPayload: 300}, SourceTunnel},
+        {"tunnel name", Info{MaxNameLen: 120}, SourceTunnel},
+        {"nxdomain burst", Info{NXDomain: 12}, SourceNXDomain},
+        {"few nxdomain", Info{NXDomain: 3}, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            d := NewDetector(DefaultThresholds())
+            alerts := d.Check(client, &tt.info, base)
+            var got string
+            if len(alerts) > 0 {
+                got = alerts[0].Source
+            }
+            if len(alerts) > 1 || got != tt.want {
+                t.Errorf("alerts = %v, want %q", alerts, tt.want)
+            }
+        })
+    }
+}
+
+func TestNXDomainWindow(t *testing.T) {
+    base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    client := net.ParseIP("10.0.0.5")
+    d := NewDetector(DefaultThresholds())
+
+    // Nine responses a second apart stay below the burst...
+    for i := 0; i < 9; i++ {
+        if alerts := d.Check(client, &Info{NXDomain: 1}, base.Add(time.Duration(i)*time.Second)); alerts != nil {
+            t.Fatalf("alert after %d responses", i+1)
+        }
+    }
+    // ...and the tenth raises it, but not once the others expired.
+    if alerts := d.Check(client, &Info{NXDomain: 1}, base.Add(10*time.Second)); len(alerts) != 1 {
+        t.Fatalf("alerts = %v, want a burst", alerts)
+    }
+    if alerts := d.Check(client, &Info{NXDomain: 1}, base.Add(5*time.Minute)); alerts != nil {
+        t.Fatalf("alerts = %v after the window", alerts)
+    }
+}
+
+func TestPrune(t *testing.T) {
+    base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    d := NewDetector(DefaultThresholds())
+    for i := 0; i < pruneEvery; i++ {
+        client := net.ParseIP(fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+        d.Check(client, &Info{NXDomain: 1}, base.Add(time.Duration(i)*time.Second))
+    }
+    // The last sweep keeps the clients seen within the minute before it.
+    if n := len(d.nx); n > 61 {
+        t.Errorf("%d clients kept, want at most 61", n)
+    }
+}
@@ -0,0 +1,308 @@
+package features
+
+import (
+    "fmt"
+    "time"
+
+    "github.com/Tushar98644/PacketSentry/pkg/dns"
+    "github.com/Tushar98644/PacketSentry/pkg/httpinfo"
+    "github.com/Tushar98644/PacketSentry/pkg/stats"
+)
+
+// Column is one numeric feature as it is named in the features CSV, in
+// ml/parameters/features.txt and in model bundles.
+type Column struct {
+    Name    string
+    Unit    string
+    // Integer columns are written without a fractional part.
+    Integer bool
+    Value   func(FlowFeatures) float64
+}
+
+// Units used by feature columns.
+const (
+    UnitMillis  = "ms"
+    UnitPackets = "packets"
+    UnitBytes   = "bytes"
+    // UnitBits is entropy in bits per byte.
+    UnitBits    = "bits"
+    // UnitRatio is a quantity without a unit, such as a share of bytes
+    // or the skewness of a distribution.
+    UnitRatio   = "ratio"
+    // UnitScore is a heuristic score from 0 to 1.
+    UnitScore   = "score"
+    // UnitCount counts application messages, such as DNS queries or HTTP
+    // requests, of which one packet may carry several or none.
+    UnitCount   = "count"
+    // UnitHosts counts distinct hosts, and UnitHostsPerMinute is their
+    // rate.
+    UnitHosts          = "hosts"
+    UnitHostsPerMinute = "hosts/min"
+)
+
+func ms(d time.Duration) float64 {
+    return float64(d) / float64(time.Millisecond)
+}
+
+// columns is the feature table in CSV order. New features are added here
+// once and are picked up by every writer and by the model loader.
+var columns = concat(
+    flowColumns,
+    directionColumns("Fwd", func(f FlowFeatures) (stats.IntStats, stats.DurationStats) {
+        return f.FwdPacketStats, f.FwdIATStats
+    }),
+    directionColumns("Bwd", func(f FlowFeatures) (stats.IntStats, stats.DurationStats) {
+        return f.BwdPacketStats, f.BwdIATStats
+    }),
+    distributionColumns("", func(f FlowFeatures) (stats.IntStats, stats.DurationStats) {
+        return f.PacketStats, f.IATStats
+    }),
+    distributionColumns("Fwd", func(f FlowFeatures) (stats.IntStats, stats.DurationStats) {
+        return f.FwdPacketStats, f.FwdIATStats
+    }),
+    distributionColumns("Bwd", func(f FlowFeatures) (stats.IntStats, stats.DurationStats) {
+        return f.BwdPacketStats, f.BwdIATStats
+    }),
+    payloadColumns,
+    directionPayloadColumns("Fwd", func(f FlowFeatures) PayloadFeatures { return f.FwdPayload }),
+    directionPayloadColumns("Bwd", func(f FlowFeatures) PayloadFeatures { return f.BwdPayload }),
+    tcpColumns,
+    dnsColumns,
+    httpColumns,
+    hostColumns,
+)
+
+// columnIndex maps a feature name to its position in columns.
+var columnIndex = func() map[string]int {
+    idx := make(map[string]int, len(columns))
+    for i, c := range columns {
+        idx[c.Name] = i
+    }
+    return idx
+}()
+
+func concat(groups ...[]Column) []Column {
+    var all []Column
+    for _, g := range groups {
+        all = append(all, g...)
+    }
+    return all
+}
+
+// flowColumns are the whole-flow features the original model was trained on.
+var flowColumns = []Column{
+    {"Duration_ms", UnitMillis, false, func(f FlowFeatures) float64 { return ms(f.Duration) }},
+    {"PacketCount", UnitPackets, true, func(f FlowFeatures) float64 { return float64(f.PacketCount) }},
+    {"PktCount", UnitPackets, true, func(f FlowFeatures) float64 { return float64(f.PacketStats.Count) }},
+    {"PktSum", UnitBytes, true, func(f FlowFeatures) float64 { return float64(f.PacketStats.Sum) }},
+    {"PktMean", UnitBytes, false, func(f FlowFeatures) float64 { return f.PacketStats.Mean }},
+    {"PktMin", UnitBytes, true, func(f FlowFeatures) float64 { return float64(f.PacketStats.Min) }},
+    {"PktMax", UnitBytes, true, func(f FlowFeatures) float64 { return float64(f.PacketStats.Max) }},
+    {"PktStd", UnitBytes, false, func(f FlowFeatures) float64 { return f.PacketStats.Std }},
+    {"IATCount", UnitPackets, true, func(f FlowFeatures) float64 { return float64(f.IATStats.Count) }},
+    {"IATSum_ms", UnitMillis, false, func(f FlowFeatures) float64 { return ms(f.IATStats.Sum) }},
+    {"IATMean_ms", UnitMillis, false, func(f FlowFeatures) float64 { return ms(f.IATStats.Mean) }},
+    {"IATMin_ms", UnitMillis, false, func(f FlowFeatures) float64 { return ms(f.IATStats.Min) }},
+    {"IATMax_ms", UnitMillis, false, func(f FlowFeatures) float64 { return ms(f.IATStats.Max) }},
+    {"IATStd_ms", UnitMillis, false, func(f FlowFeatures) float64 { return ms(f.IATStats.Std) }},
+}
+
+// directionColumns builds the per-direction packet size and IAT columns.
+func directionColumns(dir string, get func(FlowFeatures) (stats.IntStats, stats.DurationStats)) []Column {
+    pkt := func(v func(stats.IntStats) float64) func(FlowFeatures) float64 {
+        return func(f FlowFeatures) float64 { p, _ := get(f); return v(p) }
+    }
+    iat := func(v func(stats.DurationStats) time.Duration) func(FlowFeatures) float64 {
+        return func(f FlowFeatures) float64 { _, d := get(f); return ms(v(d)) }
+    }
+    return []Column{
+        {dir + "PktCount", UnitPackets, true, pkt(func(s stats.IntStats) float64 { return float64(s.Count) })},
+        {dir + "PktSum", UnitBytes, true, pkt(func(s stats.IntStats) float64 { return float64(s.Sum) })},
+        {dir + "PktMean", UnitBytes, false, pkt(func(s stats.IntStats) float64 { return s.Mean })},
+        {dir + "PktMin", UnitBytes, true, pkt(func(s stats.IntStats) float64 { return float64(s.Min) })},
+        {dir + "PktMax", UnitBytes, true, pkt(func(s stats.IntStats) float64 { return float64(s.Max) })},
+        {dir + "PktStd", UnitBytes, false, pkt(func(s stats.IntStats) float64 { return s.Std })},
+        {dir + "IATMean_ms", UnitMillis, false, iat(func(s stats.DurationStats) time.Duration { return s.Mean })},
+        {dir + "IATMin_ms", UnitMillis, false, iat(func(s stats.DurationStats) time.Duration { return s.Min })},
+        {dir + "IATMax_ms", UnitMillis, false, iat(func(s stats.DurationStats) time.Duration { return s.Max })},
+        {dir + "IATStd_ms", UnitMillis, false, iat(func(s stats.DurationStats) time.Duration { return s.Std })},
+    }
+}
+
+// distributionColumns builds the quantile and shape columns of packet sizes
+// and IATs, for the whole flow when dir is empty. Quantiles come from a
+// sketch and are exact for flows of up to stats.DefaultSketchK packets.
+// Skewness, kurtosis and CV are ratios without a unit.
+func distributionColumns(dir string, get func(FlowFeatures) (stats.IntStats, stats.DurationStats)) []Column {
+    pkt := func(v func(stats.IntStats) float64) func(FlowFeatures) float64 {
+        return func(f FlowFeatures) float64 { p, _ := get(f); return v(p) }
+    }
+    iat := func(v func(stats.DurationStats) time.Duration) func(FlowFeatures) float64 {
+        return func(f FlowFeatures) float64 { _, d := get(f); return ms(v(d)) }
+    }
+    iatShape := func(v func(stats.DurationStats) float64) func(FlowFeatures) float64 {
+        return func(f FlowFeatures) float64 { _, d := get(f); return v(d) }
+    }
+    return []Column{
+        {dir + "PktMedian", UnitBytes, false, pkt(func(s stats.IntStats) float64 { return s.Median })},
+        {dir + "PktP25", UnitBytes, false, pkt(func(s stats.IntStats) float64 { return s.P25 })},
+        {dir + "PktP75", UnitBytes, false, pkt(func(s stats.IntStats) float64 { return s.P75 })},
+        {dir + "PktP95", UnitBytes, false, pkt(func(s stats.IntStats) float64 { return s.P95 })},
+        {dir + "PktIQR", UnitBytes, false, pkt(func(s stats.IntStats) float64 { return s.IQR })},
+        {dir + "PktSkew", UnitRatio, false, pkt(func(s stats.IntStats) float64 { return s.Skewness })},
+        {dir + "PktKurtosis", UnitRatio, false, pkt(func(s stats.IntStats) float64 { return s.Kurtosis })},
+        {dir + "PktCV", UnitRatio, false, pkt(func(s stats.IntStats) float64 { return s.CV })},
+        {dir + "IATMedian_ms", UnitMillis, false, iat(func(s stats.DurationStats) time.Duration { return s.Median })},
+        {dir + "IATP25_ms", UnitMillis, false, iat(func(s stats.DurationStats) time.Duration { return s.P25 })},
+        {dir + "IATP75_ms", UnitMillis, false, iat(func(s stats.DurationStats) time.Duration { return s.P75 })},
+        {dir + "IATP95_ms", UnitMillis, false, iat(func(s stats.DurationStats) time.Duration { return s.P95 })},
+        {dir + "IATIQR_ms", UnitMillis, false, iat(func(s stats.DurationStats) time.Duration { return s.IQR })},
+        {dir + "IATSkew", UnitRatio, false, iatShape(func(s stats.DurationStats) float64 { return s.Skewness })},
+        {dir + "IATKurtosis", UnitRatio, false, iatShape(func(s stats.DurationStats) float64 { return s.Kurtosis })},
+        {dir + "IATCV", UnitRatio, false, iatShape(func(s stats.DurationStats) float64 { return s.CV })},
+    }
+}
+
+// payloadColumns are the whole-flow application payload features.
+var payloadColumns = []Column{
+    {"PayloadSum", UnitBytes, true, func(f FlowFeatures) float64 { return float64(f.PayloadStats.Sum) }},
+    {"PayloadMean", UnitBytes, false, func(f FlowFeatures) float64 { return f.PayloadStats.Mean }},
+    {"PayloadMax", UnitBytes, true, func(f FlowFeatures) float64 { return float64(f.PayloadStats.Max) }},
+    {"PayloadStd", UnitBytes, false, func(f FlowFeatures) float64 { return f.PayloadStats.Std }},
+    {"HeaderSum", UnitBytes, true, func(f FlowFeatures) float64 { return float64(f.HeaderBytes) }},
+}
+
+// directionPayloadColumns builds the per-direction payload columns.
+func directionPayloadColumns(dir string, get func(FlowFeatures) PayloadFeatures) []Column {
+    col := func(v func(PayloadFeatures) float64) func(FlowFeatures) float64 {
+        return func(f FlowFeatures) float64 { return v(get(f)) }
+    }
+    return []Column{
+        {dir + "PayloadSum", UnitBytes, true, col(func(p PayloadFeatures) float64 { return float64(p.Stats.Sum) })},
+        {dir + "PayloadMean", UnitBytes, false, col(func(p PayloadFeatures) float64 { return p.Stats.Mean })},
+        {dir + "HeaderSum", UnitBytes, true, col(func(p PayloadFeatures) float64 { return float64(p.HeaderBytes) })},
+        {dir + "PayloadEntropy", UnitBits, false, col(func(p PayloadFeatures) float64 { return p.Entropy })},
+        {dir + "HeadEntropy", UnitBits, false, col(func(p PayloadFeatures) float64 { return p.HeadEntropy })},
+        {dir + "PrintableRatio", UnitRatio, false, col(func(p PayloadFeatures) float64 { return p.Printable })},
+    }
+}
+
+// tcpColumns are the TCP flag counts, handshake and sequence features.
+// Window sizes are the raw header values, before any window scaling.
+var tcpColumns = []Column{
+    {"SYNCount", UnitPackets, true, func(f FlowFeatures) float64 { return float64(f.Flags.SYN) }},
+    {"ACKCount", UnitPackets, true, func(f FlowFeatures) float64 { return float64(f.Flags.ACK) }},
+    {"FINCount", UnitPackets, true, func(f FlowFeatures) float64 { return float64(f.Flags.FIN) }},
+    {"RSTCount", UnitPackets, true, func(f FlowFeatures) float64 { return float64(f.Flags.RST) }},
+    {"PSHCount", UnitPackets, true, func(f FlowFeatures) float64 { return float64(f.Flags.PSH) }},
+    {"URGCount", UnitPackets, true, func(f FlowFeatures) float64 { return float64(f.Flags.URG) }},
+    {"ECECount", UnitPackets, true, func(f FlowFeatures) float64 { return float64(f.Flags.ECE) }},
+    {"CWRCount", UnitPackets, true, func(f FlowFeatures) float64 { return float64(f.Flags.CWR) }},
+    {"HandshakeRTT_ms", UnitMillis, false, func(f FlowFeatures) float64 { return ms(f.HandshakeRTT) }},
+    {"FwdInitWindow", UnitBytes, true, func(f FlowFeatures) float64 { return float64(f.FwdInitWindow) }},
+    {"BwdInitWindow", UnitBytes, true, func(f FlowFeatures) float64 { return float64(f.BwdInitWindow) }},
+    {"RetransCount", UnitPackets, true, func(f FlowFeatures) float64 { return float64(f.Retransmissions) }},
+    {"OutOfOrderCount", UnitPackets, true, func(f FlowFeatures) float64 { return float64(f.OutOfOrder) }},
+}
+
+// dnsColumns describe the DNS messages of a flow; they are zero for flows
+// without any.
+var dnsColumns = []Column{
+    {"DNSQueryCount", UnitCount, true, dnsValue(func(d *dns.Info) float64 { return float64(d.QueryCount) })},
+    {"DNSNXDomainCount", UnitCount, true, dnsValue(func(d *dns.Info) float64 { return float64(d.NXDomain) })},
+    {"DNSNameLenMax", UnitBytes, true, dnsValue(func(d *dns.Info) float64 { return float64(d.MaxNameLen) })},
+    {"DNSLabelEntropy", UnitBits, false, dnsValue(func(d *dns.Info) float64 { return dns.LabelEntropy(d.DGAName) })},
+    {"DNSDGAScore", UnitScore, false, dnsValue(func(d *dns.Info) float64 { return d.DGAScore })},
+    {"DNSTunnelPayloadMax", UnitBytes, true, dnsValue(func(d *dns.Info) float64 { return float64(d.MaxTunnelPayload) })},
+}
+
+func dnsValue(v func(*dns.Info) float64) func(FlowFeatures) float64 {
+    return func(f FlowFeatures) float64 {
+        if f.DNS == nil {
+            return 0
+        }
+        return v(f.DNS)
+    }
+}
+
+// httpColumns describe the HTTP/1.x exchanges of a flow; they are zero
+// for flows without any. Methods, URIs and statuses are only looked at in
+// the requests the flow records.
+var httpColumns = []Column{
+    {"HTTPRequestCount", UnitCount, true, httpValue(func(h *httpinfo.Info) float64 { return float64(h.RequestCount) })},
+    {"HTTPPostCount", UnitCount, true, httpValue(func(h *httpinfo.Info) float64 {
+        return float64(countRequests(h, func(r httpinfo.Request) bool { return r.Method == "POST" }))
+    })},
+    {"HTTPErrorCount", UnitCount, true, httpValue(func(h *httpinfo.Info) float64 {
+        return float64(countRequests(h, func(r httpinfo.Request) bool { return r.Status >= 400 }))
+    })},
+    {"HTTPURILenMax", UnitBytes, true, httpValue(func(h *httpinfo.Info) float64 {
+        n := 0
+        for _, r := range h.Requests {
+            n = max(n, len(r.URI))
+        }
+        return float64(n)
+    })},
+    {"HTTPReqBodySum", UnitBytes, true, httpValue(func(h *httpinfo.Info) float64 { return float64(h.BodyBytes) })},
+    {"HTTPRespBodySum", UnitBytes, true, httpValue(func(h *httpinfo.Info) float64 { return float64(h.ResponseBodyBytes) })},
+}
+
+func httpValue(v func(*httpinfo.Info) float64) func(FlowFeatures) float64 {
+    return func(f FlowFeatures) float64 {
+        if f.HTTP == nil {
+            return 0
+        }
+        return v(f.HTTP)
+    }
+}
+
+// hostColumns describe the local host of a flow rather than the flow
+// itself; they are zero when neither end is local.
+var hostColumns = []Column{
+    {"HostFanOut", UnitHosts, true, func(f FlowFeatures) float64 { return float64(f.Host.FanOut) }},
+    {"HostNewDstRate", UnitHostsPerMinute, false, func(f FlowFeatures) float64 { return f.Host.NewDstRate }},
+    {"HostBytesOutRatio", UnitRatio, false, func(f FlowFeatures) float64 { return f.Host.BytesOutRatio }},
+    {"HostUDPRatio", UnitRatio, false, func(f FlowFeatures) float64 { return f.Host.UDPRatio }},
+    {"HostTLSRatio", UnitRatio, false, func(f FlowFeatures) float64 { return f.Host.TLSRatio }},
+    {"HostDNSRatio", UnitRatio, false, func(f FlowFeatures) float64 { return f.Host.DNSRatio }},
+    {"HostHTTPRatio", UnitRatio, false, func(f FlowFeatures) float64 { return f.Host.HTTPRatio }},
+    {"HostHourRatio", UnitRatio, false, func(f FlowFeatures) float64 { return f.Host.HourRatio }},
+}
+
+func countRequests(h *httpinfo.Info, match func(httpinfo.Request) bool) int {
+    n := 0
+    for _, r := range h.Requests {
+        if match(r) {
+            n++
+        }
+    }
+    return n
+}
+
+// Columns returns every feature column in CSV order.
+func Columns() []Column {
+    return columns
+}
+
+// Units maps every feature name to its unit.
+func Units() map[string]string {
+    units := make(map[string]string, len(columns))
+    for _, c := range columns {
+        units[c.Name] = c.Unit
+    }
+    return units
+}
+
+// Vector returns the named features of ftr in the given order, as a model
+// expects them.
+func (ftr FlowFeatures) Vector(names []string) ([]float64, error) {
+    vec := make([]float64, len(names))
+    for i, n := range names {
+        c, ok := columnIndex[n]
+        if !ok {
+            return nil, fmt.Errorf("unknown feature %q", n)
+        }
+        vec[i] = columns[c].Value(ftr)
+    }
+    return vec, nil
+}
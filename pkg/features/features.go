@@ -4,8 +4,11 @@ import (
     "time"
     "net"
 
+    "github.com/Tushar98644/PacketSentry/pkg/dns"
     "github.com/Tushar98644/PacketSentry/pkg/flow"
+    "github.com/Tushar98644/PacketSentry/pkg/httpinfo"
     "github.com/Tushar98644/PacketSentry/pkg/stats"
+    "github.com/Tushar98644/PacketSentry/pkg/tlsfp"
 )
 
 type FlowFeatures struct {
@@ -15,12 +18,92 @@ type FlowFeatures struct {
     DstPort    uint16
     Protocol   string
 
+    // Start and End are the timestamps of the flow's first and last packet.
+    Start      time.Time
+    End        time.Time
+    // EndReason says why the flow was exported; see the flow.End* constants.
+    EndReason  string
+
     Duration time.Duration 
 
     PacketCount int
 
     PacketStats stats.IntStats
     IATStats    stats.DurationStats
+
+    // Forward is initiator -> responder, backward is responder -> initiator.
+    FwdPacketStats stats.IntStats
+    BwdPacketStats stats.IntStats
+    FwdIATStats    stats.DurationStats
+    BwdIATStats    stats.DurationStats
+
+    // Payload features: application payload lengths, with headers of all
+    // layers below counted apart, for the whole flow and each direction.
+    PayloadStats stats.IntStats
+    HeaderBytes  int
+    FwdPayload   PayloadFeatures
+    BwdPayload   PayloadFeatures
+
+    // TLS fingerprints the TLS handshake, nil for flows without one.
+    TLS *tlsfp.Fingerprint
+    // DNS holds the flow's DNS messages, nil for flows without any.
+    DNS *dns.Info
+    // HTTP holds the flow's HTTP/1.x exchanges, nil for flows without any.
+    HTTP *httpinfo.Info
+
+    // TCP header features, zero for other protocols. Flags counts both
+    // directions; HandshakeRTT is zero when no handshake was seen.
+    Flags           flow.TCPFlags
+    HandshakeRTT    time.Duration
+    FwdInitWindow   int
+    BwdInitWindow   int
+    Retransmissions int
+    OutOfOrder      int
+
+    // Host describes the flow's local end; FromFlow leaves it zero and
+    // host.Tracker fills it in.
+    Host HostFeatures
+}
+
+// HostFeatures describe a local host as profiled up to and including a
+// flow; see the host package. They are zero when neither end is local.
+type HostFeatures struct {
+    // FanOut is the number of distinct destinations the host contacted
+    // within the profile window, and NewDstRate how many of them per
+    // minute were first contacts.
+    FanOut        int
+    NewDstRate    float64
+    // BytesOutRatio is the share of the host's bytes it sent.
+    BytesOutRatio float64
+    // Shares of the host's flows over UDP, with TLS, DNS or HTTP, and
+    // started in the same hour of the day as this one.
+    UDPRatio      float64
+    TLSRatio      float64
+    DNSRatio      float64
+    HTTPRatio     float64
+    HourRatio     float64
+}
+
+// PayloadFeatures describe the application payload sent in one direction.
+// Entropy is in bits per byte; HeadEntropy covers only the first
+// flow.HeadBytes bytes, where handshakes and protocol banners are.
+type PayloadFeatures struct {
+    Stats       stats.IntStats
+    HeaderBytes int
+    Entropy     float64
+    HeadEntropy float64
+    // Printable is the share of payload bytes that are printable ASCII.
+    Printable   float64
+}
+
+func payloadFeatures(d *flow.Direction) PayloadFeatures {
+    return PayloadFeatures{
+        Stats:       d.PayloadSizes.IntStats(),
+        HeaderBytes: d.HeaderBytes,
+        Entropy:     d.Bytes.Entropy(),
+        HeadEntropy: stats.Entropy(d.Head),
+        Printable:   d.Bytes.Printable(),
+    }
 }
 
 func FromFlow(f *flow.Flow) FlowFeatures {
@@ -32,9 +115,9 @@ func FromFlow(f *flow.Flow) FlowFeatures {
 
     duration := f.LastSeen.Sub(f.FirstSeen)
 
-    pktStats := stats.ComputeIntStats(f.PacketSizes)
+    pktStats := f.PacketSizes.IntStats()
 
-    iatStats := stats.ComputeDurationStats(f.IATs)
+    iatStats := f.IATs.DurationStats()
 
     return FlowFeatures{
         SrcIP:      srcIP,
@@ -42,9 +125,33 @@ func FromFlow(f *flow.Flow) FlowFeatures {
         SrcPort:    srcPort,
         DstPort:    dstPort,
         Protocol:   protocol,
+        Start:      f.FirstSeen,
+        End:        f.LastSeen,
+        EndReason:  f.EndReason,
         Duration:    duration,
         PacketCount: f.PacketCount,
         PacketStats: pktStats,
         IATStats:    iatStats,
+
+        FwdPacketStats: f.Fwd.PacketSizes.IntStats(),
+        BwdPacketStats: f.Bwd.PacketSizes.IntStats(),
+        FwdIATStats:    f.Fwd.IATs.DurationStats(),
+        BwdIATStats:    f.Bwd.IATs.DurationStats(),
+
+        PayloadStats: f.PayloadSizes.IntStats(),
+        HeaderBytes:  f.HeaderBytes,
+        FwdPayload:   payloadFeatures(&f.Fwd),
+        BwdPayload:   payloadFeatures(&f.Bwd),
+
+        TLS: f.TLS,
+        DNS: f.DNS,
+        HTTP: f.HTTP,
+
+        Flags:           f.Fwd.TCP.Flags.Add(f.Bwd.TCP.Flags),
+        HandshakeRTT:    f.HandshakeRTT,
+        FwdInitWindow:   f.Fwd.TCP.InitWindow,
+        BwdInitWindow:   f.Bwd.TCP.InitWindow,
+        Retransmissions: f.Fwd.TCP.Retransmissions + f.Bwd.TCP.Retransmissions,
+        OutOfOrder:      f.Fwd.TCP.OutOfOrder + f.Bwd.TCP.OutOfOrder,
     }
 }
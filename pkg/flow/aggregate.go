@@ -1,15 +1,21 @@
 package flow
 
 import (
+    "strconv"
     "strings"
     "time"
 
     "github.com/google/gopacket"
     "github.com/google/gopacket/layers"
+    "github.com/google/gopacket/reassembly"
+
+    "github.com/Tushar98644/PacketSentry/pkg/dns"
 )
 
-// extractKey builds the 5-tuple string key and returns it plus its parts.
-func extractKey(pkt gopacket.Packet) (key string, parts []string, timestamp time.Time) {
+// extractKey builds the bidirectional flow key and returns it plus the
+// 5-tuple parts as seen in this packet's direction. ok is false for
+// packets without an IP layer, such as ARP, which do not belong to a flow.
+func extractKey(pkt gopacket.Packet) (key string, parts []string, timestamp time.Time, ok bool) {
     timestamp = pkt.Metadata().Timestamp
 
     // Get IPv4 or IPv6 layer
@@ -25,53 +31,280 @@ func extractKey(pkt gopacket.Packet) (key string, parts []string, timestamp time
     } else if ip6 := pkt.Layer(layers.LayerTypeIPv6); ip6 != nil {
         v6 := ip6.(*layers.IPv6)
         srcIP, dstIP = v6.SrcIP.String(), v6.DstIP.String()
+    } else {
+        return
     }
 
     // Get transport layer
     if tcp := pkt.Layer(layers.LayerTypeTCP); tcp != nil {
         t := tcp.(*layers.TCP)
         proto = "TCP"
-        srcPort, dstPort = t.SrcPort.String(), t.DstPort.String()
+        srcPort, dstPort = strconv.Itoa(int(t.SrcPort)), strconv.Itoa(int(t.DstPort))
     } else if udp := pkt.Layer(layers.LayerTypeUDP); udp != nil {
         u := udp.(*layers.UDP)
         proto = "UDP"
-        srcPort, dstPort = u.SrcPort.String(), u.DstPort.String()
+        srcPort, dstPort = strconv.Itoa(int(u.SrcPort)), strconv.Itoa(int(u.DstPort))
     }
 
     parts = []string{srcIP, dstIP, proto, srcPort, dstPort}
-    key = strings.Join(parts, "-")
+    key = canonicalKey(parts)
+    ok = true
     return
 }
 
-// Aggregate reads packets from ch, groups them into flows, and returns them.
-func Aggregate(ch <-chan gopacket.Packet) []*Flow {
-    flows := make(map[string]*Flow)
-
-    for pkt := range ch {
-        key, parts, ts := extractKey(pkt)
-        size := len(pkt.Data())
-
-        if f, exists := flows[key]; !exists {
-            // first packet of this flow
-            flows[key] = newFlow(pkt, parts)
-        } else {
-            // update existing flow
-            f.PacketCount++
-            f.ByteCount += size
-
-            // compute inter-arrival time
-            iat := ts.Sub(f.LastSeen)
-            f.IATs = append(f.IATs, iat)
-
-            f.PacketSizes = append(f.PacketSizes, size)
-            f.LastSeen = ts
-        }
+// canonicalKey orders the two endpoints of a 5-tuple so that both
+// directions of a conversation map to the same key.
+func canonicalKey(parts []string) string {
+    a := []string{parts[0], parts[3]}
+    b := []string{parts[1], parts[4]}
+    if b[0] < a[0] || (b[0] == a[0] && b[1] < a[1]) {
+        a, b = b, a
     }
+    return strings.Join([]string{a[0], b[0], parts[2], a[1], b[1]}, "-")
+}
+
+// tcpLayer returns the packet's TCP header, or nil for non-TCP packets.
+func tcpLayer(pkt gopacket.Packet) *layers.TCP {
+    if tcp := pkt.Layer(layers.LayerTypeTCP); tcp != nil {
+        return tcp.(*layers.TCP)
+    }
+    return nil
+}
+
+// dnsLayer returns the packet's DNS message, or nil if it has none.
+// gopacket also decodes mDNS this way, which add leaves out.
+func dnsLayer(pkt gopacket.Packet) *layers.DNS {
+    if msg := pkt.Layer(layers.LayerTypeDNS); msg != nil {
+        return msg.(*layers.DNS)
+    }
+    return nil
+}
+
+// payload returns the packet's application payload, or nil if it has none.
+// It is taken from the transport layer: for the UDP protocols gopacket
+// decodes itself, such as DNS, the application layer has no payload.
+func payload(pkt gopacket.Packet) []byte {
+    if tl := pkt.TransportLayer(); tl != nil {
+        return tl.LayerPayload()
+    }
+    return nil
+}
+
+// sweepInterval is how often, in packet or wall-clock time, the
+// aggregator looks for flows that have timed out or closed.
+const sweepInterval = time.Second
+
+// Options controls when flows are considered finished and exported.
+// A zero timeout disables that kind of expiry.
+type Options struct {
+    // IdleTimeout exports a flow once no packet has been seen for this long.
+    IdleTimeout   time.Duration
+    // ActiveTimeout exports a flow once it has been open this long, even if
+    // it is still busy; later packets start a new flow.
+    ActiveTimeout time.Duration
+    // WallClock also expires flows by wall-clock time, so that live
+    // captures export idle flows while no packets are arriving.
+    WallClock     bool
+}
 
-    // convert map to slice
-    result := make([]*Flow, 0, len(flows))
-    for _, f := range flows {
+// aggregator holds the flow table of a running AggregateStream.
+type aggregator struct {
+    opts      Options
+    flows     map[string]*Flow
+    out       chan<- *Flow
+    lastSweep time.Time
+    // assembler reassembles TCP streams for the application parsers.
+    assembler *reassembly.Assembler
+}
+
+// Aggregate reads packets from ch, groups them into bidirectional flows,
+// and returns them once ch is closed. The initiator of a flow is the
+// sender of its first packet, unless a TCP SYN says otherwise; a TCP flow
+// whose handshake was not seen is taken to be initiated from the higher
+// port.
+func Aggregate(ch <-chan gopacket.Packet) []*Flow {
+    var result []*Flow
+    for f := range AggregateStream(ch, Options{}) {
         result = append(result, f)
     }
     return result
 }
+
+// AggregateStream groups packets from ch into bidirectional flows and sends
+// each flow on the returned channel as soon as it is finished: shortly after
+// a TCP RST or a FIN from both sides, or when it hits one of the timeouts in
+// opts.
+// Flows still open when ch is closed are flushed before the returned
+// channel is closed.
+func AggregateStream(ch <-chan gopacket.Packet, opts Options) <-chan *Flow {
+    out := make(chan *Flow)
+    a := &aggregator{
+        opts:  opts,
+        flows: make(map[string]*Flow),
+        out:   out,
+    }
+    a.assembler = reassembly.NewAssembler(reassembly.NewStreamPool(&streamFactory{a: a}))
+    a.assembler.MaxBufferedPagesPerConnection = maxPagesPerConnection
+    a.assembler.MaxBufferedPagesTotal = maxPagesTotal
+
+    go func() {
+        defer close(out)
+
+        var tick <-chan time.Time
+        if opts.WallClock {
+            t := time.NewTicker(sweepInterval)
+            defer t.Stop()
+            tick = t.C
+        }
+
+        for {
+            select {
+            case pkt, ok := <-ch:
+                if !ok {
+                    a.flush()
+                    return
+                }
+                a.add(pkt)
+            case now := <-tick:
+                a.sweep(now)
+            }
+        }
+    }()
+    return out
+}
+
+// add records one packet, exporting the flow it belongs to first if that
+// flow has already timed out.
+func (a *aggregator) add(pkt gopacket.Packet) {
+    key, parts, ts, ok := extractKey(pkt)
+    if !ok {
+        return
+    }
+    size := len(pkt.Data())
+    tcp := tcpLayer(pkt)
+
+    f, exists := a.flows[key]
+    if exists {
+        if reason := a.expired(f, ts); reason != "" {
+            a.export(key, f, reason)
+            exists = false
+        }
+    }
+    if !exists {
+        // first packet of this flow
+        f = newFlow(pkt, parts)
+        a.flows[key] = f
+        if tcp != nil && !tcp.SYN && f.SrcPort < f.DstPort {
+            // The middle of a connection, e.g. after a timeout or with the
+            // capture started late: the lower port, usually the
+            // well-known one, is the server's.
+            f.reverse()
+        }
+    }
+
+    forward := f.isForward(parts)
+    if tcp != nil && tcp.SYN && !f.sawSYN {
+        // A SYN comes from the initiator, a SYN-ACK from the responder.
+        f.sawSYN = true
+        if forward == tcp.ACK {
+            f.reverse()
+            forward = !forward
+        }
+    }
+
+    d := &f.Bwd
+    if forward {
+        d = &f.Fwd
+    }
+    f.add(d, ts, size, payload(pkt))
+
+    if msg := dnsLayer(pkt); msg != nil && (f.SrcPort == 53 || f.DstPort == 53) {
+        if f.DNS == nil {
+            f.DNS = &dns.Info{}
+        }
+        f.DNS.Add(msg)
+    }
+
+    if tcp != nil {
+        f.addTCP(d, forward, tcp, ts)
+        if f.HTTP == nil || !f.HTTP.Done() || !f.helloDone() {
+            pc := &packetContext{ci: pkt.Metadata().CaptureInfo, key: key, parts: parts}
+            a.assembler.AssembleWithContext(pkt.NetworkLayer().NetworkFlow(), tcp, pc)
+        }
+        if tcp.RST {
+            f.rst = true
+        } else if tcp.FIN {
+            if forward {
+                f.finFwd = true
+            } else {
+                f.finBwd = true
+            }
+        }
+    }
+
+    if ts.Sub(a.lastSweep) >= sweepInterval {
+        a.sweep(ts)
+    }
+}
+
+// expired returns why f should be exported at time now, or "" if it is
+// still live. Closed TCP flows are held for one sweep interval so that the
+// final ACK of the teardown, or a burst of trailing RSTs, stays in the same
+// flow.
+func (a *aggregator) expired(f *Flow, now time.Time) string {
+    switch {
+    case f.rst && now.Sub(f.LastSeen) >= sweepInterval:
+        return EndRST
+    case f.finFwd && f.finBwd && now.Sub(f.LastSeen) >= sweepInterval:
+        return EndFIN
+    case a.opts.IdleTimeout > 0 && now.Sub(f.LastSeen) >= a.opts.IdleTimeout:
+        return EndIdle
+    case a.opts.ActiveTimeout > 0 && now.Sub(f.FirstSeen) >= a.opts.ActiveTimeout:
+        return EndActive
+    }
+    return ""
+}
+
+// streamTimeout is how long the reassembler keeps an idle TCP stream when
+// flows have no idle timeout.
+const streamTimeout = 2 * time.Minute
+
+// sweep exports every flow that has closed or timed out by now. Data held
+// back for a missing segment is given up on after a sweep interval, so
+// that it reaches its flow before the flow is exported.
+func (a *aggregator) sweep(now time.Time) {
+    a.lastSweep = now
+    idle := a.opts.IdleTimeout
+    if idle <= 0 {
+        idle = streamTimeout
+    }
+    a.assembler.FlushWithOptions(reassembly.FlushOptions{T: now.Add(-sweepInterval), TC: now.Add(-idle)})
+    for key, f := range a.flows {
+        if reason := a.expired(f, now); reason != "" {
+            a.export(key, f, reason)
+        }
+    }
+}
+
+// flush exports every remaining flow at the end of the packet stream.
+func (a *aggregator) flush() {
+    a.assembler.FlushAll()
+    for key, f := range a.flows {
+        reason := EndEOF
+        if f.rst {
+            reason = EndRST
+        } else if f.finFwd && f.finBwd {
+            reason = EndFIN
+        }
+        a.export(key, f, reason)
+    }
+}
+
+func (a *aggregator) export(key string, f *Flow, reason string) {
+    delete(a.flows, key)
+    f.EndReason = reason
+    if f.HTTP != nil && !f.HTTP.Seen() {
+        f.HTTP = nil
+    }
+    a.out <- f
+}
@@ -0,0 +1,238 @@
+package flow
+
+import (
+    "fmt"
+    "net"
+    "sort"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/google/gopacket"
+    "github.com/google/gopacket/layers"
+)
+
+// packet builds an IPv4 packet from src to dst, both "ip:port", at
+// base+offset ms. For TCP, flags lists the flags set, such as "SA" for a
+// SYN-ACK; UDP ignores it.
+func packet(t *testing.T, proto, src, dst, flags string, offset int) gopacket.Packet {
+    t.Helper()
+    endpoint := func(s string) (net.IP, int) {
+        host, port, err := net.SplitHostPort(s)
+        if err != nil {
+            t.Fatal(err)
+        }
+        p, err := strconv.Atoi(port)
+        if err != nil {
+            t.Fatal(err)
+        }
+        return net.ParseIP(host).To4(), p
+    }
+    srcIP, sport := endpoint(src)
+    dstIP, dport := endpoint(dst)
+
+    eth := &layers.Ethernet{SrcMAC: net.HardwareAddr{0, 0, 0, 0, 0, 1}, DstMAC: net.HardwareAddr{0, 0, 0, 0, 0, 2}, EthernetType: layers.EthernetTypeIPv4}
+    ip := &layers.IPv4{Version: 4, TTL: 64, SrcIP: srcIP, DstIP: dstIP}
+    var transport gopacket.SerializableLayer
+    switch proto {
+    case "TCP":
+        ip.Protocol = layers.IPProtocolTCP
+        tcp := &layers.TCP{SrcPort: layers.TCPPort(sport), DstPort: layers.TCPPort(dport), Window: 65535,
+            SYN: strings.Contains(flags, "S"), ACK: strings.Contains(flags, "A"),
+            FIN: strings.Contains(flags, "F"), RST: strings.Contains(flags, "R")}
+        tcp.SetNetworkLayerForChecksum(ip)
+        transport = tcp
+    case "UDP":
+        ip.Protocol = layers.IPProtocolUDP
+        udp := &layers.UDP{SrcPort: layers.UDPPort(sport), DstPort: layers.UDPPort(dport)}
+        udp.SetNetworkLayerForChecksum(ip)
+        transport = udp
+    default:
+        t.Fatalf("unknown protocol %q", proto)
+    }
+    buf := gopacket.NewSerializeBuffer()
+    opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+    if err := gopacket.SerializeLayers(buf, opts, eth, ip, transport, gopacket.Payload("data")); err != nil {
+        t.Fatal(err)
+    }
+    pkt := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+    md := pkt.Metadata()
+    md.Timestamp = time.Unix(1000, 0).Add(time.Duration(offset) * time.Millisecond)
+    md.CaptureLength, md.Length = len(buf.Bytes()), len(buf.Bytes())
+    return pkt
+}
+
+// feed returns a closed channel holding pkts.
+func feed(pkts ...gopacket.Packet) <-chan gopacket.Packet {
+    ch := make(chan gopacket.Packet, len(pkts))
+    for _, p := range pkts {
+        ch <- p
+    }
+    close(ch)
+    return ch
+}
+
+// endpoints formats a flow's initiator and responder as "ip:port -> ip:port".
+func endpoints(f *Flow) string {
+    return fmt.Sprintf("%s -> %s", net.JoinHostPort(f.SrcIP.String(), strconv.Itoa(int(f.SrcPort))),
+        net.JoinHostPort(f.DstIP.String(), strconv.Itoa(int(f.DstPort))))
+}
+
+func TestCanonicalKey(t *testing.T) {
+    tuples := [][]string{
+        {"10.0.0.1", "10.0.0.2", "TCP", "40000", "80"},
+        {"10.0.0.1", "10.0.0.2", "UDP", "40000", "80"},
+        {"10.0.0.1", "10.0.0.2", "TCP", "40001", "80"},
+        {"10.0.0.1", "10.0.0.20", "TCP", "40000", "80"},
+        {"10.0.0.1", "10.0.0.1", "TCP", "40000", "80"},
+        {"2001:db8::1", "2001:db8::2", "TCP", "40000", "443"},
+        {"10.0.0.1", "10.0.0.2", "", "", ""},
+    }
+    seen := make(map[string][]string)
+    for _, p := range tuples {
+        key := canonicalKey(p)
+        reversed := []string{p[1], p[0], p[2], p[4], p[3]}
+        if k := canonicalKey(reversed); k != key {
+            t.Errorf("canonicalKey(%v) = %q, reversed %q", p, key, k)
+        }
+        if other, ok := seen[key]; ok {
+            t.Errorf("%v and %v share key %q", p, other, key)
+        }
+        seen[key] = p
+    }
+}
+
+func TestInitiator(t *testing.T) {
+    const client, server = "10.0.0.1:40000", "10.0.0.2:80"
+    const outbound = client + " -> " + server
+    tcp := func(from, to, flags string, offset int) gopacket.Packet {
+        return packet(t, "TCP", from, to, flags, offset)
+    }
+    tests := []struct {
+        name     string
+        pkts     []gopacket.Packet
+        flow     string
+        fwd, bwd int
+    }{
+        {"handshake", []gopacket.Packet{
+            tcp(client, server, "S", 0), tcp(server, client, "SA", 1), tcp(client, server, "A", 2),
+        }, outbound, 2, 1},
+        {"SYN-ACK first", []gopacket.Packet{
+            tcp(server, client, "SA", 0), tcp(client, server, "A", 1), tcp(server, client, "A", 2),
+        }, outbound, 1, 2},
+        {"SYN after a stray reply", []gopacket.Packet{
+            tcp(server, client, "A", 0), tcp(client, server, "S", 1), tcp(server, client, "SA", 2),
+        }, outbound, 1, 2},
+        {"retransmitted SYN-ACK", []gopacket.Packet{
+            tcp(client, server, "S", 0), tcp(server, client, "SA", 1), tcp(server, client, "SA", 2),
+        }, outbound, 1, 2},
+        {"no handshake, server first", []gopacket.Packet{
+            tcp(server, client, "A", 0), tcp(client, server, "A", 1),
+        }, outbound, 1, 1},
+        {"no handshake, client first", []gopacket.Packet{
+            tcp(client, server, "A", 0), tcp(server, client, "A", 1),
+        }, outbound, 1, 1},
+        {"no handshake, same port", []gopacket.Packet{
+            tcp("10.0.0.2:40000", client, "A", 0), tcp(client, "10.0.0.2:40000", "A", 1),
+        }, "10.0.0.2:40000 -> " + client, 1, 1},
+        {"server first, then SYN-ACK", []gopacket.Packet{
+            tcp(server, client, "A", 0), tcp(server, client, "SA", 1), tcp(client, server, "A", 2),
+        }, outbound, 1, 2},
+        {"UDP", []gopacket.Packet{
+            packet(t, "UDP", client, "10.0.0.2:9999", "", 0), packet(t, "UDP", "10.0.0.2:9999", client, "", 1),
+        }, client + " -> 10.0.0.2:9999", 1, 1},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            flows := Aggregate(feed(tt.pkts...))
+            if len(flows) != 1 {
+                t.Fatalf("%d flows, want 1", len(flows))
+            }
+            f := flows[0]
+            if got := endpoints(f); got != tt.flow {
+                t.Errorf("flow %s, want %s", got, tt.flow)
+            }
+            if f.Fwd.PacketCount != tt.fwd || f.Bwd.PacketCount != tt.bwd {
+                t.Errorf("%d packets forward, %d backward, want %d, %d", f.Fwd.PacketCount, f.Bwd.PacketCount, tt.fwd, tt.bwd)
+            }
+        })
+    }
+
+    // A connection that outlives the idle timeout continues in a new flow
+    // with the same initiator, even when the server speaks first.
+    var got []string
+    for f := range AggregateStream(feed(
+        tcp(client, server, "S", 0), tcp(server, client, "SA", 1), tcp(client, server, "A", 2),
+        tcp(server, client, "A", 20000), tcp(client, server, "A", 20001),
+    ), Options{IdleTimeout: 10 * time.Second}) {
+        got = append(got, endpoints(f))
+    }
+    if len(got) != 2 || got[0] != outbound || got[1] != outbound {
+        t.Errorf("flows %q, want two of %s", got, outbound)
+    }
+}
+
+func TestEviction(t *testing.T) {
+    const client, server, other = "10.0.0.1:40000", "10.0.0.2:80", "10.0.0.3:40000"
+    tcp := func(from, to, flags string, offset int) gopacket.Packet {
+        return packet(t, "TCP", from, to, flags, offset)
+    }
+    udp := func(offset int) gopacket.Packet {
+        return packet(t, "UDP", client, "10.0.0.2:9999", "", offset)
+    }
+    handshake := []gopacket.Packet{tcp(client, server, "S", 0), tcp(server, client, "SA", 10), tcp(client, server, "A", 20)}
+
+    // exported describes a flow by its packet count and end reason.
+    type exported struct {
+        packets int
+        reason  string
+    }
+    tests := []struct {
+        name string
+        opts Options
+        pkts []gopacket.Packet
+        want []exported
+    }{
+        {"idle", Options{IdleTimeout: 10 * time.Second},
+            []gopacket.Packet{udp(0), udp(1000), udp(12000), udp(13000)},
+            []exported{{2, EndIdle}, {2, EndEOF}}},
+        {"idle disabled", Options{},
+            []gopacket.Packet{udp(0), udp(1000), udp(600000)},
+            []exported{{3, EndEOF}}},
+        {"active", Options{ActiveTimeout: 5 * time.Second},
+            []gopacket.Packet{udp(0), udp(1000), udp(2000), udp(3000), udp(4000), udp(5000), udp(6000), udp(7000)},
+            []exported{{5, EndActive}, {3, EndEOF}}},
+        {"FIN", Options{IdleTimeout: time.Minute},
+            append(handshake, tcp(client, server, "FA", 100), tcp(server, client, "FA", 110),
+                tcp(client, server, "A", 120), tcp(other, server, "S", 3000)),
+            []exported{{6, EndFIN}, {1, EndEOF}}},
+        {"FIN from one side", Options{},
+            append(handshake, tcp(client, server, "FA", 100), tcp(other, server, "S", 3000)),
+            []exported{{4, EndEOF}, {1, EndEOF}}},
+        {"RST", Options{},
+            append(handshake, tcp(server, client, "R", 100), tcp(server, client, "R", 150),
+                tcp(other, server, "S", 3000)),
+            []exported{{5, EndRST}, {1, EndEOF}}},
+        {"reused after RST", Options{},
+            append(handshake, tcp(server, client, "R", 100), tcp(client, server, "S", 3000)),
+            []exported{{4, EndRST}, {1, EndEOF}}},
+        {"FIN at end of capture", Options{},
+            append(handshake, tcp(client, server, "FA", 100), tcp(server, client, "FA", 110)),
+            []exported{{5, EndFIN}}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var got []exported
+            for f := range AggregateStream(feed(tt.pkts...), tt.opts) {
+                got = append(got, exported{f.PacketCount, f.EndReason})
+            }
+            // Flows left at the end of the capture are flushed in no
+            // particular order.
+            sort.Slice(got, func(i, j int) bool { return got[i].packets > got[j].packets })
+            if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+                t.Errorf("exported %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
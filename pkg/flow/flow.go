@@ -6,9 +6,43 @@ import (
     "time"
 
     "github.com/google/gopacket"
+
+    "github.com/Tushar98644/PacketSentry/pkg/dns"
+    "github.com/Tushar98644/PacketSentry/pkg/httpinfo"
+    "github.com/Tushar98644/PacketSentry/pkg/stats"
+    "github.com/Tushar98644/PacketSentry/pkg/tlsfp"
 )
 
-// Flow holds per-flow stats and raw data for feature computation.
+// HeadBytes is how much of each direction's application payload is kept
+// for features of the start of a conversation.
+const HeadBytes = 64
+
+// Direction holds the per-direction share of a flow's packets.
+type Direction struct {
+    PacketCount int
+    ByteCount   int
+    // PacketSizes, IATs (in nanoseconds) and PayloadSizes summarize the
+    // packets as they arrive, so that long flows take no more memory than
+    // short ones. PayloadSizes are the application payload lengths;
+    // HeaderBytes is everything else, from the link layer to the
+    // transport header.
+    PacketSizes  stats.Accumulator
+    IATs         stats.Accumulator
+    PayloadSizes stats.Accumulator
+    HeaderBytes  int
+    // Bytes counts the payload byte values and Head keeps the first
+    // HeadBytes bytes of payload.
+    Bytes       stats.ByteHistogram
+    Head        []byte
+    FirstSeen   time.Time
+    LastSeen    time.Time
+    // TCP is left zero for other protocols.
+    TCP         TCPState
+}
+
+// Flow holds per-flow stats for feature computation.
+// Src is the initiator of the conversation; Fwd covers packets sent by the
+// initiator and Bwd covers the responder's replies.
 type Flow struct {
     SrcIP        net.IP
     DstIP        net.IP
@@ -19,23 +53,70 @@ type Flow struct {
     LastSeen     time.Time
     PacketCount  int
     ByteCount    int
-    PacketSizes  []int
-    IATs         []time.Duration
+    PacketSizes  stats.Accumulator
+    IATs         stats.Accumulator
+    PayloadSizes stats.Accumulator
+    HeaderBytes  int
+
+    Fwd          Direction
+    Bwd          Direction
+
+    // EndReason says why the flow was exported; one of the End* constants.
+    EndReason    string
+
+    // HandshakeRTT is the time from the initiator's SYN to the ACK that
+    // completes the handshake, or zero if no handshake was seen.
+    HandshakeRTT time.Duration
+    // TLS fingerprints the flow's TLS handshake, nil for flows without one.
+    TLS          *tlsfp.Fingerprint
+    // DNS records the DNS messages of flows to or from port 53, nil for
+    // other flows.
+    DNS          *dns.Info
+    // HTTP records the HTTP/1.x exchanges of a TCP flow, nil for flows
+    // without any.
+    HTTP         *httpinfo.Info
+
+    // sawSYN records whether the initiator was fixed by a TCP SYN.
+    sawSYN       bool
+    // finFwd and finBwd record a TCP FIN from each side.
+    finFwd       bool
+    finBwd       bool
+    // rst records a TCP RST from either side.
+    rst          bool
+    // synAt is when the initiator's SYN was seen, and synAckSeen whether
+    // the responder has answered it.
+    synAt        time.Time
+    synAckSeen   bool
+    // clientHello and serverHello buffer each side's stream until its TLS
+    // hello is parsed into client or server.
+    clientHello  helloBuffer
+    serverHello  helloBuffer
+    client       *tlsfp.ClientHello
+    server       *tlsfp.ServerHello
 }
 
+// Reasons a flow was exported by the aggregator.
+const (
+    EndIdle   = "idle"
+    EndActive = "active"
+    EndFIN    = "fin"
+    EndRST    = "rst"
+    EndEOF    = "eof"
+)
+
 // parsePort turns a port string (“80”) into uint16.
 func parsePort(s string) uint16 {
     p, _ := strconv.Atoi(s)
     return uint16(p)
 }
 
-// newFlow initializes a Flow from the very first packet.
+// newFlow initializes an empty Flow from the very first packet; the packet
+// itself is recorded by add.
 func newFlow(pkt gopacket.Packet, keyParts []string) *Flow {
     // keyParts: [srcIP, dstIP, proto, srcPort, dstPort]
     now := pkt.Metadata().Timestamp
-    size := len(pkt.Data())
 
-    return &Flow{
+    f := &Flow{
         SrcIP:       net.ParseIP(keyParts[0]),
         DstIP:       net.ParseIP(keyParts[1]),
         Protocol:    keyParts[2],
@@ -43,9 +124,56 @@ func newFlow(pkt gopacket.Packet, keyParts []string) *Flow {
         DstPort:     parsePort(keyParts[4]),
         FirstSeen:   now,
         LastSeen:    now,
-        PacketCount: 1,
-        ByteCount:   size,
-        PacketSizes: []int{size},
-        IATs:        nil,
     }
+    // Sizes and IATs also feed quantile sketches; payload sizes only need
+    // the moments.
+    for _, a := range []*stats.Accumulator{&f.PacketSizes, &f.IATs, &f.Fwd.PacketSizes,
+        &f.Fwd.IATs, &f.Bwd.PacketSizes, &f.Bwd.IATs} {
+        a.Sketch = stats.NewSketch(stats.DefaultSketchK)
+    }
+    return f
+}
+
+// isForward reports whether a packet with the given key parts was sent by
+// the flow's initiator.
+func (f *Flow) isForward(keyParts []string) bool {
+    return f.SrcIP.Equal(net.ParseIP(keyParts[0])) && f.SrcPort == parsePort(keyParts[3])
+}
+
+// reverse swaps initiator and responder, used when a SYN or the ports show
+// that the first packet seen actually came from the responder.
+func (f *Flow) reverse() {
+    f.SrcIP, f.DstIP = f.DstIP, f.SrcIP
+    f.SrcPort, f.DstPort = f.DstPort, f.SrcPort
+    f.Fwd, f.Bwd = f.Bwd, f.Fwd
+}
+
+// add records one packet of size bytes, payload being its application
+// payload, in the flow totals and in the given direction.
+func (f *Flow) add(d *Direction, ts time.Time, size int, payload []byte) {
+    if f.PacketCount > 0 {
+        f.IATs.Add(float64(ts.Sub(f.LastSeen)))
+    }
+    f.PacketCount++
+    f.ByteCount += size
+    f.PacketSizes.Add(float64(size))
+    f.PayloadSizes.Add(float64(len(payload)))
+    f.HeaderBytes += size - len(payload)
+    f.LastSeen = ts
+
+    if d.PacketCount == 0 {
+        d.FirstSeen = ts
+    } else {
+        d.IATs.Add(float64(ts.Sub(d.LastSeen)))
+    }
+    d.PacketCount++
+    d.ByteCount += size
+    d.PacketSizes.Add(float64(size))
+    d.PayloadSizes.Add(float64(len(payload)))
+    d.HeaderBytes += size - len(payload)
+    d.Bytes.Add(payload)
+    if n := HeadBytes - len(d.Head); n > 0 && len(payload) > 0 {
+        d.Head = append(d.Head, payload[:min(n, len(payload))]...)
+    }
+    d.LastSeen = ts
 }
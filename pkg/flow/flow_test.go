@@ -0,0 +1,96 @@
+package flow
+
+import (
+    "os"
+    "testing"
+    "time"
+
+    "github.com/google/gopacket"
+    "github.com/google/gopacket/pcapgo"
+)
+
+// readFixture aggregates the flows of a capture in packets/test.
+func readFixture(t *testing.T, name string) []*Flow {
+    t.Helper()
+    f, err := os.Open("../../packets/test/" + name)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+    r, err := pcapgo.NewReader(f)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return Aggregate(gopacket.NewPacketSource(r, r.LinkType()).Packets())
+}
+
+func TestDNSPayload(t *testing.T) {
+    for _, name := range []string{"redline.pcap", "lokibot.pcap", "benign_test.pcap"} {
+        t.Run(name, func(t *testing.T) {
+            n := 0
+            for _, f := range readFixture(t, name) {
+                if f.DNS == nil {
+                    continue
+                }
+                n++
+                if f.PayloadSizes.Sum() == 0 {
+                    t.Errorf("%s:%d -> %s:%d: DNS flow has no payload", f.SrcIP, f.SrcPort, f.DstIP, f.DstPort)
+                }
+                if f.Fwd.Bytes.Entropy() == 0 {
+                    t.Errorf("%s:%d -> %s:%d: DNS query has zero entropy", f.SrcIP, f.SrcPort, f.DstIP, f.DstPort)
+                }
+            }
+            if n == 0 {
+                t.Fatal("no DNS flows")
+            }
+        })
+    }
+}
+
+func TestTCPFixture(t *testing.T) {
+    // tcp-ecn-sample.pcap is a single HTTP download over a connection that
+    // negotiates ECN and then sees congestion. The expected counts were
+    // read from the TCP headers in the file without gopacket.
+    flows := readFixture(t, "tcp-ecn-sample.pcap")
+    if len(flows) != 1 {
+        t.Fatalf("%d flows, want 1", len(flows))
+    }
+    f := flows[0]
+    if f.SrcPort != 46557 || f.DstPort != 80 {
+        t.Errorf("flow %s:%d -> %s:%d, want the client on port 46557 as initiator", f.SrcIP, f.SrcPort, f.DstIP, f.DstPort)
+    }
+
+    tests := []struct {
+        name    string
+        d       Direction
+        packets int
+        flags   TCPFlags
+    }{
+        // The client's SYN asks for ECN with ECE and CWR, and it then
+        // echoes the congestion marks it receives.
+        {"fwd", f.Fwd, 309, TCPFlags{SYN: 1, ACK: 308, FIN: 1, PSH: 1, ECE: 132, CWR: 1}},
+        // The server's SYN-ACK agrees to ECN with ECE, and it answers the
+        // echoes with CWR.
+        {"bwd", f.Bwd, 170, TCPFlags{SYN: 1, ACK: 170, FIN: 1, PSH: 1, ECE: 1, CWR: 46}},
+    }
+    for _, tt := range tests {
+        if tt.d.PacketCount != tt.packets {
+            t.Errorf("%s: %d packets, want %d", tt.name, tt.d.PacketCount, tt.packets)
+        }
+        if tt.d.TCP.Flags != tt.flags {
+            t.Errorf("%s: flags %+v, want %+v", tt.name, tt.d.TCP.Flags, tt.flags)
+        }
+        if tt.d.TCP.InitWindow != 4128 {
+            t.Errorf("%s: initial window %d, want 4128", tt.name, tt.d.TCP.InitWindow)
+        }
+        if tt.d.TCP.Retransmissions != 0 || tt.d.TCP.OutOfOrder != 0 {
+            t.Errorf("%s: %d retransmissions, %d out of order in a clean capture", tt.name, tt.d.TCP.Retransmissions, tt.d.TCP.OutOfOrder)
+        }
+    }
+    if f.HandshakeRTT.Round(time.Millisecond) != 452*time.Millisecond || f.EndReason != EndFIN {
+        t.Errorf("handshake RTT %v, end reason %q", f.HandshakeRTT, f.EndReason)
+    }
+    if f.HTTP == nil || f.HTTP.RequestCount != 1 || f.HTTP.ResponseCount != 1 || f.HTTP.Requests[0].Status != 200 {
+        t.Errorf("HTTP = %+v", f.HTTP)
+    }
+}
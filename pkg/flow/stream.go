@@ -0,0 +1,92 @@
+package flow
+
+import (
+    "github.com/google/gopacket"
+    "github.com/google/gopacket/layers"
+    "github.com/google/gopacket/reassembly"
+
+    "github.com/Tushar98644/PacketSentry/pkg/httpinfo"
+)
+
+// Limits on the out-of-order data the reassembler holds, in pages of
+// about 2 KB: per connection, and in all.
+const (
+    maxPagesPerConnection = 64
+    maxPagesTotal         = 16384
+)
+
+// packetContext carries a packet's capture info and flow key through the
+// reassembler to the stream that receives its data.
+type packetContext struct {
+    ci    gopacket.CaptureInfo
+    key   string
+    parts []string
+}
+
+func (c *packetContext) GetCaptureInfo() gopacket.CaptureInfo {
+    return c.ci
+}
+
+// streamFactory creates a tcpStream for each TCP connection the
+// reassembler sees.
+type streamFactory struct {
+    a *aggregator
+}
+
+func (sf *streamFactory) New(_, _ gopacket.Flow, _ *layers.TCP, ac reassembly.AssemblerContext) reassembly.Stream {
+    pc := ac.(*packetContext)
+    return &tcpStream{a: sf.a, key: pc.key, first: pc.parts}
+}
+
+// tcpStream hands a connection's reassembled data to the TLS and HTTP
+// parsers of the flow it currently belongs to.
+type tcpStream struct {
+    a   *aggregator
+    key string
+    // first are the key parts of the packet that opened the stream, which
+    // the reassembler calls client to server.
+    first []string
+}
+
+// Accept takes every segment, also of connections whose handshake was
+// not captured.
+func (s *tcpStream) Accept(_ *layers.TCP, _ gopacket.CaptureInfo, _ reassembly.TCPFlowDirection, _ reassembly.Sequence, start *bool, _ reassembly.AssemblerContext) bool {
+    *start = true
+    return true
+}
+
+func (s *tcpStream) ReassembledSG(sg reassembly.ScatterGather, _ reassembly.AssemblerContext) {
+    f := s.a.flows[s.key]
+    length, _ := sg.Lengths()
+    if f == nil || length == 0 {
+        return
+    }
+    dir, _, _, skip := sg.Info()
+    parts := s.first
+    if dir == reassembly.TCPDirServerToClient {
+        parts = []string{parts[1], parts[0], parts[2], parts[4], parts[3]}
+    }
+    forward := f.isForward(parts)
+
+    data := sg.Fetch(length)
+    f.addTLS(forward, data, skip > 0)
+
+    if f.HTTP == nil {
+        f.HTTP = &httpinfo.Info{}
+    }
+    if forward {
+        if skip > 0 {
+            f.HTTP.ClientGap(skip)
+        }
+        f.HTTP.Client(data)
+    } else {
+        if skip > 0 {
+            f.HTTP.ServerGap(skip)
+        }
+        f.HTTP.Server(data)
+    }
+}
+
+func (s *tcpStream) ReassemblyComplete(_ reassembly.AssemblerContext) bool {
+    return true
+}
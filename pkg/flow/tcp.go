@@ -0,0 +1,125 @@
+package flow
+
+import (
+    "time"
+
+    "github.com/google/gopacket/layers"
+)
+
+// TCPFlags counts the packets that carried each TCP flag.
+type TCPFlags struct {
+    SYN int
+    ACK int
+    FIN int
+    RST int
+    PSH int
+    URG int
+    ECE int
+    CWR int
+}
+
+// Add returns the sum of both counts, e.g. to total two directions.
+func (c TCPFlags) Add(o TCPFlags) TCPFlags {
+    return TCPFlags{
+        SYN: c.SYN + o.SYN,
+        ACK: c.ACK + o.ACK,
+        FIN: c.FIN + o.FIN,
+        RST: c.RST + o.RST,
+        PSH: c.PSH + o.PSH,
+        URG: c.URG + o.URG,
+        ECE: c.ECE + o.ECE,
+        CWR: c.CWR + o.CWR,
+    }
+}
+
+// TCPState holds the TCP header counters of one direction of a flow.
+type TCPState struct {
+    Flags           TCPFlags
+    // InitWindow is the advertised window of the direction's first segment,
+    // unscaled.
+    InitWindow      int
+    // Retransmissions counts segments carrying only sequence space that was
+    // already seen; OutOfOrder counts those that arrived late, within one
+    // handshake RTT of the segment that overtook them.
+    Retransmissions int
+    OutOfOrder      int
+
+    seen       bool
+    // nextSeq is one past the highest sequence number sent so far, and
+    // advanced is when it last moved forward.
+    nextSeq    uint32
+    advanced   time.Time
+}
+
+// seqBefore reports whether sequence number a comes before b, allowing for
+// wrap-around.
+func seqBefore(a, b uint32) bool {
+    return int32(a-b) < 0
+}
+
+// addTCP records the header of a TCP segment sent in direction d.
+func (f *Flow) addTCP(d *Direction, forward bool, tcp *layers.TCP, ts time.Time) {
+    s := &d.TCP
+    countFlags(&s.Flags, tcp)
+
+    // SYN and FIN each take up one sequence number.
+    length := uint32(len(tcp.Payload))
+    if tcp.SYN {
+        length++
+    }
+    if tcp.FIN {
+        length++
+    }
+
+    if !s.seen {
+        s.seen = true
+        s.InitWindow = int(tcp.Window)
+        s.nextSeq = tcp.Seq + length
+        s.advanced = ts
+    } else if length > 0 {
+        end := tcp.Seq + length
+        switch {
+        case seqBefore(s.nextSeq, end):
+            s.nextSeq = end
+            s.advanced = ts
+        case f.HandshakeRTT > 0 && ts.Sub(s.advanced) < f.HandshakeRTT:
+            s.OutOfOrder++
+        default:
+            s.Retransmissions++
+        }
+    }
+
+    f.handshake(forward, tcp, ts)
+}
+
+// handshake measures HandshakeRTT from the initiator's SYN to its ACK of
+// the responder's SYN-ACK.
+func (f *Flow) handshake(forward bool, tcp *layers.TCP, ts time.Time) {
+    switch {
+    case f.HandshakeRTT > 0:
+    case forward && tcp.SYN && !tcp.ACK:
+        if f.synAt.IsZero() {
+            f.synAt = ts
+        }
+    case !forward && tcp.SYN && tcp.ACK:
+        if !f.synAt.IsZero() {
+            f.synAckSeen = true
+        }
+    case forward && tcp.ACK && f.synAckSeen:
+        f.HandshakeRTT = ts.Sub(f.synAt)
+    }
+}
+
+func countFlags(c *TCPFlags, tcp *layers.TCP) {
+    for _, fl := range []struct {
+        set bool
+        n   *int
+    }{
+        {tcp.SYN, &c.SYN}, {tcp.ACK, &c.ACK}, {tcp.FIN, &c.FIN}, {tcp.RST, &c.RST},
+        {tcp.PSH, &c.PSH}, {tcp.URG, &c.URG}, {tcp.ECE, &c.ECE}, {tcp.CWR, &c.CWR},
+    } {
+        if fl.set {
+            *fl.n++
+        }
+    }
+}
@@ -0,0 +1,63 @@
+package flow
+
+import (
+    "errors"
+
+    "github.com/Tushar98644/PacketSentry/pkg/tlsfp"
+)
+
+// maxHelloBytes bounds how much of a stream is buffered while waiting for
+// a complete TLS hello.
+const maxHelloBytes = 32 * 1024
+
+// helloBuffer collects the start of one side of a TCP stream until its TLS
+// hello is parsed, or it turns out not to be TLS.
+type helloBuffer struct {
+    data []byte
+    done bool
+}
+
+// addTLS feeds reassembled stream data to the TLS parser of its direction
+// and updates f.TLS once a hello is parsed. After a gap the hello cannot
+// be completed, so the direction is given up on.
+func (f *Flow) addTLS(forward bool, data []byte, gap bool) {
+    buf := &f.serverHello
+    if forward {
+        buf = &f.clientHello
+    }
+    if buf.done {
+        return
+    }
+    if gap {
+        buf.data, buf.done = nil, true
+        f.TLS = tlsfp.NewFingerprint(f.client, f.server)
+        return
+    }
+    if len(data) == 0 {
+        return
+    }
+    buf.data = append(buf.data, data...)
+
+    var err error
+    if forward {
+        var ch *tlsfp.ClientHello
+        if ch, err = tlsfp.ParseClientHello(buf.data); err == nil {
+            f.client = ch
+        }
+    } else {
+        var sh *tlsfp.ServerHello
+        if sh, err = tlsfp.ParseServerHello(buf.data); err == nil {
+            f.server = sh
+        }
+    }
+    if errors.Is(err, tlsfp.ErrIncomplete) && len(buf.data) < maxHelloBytes {
+        return
+    }
+    buf.data, buf.done = nil, true
+    f.TLS = tlsfp.NewFingerprint(f.client, f.server)
+}
+
+// helloDone reports whether both directions are done with TLS hellos.
+func (f *Flow) helloDone() bool {
+    return f.clientHello.done && f.serverHello.done
+}
@@ -0,0 +1,110 @@
+package flow
+
+import (
+    "crypto/tls"
+    "net"
+    "testing"
+    "time"
+
+    "github.com/google/gopacket"
+    "github.com/google/gopacket/layers"
+)
+
+// clientHello returns the ClientHello record crypto/tls sends for sni.
+func clientHello(t *testing.T, sni string) []byte {
+    t.Helper()
+    client, server := net.Pipe()
+    defer server.Close()
+    go func() {
+        tls.Client(client, &tls.Config{ServerName: sni}).Handshake()
+        client.Close()
+    }()
+    buf := make([]byte, 64*1024)
+    n, err := server.Read(buf)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return buf[:n]
+}
+
+// segment builds a TCP packet from 10.0.0.1:40000 to 10.0.0.2:443, or back
+// when reply is set, at base+offset ms.
+func segment(t *testing.T, reply bool, seq, ack uint32, syn, isAck bool, payload []byte, offset int) gopacket.Packet {
+    t.Helper()
+    src, dst := net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2}
+    sport, dport := layers.TCPPort(40000), layers.TCPPort(443)
+    if reply {
+        src, dst, sport, dport = dst, src, dport, sport
+    }
+    eth := &layers.Ethernet{SrcMAC: net.HardwareAddr{0, 0, 0, 0, 0, 1}, DstMAC: net.HardwareAddr{0, 0, 0, 0, 0, 2}, EthernetType: layers.EthernetTypeIPv4}
+    ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: src, DstIP: dst}
+    tcp := &layers.TCP{SrcPort: sport, DstPort: dport, Seq: seq, Ack: ack, SYN: syn, ACK: isAck, Window: 65535}
+    tcp.SetNetworkLayerForChecksum(ip)
+    buf := gopacket.NewSerializeBuffer()
+    opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+    if err := gopacket.SerializeLayers(buf, opts, eth, ip, tcp, gopacket.Payload(payload)); err != nil {
+        t.Fatal(err)
+    }
+    pkt := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+    md := pkt.Metadata()
+    md.Timestamp = time.Unix(1000, 0).Add(time.Duration(offset) * time.Millisecond)
+    md.CaptureLength, md.Length = len(buf.Bytes()), len(buf.Bytes())
+    return pkt
+}
+
+func TestClientHelloReassembly(t *testing.T) {
+    hello := clientHello(t, "split.example.com")
+    if len(hello) < 250 {
+        t.Fatalf("hello is only %d bytes", len(hello))
+    }
+    const isn, rsn = 1000, 5000
+    data := func(from, to int, offset int) gopacket.Packet {
+        return segment(t, false, isn+1+uint32(from), rsn+1, false, true, hello[from:to], offset)
+    }
+
+    tests := []struct {
+        name string
+        segs func() []gopacket.Packet
+        sni  string
+    }{
+        {"in order", func() []gopacket.Packet {
+            return []gopacket.Packet{data(0, 100, 3), data(100, 200, 4), data(200, len(hello), 5)}
+        }, "split.example.com"},
+        {"out of order", func() []gopacket.Packet {
+            return []gopacket.Packet{data(0, 100, 3), data(200, len(hello), 4), data(100, 200, 5)}
+        }, "split.example.com"},
+        {"overlapping retransmission", func() []gopacket.Packet {
+            return []gopacket.Packet{data(0, 100, 3), data(50, 150, 4), data(100, 200, 5), data(200, len(hello), 6)}
+        }, "split.example.com"},
+        {"gap", func() []gopacket.Packet {
+            return []gopacket.Packet{data(0, 100, 3), data(200, len(hello), 4)}
+        }, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            pkts := []gopacket.Packet{
+                segment(t, false, isn, 0, true, false, nil, 0),
+                segment(t, true, rsn, isn+1, true, true, nil, 1),
+                segment(t, false, isn+1, rsn+1, false, true, nil, 2),
+            }
+            pkts = append(pkts, tt.segs()...)
+            ch := make(chan gopacket.Packet, len(pkts))
+            for _, p := range pkts {
+                ch <- p
+            }
+            close(ch)
+
+            flows := Aggregate(ch)
+            if len(flows) != 1 {
+                t.Fatalf("got %d flows, want 1", len(flows))
+            }
+            sni := ""
+            if fp := flows[0].TLS; fp != nil {
+                sni = fp.SNI
+            }
+            if sni != tt.sni {
+                t.Errorf("SNI = %q, want %q", sni, tt.sni)
+            }
+        })
+    }
+}
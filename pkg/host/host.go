@@ -0,0 +1,272 @@
+// Package host profiles the hosts of the local networks across their flows:
+// how many destinations they talk to and how quickly they find new ones,
+// how much they send and receive, which protocols they use and at what
+// hours they are active. A flow that is unremarkable on its own can stand
+// out against the profile of the host that made it.
+package host
+
+import (
+    "bytes"
+    "fmt"
+    "net"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/Tushar98644/PacketSentry/pkg/features"
+)
+
+// DefaultWindow is how far back destinations count towards a host's
+// fan-out and new-destination rate.
+const DefaultWindow = time.Hour
+
+// Networks are the local networks. Empty Networks stand for the private,
+// link-local and loopback ranges.
+type Networks []*net.IPNet
+
+// ParseNetworks parses a comma-separated list of addresses and CIDR
+// networks, such as "192.168.1.10" or "10.0.0.0/8,fd00::/8". An address
+// stands for itself alone.
+func ParseNetworks(s string) (Networks, error) {
+    var ns Networks
+    for _, part := range strings.Split(s, ",") {
+        part = strings.TrimSpace(part)
+        if part == "" {
+            continue
+        }
+        if _, n, err := net.ParseCIDR(part); err == nil {
+            ns = append(ns, n)
+            continue
+        }
+        ip := net.ParseIP(part)
+        if ip == nil {
+            return nil, fmt.Errorf("invalid local address or network %q", part)
+        }
+        bits := 8 * net.IPv6len
+        if v4 := ip.To4(); v4 != nil {
+            ip, bits = v4, 8*net.IPv4len
+        }
+        ns = append(ns, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+    }
+    return ns, nil
+}
+
+// Contains reports whether ip is a local address.
+func (ns Networks) Contains(ip net.IP) bool {
+    if len(ns) == 0 {
+        return ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLoopback()
+    }
+    for _, n := range ns {
+        if n.Contains(ip) {
+            return true
+        }
+    }
+    return false
+}
+
+// Profile sums up the flows of one local host. Flows count whichever end
+// the host was; destinations only count flows the host initiated.
+type Profile struct {
+    Host  string
+    First time.Time
+    Last  time.Time
+    Flows int
+    // Outbound is the number of flows the host initiated.
+    Outbound int
+    BytesOut int64
+    BytesIn  int64
+    // Destinations counts first contacts: destinations the host had not
+    // talked to within the window before.
+    Destinations int
+    // FanOut is the number of distinct destinations within the window and
+    // NewDstRate the first contacts per minute within it, both as of the
+    // host's latest flow.
+    FanOut     int
+    NewDstRate float64
+    // Flows by transport and by application protocol.
+    TCP, UDP       int
+    TLS, DNS, HTTP int
+    // Hours counts the flows started in each hour of the day, in UTC.
+    Hours [24]int
+}
+
+// Tracker keeps the Profile of every local host seen.
+type Tracker struct {
+    nets   Networks
+    window time.Duration
+    hosts  map[string]*state
+}
+
+type state struct {
+    Profile
+    ip net.IP
+    // dests maps the destinations contacted within the window to when
+    // they were last contacted; contacts queues every contact in arrival
+    // order so that expired destinations are found without a scan.
+    dests    map[string]time.Time
+    contacts []contact
+    // firsts are the times of the first contacts within the window.
+    firsts   []time.Time
+    newest   time.Time
+}
+
+type contact struct {
+    dst string
+    at  time.Time
+}
+
+// NewTracker profiles the hosts of nets, with fan-out and new-destination
+// rates taken over window.
+func NewTracker(nets Networks, window time.Duration) *Tracker {
+    if window <= 0 {
+        window = DefaultWindow
+    }
+    return &Tracker{nets: nets, window: window, hosts: make(map[string]*state)}
+}
+
+// Observe adds a finished flow to the profiles of its local ends and sets
+// ftr.Host from the profile of the end that initiated it, or else of the
+// end that answered. Flows should be observed roughly in time order.
+func (t *Tracker) Observe(ftr *features.FlowFeatures) {
+    var local *state
+    if t.nets.Contains(ftr.DstIP) && !ftr.DstIP.Equal(ftr.SrcIP) {
+        local = t.state(ftr.DstIP)
+        local.record(ftr, false, t.window)
+    }
+    if t.nets.Contains(ftr.SrcIP) {
+        local = t.state(ftr.SrcIP)
+        local.record(ftr, true, t.window)
+    }
+    if local != nil {
+        ftr.Host = local.features(ftr.Start)
+    }
+}
+
+func (t *Tracker) state(ip net.IP) *state {
+    key := ip.String()
+    s := t.hosts[key]
+    if s == nil {
+        s = &state{Profile: Profile{Host: key}, ip: ip, dests: make(map[string]time.Time)}
+        t.hosts[key] = s
+    }
+    return s
+}
+
+// Profiles returns the profile of every local host seen, ordered by
+// address.
+func (t *Tracker) Profiles() []Profile {
+    states := make([]*state, 0, len(t.hosts))
+    for _, s := range t.hosts {
+        states = append(states, s)
+    }
+    sort.Slice(states, func(i, j int) bool {
+        a, b := states[i].ip.To16(), states[j].ip.To16()
+        return bytes.Compare(a, b) < 0
+    })
+    profiles := make([]Profile, len(states))
+    for i, s := range states {
+        profiles[i] = s.Profile
+    }
+    return profiles
+}
+
+// Len returns the number of local hosts seen.
+func (t *Tracker) Len() int {
+    return len(t.hosts)
+}
+
+// record adds ftr to the profile; outbound is whether the host initiated
+// it.
+func (s *state) record(ftr *features.FlowFeatures, outbound bool, window time.Duration) {
+    at := ftr.Start
+    if s.Flows == 0 || at.Before(s.First) {
+        s.First = at
+    }
+    if ftr.End.After(s.Last) {
+        s.Last = ftr.End
+    }
+    if at.After(s.newest) {
+        s.newest = at
+    }
+    s.Flows++
+
+    sent, received := int64(ftr.FwdPacketStats.Sum), int64(ftr.BwdPacketStats.Sum)
+    if !outbound {
+        sent, received = received, sent
+    }
+    s.BytesOut += sent
+    s.BytesIn += received
+
+    switch ftr.Protocol {
+    case "TCP":
+        s.TCP++
+    case "UDP":
+        s.UDP++
+    }
+    if ftr.TLS != nil {
+        s.TLS++
+    }
+    if ftr.DNS != nil {
+        s.DNS++
+    }
+    if ftr.HTTP != nil {
+        s.HTTP++
+    }
+    s.Hours[at.UTC().Hour()]++
+
+    s.expire(s.newest.Add(-window))
+    if outbound {
+        s.Outbound++
+        dst := ftr.DstIP.String()
+        last, seen := s.dests[dst]
+        if !seen {
+            s.Destinations++
+            s.firsts = append(s.firsts, at)
+        }
+        if !seen || at.After(last) {
+            s.dests[dst] = at
+        }
+        s.contacts = append(s.contacts, contact{dst, at})
+    }
+
+    s.FanOut = len(s.dests)
+    // Until a whole window has passed, the rate is taken over the time
+    // the host has been seen, but at least a minute.
+    span := min(window, max(s.newest.Sub(s.First), time.Minute))
+    s.NewDstRate = float64(len(s.firsts)) / span.Minutes()
+}
+
+// expire forgets the destinations and first contacts from before cutoff.
+func (s *state) expire(cutoff time.Time) {
+    for len(s.contacts) > 0 && s.contacts[0].at.Before(cutoff) {
+        c := s.contacts[0]
+        if last, ok := s.dests[c.dst]; ok && last.Before(cutoff) {
+            delete(s.dests, c.dst)
+        }
+        s.contacts = s.contacts[1:]
+    }
+    for len(s.firsts) > 0 && s.firsts[0].Before(cutoff) {
+        s.firsts = s.firsts[1:]
+    }
+}
+
+// features describes the host for a flow that started at at.
+func (s *state) features(at time.Time) features.HostFeatures {
+    share := func(n int64, total int64) float64 {
+        if total == 0 {
+            return 0
+        }
+        return float64(n) / float64(total)
+    }
+    flows := int64(s.Flows)
+    return features.HostFeatures{
+        FanOut:        s.FanOut,
+        NewDstRate:    s.NewDstRate,
+        BytesOutRatio: share(s.BytesOut, s.BytesOut+s.BytesIn),
+        UDPRatio:      share(int64(s.UDP), flows),
+        TLSRatio:      share(int64(s.TLS), flows),
+        DNSRatio:      share(int64(s.DNS), flows),
+        HTTPRatio:     share(int64(s.HTTP), flows),
+        HourRatio:     share(int64(s.Hours[at.UTC().Hour()]), flows),
+    }
+}
@@ -0,0 +1,195 @@
+package host
+
+import (
+    "net"
+    "testing"
+    "time"
+
+    "github.com/Tushar98644/PacketSentry/pkg/dns"
+    "github.com/Tushar98644/PacketSentry/pkg/features"
+    "github.com/Tushar98644/PacketSentry/pkg/httpinfo"
+    "github.com/Tushar98644/PacketSentry/pkg/tlsfp"
+)
+
+// base is the start of the synthetic flows, ten o'clock UTC.
+var base = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// flow is a one-second TCP flow from src to dst starting at base+at, in
+// which the initiator sent sent bytes and received received.
+func flow(src, dst string, at time.Duration, sent, received int, edits ...func(*features.FlowFeatures)) *features.FlowFeatures {
+    f := &features.FlowFeatures{
+        SrcIP:    net.ParseIP(src),
+        DstIP:    net.ParseIP(dst),
+        Protocol: "TCP",
+        Start:    base.Add(at),
+        End:      base.Add(at + time.Second),
+    }
+    f.FwdPacketStats.Sum = sent
+    f.BwdPacketStats.Sum = received
+    for _, edit := range edits {
+        edit(f)
+    }
+    return f
+}
+
+func udp(f *features.FlowFeatures)      { f.Protocol = "UDP" }
+func withTLS(f *features.FlowFeatures)  { f.TLS = &tlsfp.Fingerprint{} }
+func withDNS(f *features.FlowFeatures)  { f.DNS = &dns.Info{} }
+func withHTTP(f *features.FlowFeatures) { f.HTTP = &httpinfo.Info{} }
+
+func TestParseNetworks(t *testing.T) {
+    tests := []struct {
+        networks string
+        in, out  []string
+    }{
+        {"", []string{"10.1.2.3", "172.16.0.1", "192.168.1.1", "fd00::1", "fe80::1", "127.0.0.1", "::1"},
+            []string{"8.8.8.8", "2001:db8::1", "100.64.0.1"}},
+        {"192.168.1.10", []string{"192.168.1.10"}, []string{"192.168.1.11", "10.0.0.1"}},
+        {" 10.0.0.0/8 , fd00::/8,", []string{"10.9.9.9", "fd00::1"}, []string{"192.168.1.1", "fe80::1", "11.0.0.1"}},
+        {"8.8.8.8,2001:db8::1", []string{"8.8.8.8", "2001:db8::1"}, []string{"8.8.4.4", "10.0.0.1"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.networks, func(t *testing.T) {
+            nets, err := ParseNetworks(tt.networks)
+            if err != nil {
+                t.Fatal(err)
+            }
+            for _, ip := range tt.in {
+                if !nets.Contains(net.ParseIP(ip)) {
+                    t.Errorf("%s is not local", ip)
+                }
+            }
+            for _, ip := range tt.out {
+                if nets.Contains(net.ParseIP(ip)) {
+                    t.Errorf("%s is local", ip)
+                }
+            }
+        })
+    }
+
+    for _, bad := range []string{"10.0.0.0/33", "local", "10.0.0.1,nope", "192.168.1"} {
+        if _, err := ParseNetworks(bad); err == nil {
+            t.Errorf("ParseNetworks(%q) succeeded", bad)
+        }
+    }
+}
+
+func TestObserve(t *testing.T) {
+    const me = "10.0.0.1"
+    tests := []struct {
+        name   string
+        nets   string
+        window time.Duration
+        flows  []*features.FlowFeatures
+        // want is what the last flow's Host is set to.
+        want   features.HostFeatures
+        hosts  int
+    }{
+        {"fan-out", "", time.Hour, []*features.FlowFeatures{
+            flow(me, "8.8.8.8", 0, 100, 100),
+            flow(me, "1.1.1.1", time.Minute, 100, 100),
+            flow(me, "8.8.8.8", 2*time.Minute, 100, 100),
+            flow(me, "9.9.9.9", 4*time.Minute, 100, 100),
+        }, features.HostFeatures{FanOut: 3, NewDstRate: 3.0 / 4, BytesOutRatio: 0.5, HourRatio: 1}, 1},
+        {"rate over at least a minute", "", time.Hour, []*features.FlowFeatures{
+            flow(me, "8.8.8.8", 0, 100, 100),
+            flow(me, "1.1.1.1", time.Second, 100, 100),
+        }, features.HostFeatures{FanOut: 2, NewDstRate: 2, BytesOutRatio: 0.5, HourRatio: 1}, 1},
+        {"window expiry", "", 10 * time.Minute, []*features.FlowFeatures{
+            flow(me, "8.8.8.8", 0, 100, 100),
+            flow(me, "1.1.1.1", 5*time.Minute, 100, 100),
+            flow(me, "9.9.9.9", 12*time.Minute, 100, 100),
+        }, features.HostFeatures{FanOut: 2, NewDstRate: 2.0 / 10, BytesOutRatio: 0.5, HourRatio: 1}, 1},
+        {"recontact keeps a destination", "", 10 * time.Minute, []*features.FlowFeatures{
+            flow(me, "8.8.8.8", 0, 100, 100),
+            flow(me, "8.8.8.8", 8*time.Minute, 100, 100),
+            flow(me, "1.1.1.1", 12*time.Minute, 100, 100),
+        }, features.HostFeatures{FanOut: 2, NewDstRate: 1.0 / 10, BytesOutRatio: 0.5, HourRatio: 1}, 1},
+        {"expired destination is new again", "", 10 * time.Minute, []*features.FlowFeatures{
+            flow(me, "8.8.8.8", 0, 100, 100),
+            flow(me, "8.8.8.8", 11*time.Minute, 100, 100),
+        }, features.HostFeatures{FanOut: 1, NewDstRate: 1.0 / 10, BytesOutRatio: 0.5, HourRatio: 1}, 1},
+        {"bytes both ways", "", time.Hour, []*features.FlowFeatures{
+            flow(me, "8.8.8.8", 0, 300, 100),
+            // The host answered this one, so it sent the backward bytes.
+            flow("8.8.8.8", me, time.Minute, 50, 150),
+        }, features.HostFeatures{FanOut: 1, NewDstRate: 1, BytesOutRatio: 0.75, HourRatio: 1}, 1},
+        {"protocols", "", time.Hour, []*features.FlowFeatures{
+            flow(me, "8.8.8.8", 0, 0, 0, withTLS),
+            flow(me, "8.8.8.8", time.Second, 0, 0, udp, withDNS),
+            flow(me, "8.8.8.8", 2*time.Second, 0, 0, withHTTP),
+            flow(me, "8.8.8.8", 3*time.Second, 0, 0, udp),
+        }, features.HostFeatures{FanOut: 1, NewDstRate: 1, UDPRatio: 0.5, TLSRatio: 0.25, DNSRatio: 0.25, HTTPRatio: 0.25, HourRatio: 1}, 1},
+        {"hours", "", 24 * time.Hour, []*features.FlowFeatures{
+            flow(me, "8.8.8.8", 5*time.Minute, 0, 0),
+            flow(me, "8.8.8.8", 30*time.Minute, 0, 0),
+            flow(me, "8.8.8.8", 70*time.Minute, 0, 0),
+        }, features.HostFeatures{FanOut: 1, NewDstRate: 1.0 / 65, HourRatio: 1.0 / 3}, 1},
+        {"answering end when the initiator is remote", "", time.Hour, []*features.FlowFeatures{
+            flow("8.8.8.8", me, 0, 100, 300),
+        }, features.HostFeatures{BytesOutRatio: 0.75, HourRatio: 1}, 1},
+        {"initiating end when both are local", "", time.Hour, []*features.FlowFeatures{
+            flow(me, "10.0.0.2", 0, 100, 0),
+        }, features.HostFeatures{FanOut: 1, NewDstRate: 1, BytesOutRatio: 1, HourRatio: 1}, 2},
+        {"same address both ends", "", time.Hour, []*features.FlowFeatures{
+            flow(me, me, 0, 100, 0),
+        }, features.HostFeatures{FanOut: 1, NewDstRate: 1, BytesOutRatio: 1, HourRatio: 1}, 1},
+        {"only the given networks", "192.168.1.0/24", time.Hour, []*features.FlowFeatures{
+            flow(me, "8.8.8.8", 0, 100, 100),
+        }, features.HostFeatures{}, 0},
+        {"given networks answering", "192.168.1.0/24", time.Hour, []*features.FlowFeatures{
+            flow(me, "192.168.1.5", 0, 100, 300),
+        }, features.HostFeatures{BytesOutRatio: 0.75, HourRatio: 1}, 1},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            nets, err := ParseNetworks(tt.nets)
+            if err != nil {
+                t.Fatal(err)
+            }
+            tr := NewTracker(nets, tt.window)
+            for _, f := range tt.flows {
+                tr.Observe(f)
+            }
+            if got := tt.flows[len(tt.flows)-1].Host; got != tt.want {
+                t.Errorf("Host = %+v\nwant   %+v", got, tt.want)
+            }
+            if tr.Len() != tt.hosts {
+                t.Errorf("%d hosts, want %d", tr.Len(), tt.hosts)
+            }
+        })
+    }
+}
+
+func TestProfiles(t *testing.T) {
+    tr := NewTracker(nil, time.Hour)
+    for _, f := range []*features.FlowFeatures{
+        flow("10.0.0.10", "8.8.8.8", 0, 100, 200, withTLS),
+        flow("10.0.0.2", "10.0.0.10", time.Minute, 10, 20, udp, withDNS),
+        flow("10.0.0.10", "1.1.1.1", 2*time.Minute, 100, 200),
+        flow("8.8.8.8", "10.0.0.2", 3*time.Minute, 30, 40),
+    } {
+        tr.Observe(f)
+    }
+
+    want := []Profile{
+        {Host: "10.0.0.2", First: base.Add(time.Minute), Last: base.Add(3*time.Minute + time.Second),
+            Flows: 2, Outbound: 1, BytesOut: 50, BytesIn: 50, Destinations: 1, FanOut: 1, NewDstRate: 1.0 / 2,
+            TCP: 1, UDP: 1, DNS: 1},
+        {Host: "10.0.0.10", First: base, Last: base.Add(2*time.Minute + time.Second),
+            Flows: 3, Outbound: 2, BytesOut: 220, BytesIn: 410, Destinations: 2, FanOut: 2, NewDstRate: 2.0 / 2,
+            TCP: 2, UDP: 1, TLS: 1, DNS: 1},
+    }
+    want[0].Hours[10] = 2
+    want[1].Hours[10] = 3
+
+    got := tr.Profiles()
+    if len(got) != len(want) {
+        t.Fatalf("%d profiles, want %d", len(got), len(want))
+    }
+    for i := range want {
+        if got[i] != want[i] {
+            t.Errorf("profile %d:\n got %+v\nwant %+v", i, got[i], want[i])
+        }
+    }
+}
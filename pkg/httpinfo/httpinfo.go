@@ -0,0 +1,352 @@
+// Package httpinfo parses HTTP/1.x requests and responses out of reassembled
+// TCP streams and records their metadata. Bodies are counted, not kept.
+package httpinfo
+
+import (
+    "bufio"
+    "bytes"
+    "net/textproto"
+    "strconv"
+    "strings"
+)
+
+const (
+    // maxRecorded bounds the requests kept per flow; the counters keep
+    // counting past it.
+    maxRecorded = 32
+    // maxHeader is the longest header block accepted; streams that do not
+    // end one within it are not HTTP, or not worth following.
+    maxHeader   = 16 * 1024
+)
+
+// Request is one HTTP request and, once it has arrived, its response.
+type Request struct {
+    Method      string
+    Host        string
+    URI         string
+    UserAgent   string
+    ContentType string
+    BodySize    int64
+
+    // Status is zero until the response is seen.
+    Status              int
+    ResponseContentType string
+    ResponseBodySize    int64
+}
+
+// Info holds the HTTP exchanges of one flow.
+type Info struct {
+    Requests      []Request
+    RequestCount  int
+    ResponseCount int
+    // BodyBytes and ResponseBodyBytes total the bodies of every message.
+    BodyBytes         int64
+    ResponseBodyBytes int64
+
+    client parser
+    server parser
+}
+
+// Client feeds the next bytes the client sent.
+func (info *Info) Client(data []byte) {
+    info.client.feed(data, info.request, info.requestBody)
+}
+
+// Server feeds the next bytes the server sent.
+func (info *Info) Server(data []byte) {
+    info.server.response = true
+    info.server.feed(data, info.response, info.responseBody)
+}
+
+// ClientGap and ServerGap report n bytes missing from a side's stream,
+// lost to the capture. A gap inside a body only shortens what is seen of
+// it; anywhere else the side cannot be followed any further.
+func (info *Info) ClientGap(n int) {
+    info.client.gap(int64(n), info.requestBody)
+}
+
+func (info *Info) ServerGap(n int) {
+    info.server.gap(int64(n), info.responseBody)
+}
+
+// Seen reports whether any HTTP message was parsed.
+func (info *Info) Seen() bool {
+    return info.RequestCount > 0 || info.ResponseCount > 0
+}
+
+// Done reports whether both sides turned out not to speak HTTP, so the
+// rest of the flow need not be fed.
+func (info *Info) Done() bool {
+    return info.client.failed && info.server.failed
+}
+
+func (info *Info) request(start []string, hdr textproto.MIMEHeader) bodyKind {
+    info.RequestCount++
+    if len(info.Requests) < maxRecorded {
+        info.Requests = append(info.Requests, Request{
+            Method:      start[0],
+            Host:        hdr.Get("Host"),
+            URI:         start[1],
+            UserAgent:   hdr.Get("User-Agent"),
+            ContentType: hdr.Get("Content-Type"),
+        })
+    }
+    // Requests without a length have no body.
+    if b := lengthOf(hdr); b.kind != bodyUntilClose {
+        return b
+    }
+    return bodyKind{kind: bodyLength}
+}
+
+func (info *Info) requestBody(n int64) {
+    info.BodyBytes += n
+    if i := info.RequestCount - 1; i < len(info.Requests) {
+        info.Requests[i].BodySize += n
+    }
+}
+
+func (info *Info) response(start []string, hdr textproto.MIMEHeader) bodyKind {
+    status, _ := strconv.Atoi(start[1])
+    // Interim responses precede the real one to the same request.
+    if status >= 100 && status < 200 {
+        return bodyKind{kind: bodyLength}
+    }
+    info.ResponseCount++
+    var method string
+    if r := info.current(); r != nil {
+        r.Status = status
+        r.ResponseContentType = hdr.Get("Content-Type")
+        method = r.Method
+    }
+    if method == "HEAD" || status == 204 || status == 304 {
+        return bodyKind{kind: bodyLength}
+    }
+    return lengthOf(hdr)
+}
+
+func (info *Info) responseBody(n int64) {
+    info.ResponseBodyBytes += n
+    if r := info.current(); r != nil {
+        r.ResponseBodySize += n
+    }
+}
+
+// current returns the request answered by the latest response, if it is
+// recorded.
+func (info *Info) current() *Request {
+    if i := info.ResponseCount - 1; i >= 0 && i < len(info.Requests) {
+        return &info.Requests[i]
+    }
+    return nil
+}
+
+type bodyKindType int
+
+const (
+    bodyLength bodyKindType = iota
+    bodyChunked
+    bodyUntilClose
+)
+
+type bodyKind struct {
+    kind bodyKindType
+    n    int64
+}
+
+func lengthOf(hdr textproto.MIMEHeader) bodyKind {
+    if strings.EqualFold(strings.TrimSpace(hdr.Get("Transfer-Encoding")), "chunked") {
+        return bodyKind{kind: bodyChunked}
+    }
+    if cl := hdr.Get("Content-Length"); cl != "" {
+        if n, err := strconv.ParseInt(strings.TrimSpace(cl), 10, 64); err == nil && n >= 0 {
+            return bodyKind{kind: bodyLength, n: n}
+        }
+    }
+    return bodyKind{kind: bodyUntilClose}
+}
+
+type parserState int
+
+const (
+    stateHeader parserState = iota
+    stateBody
+    stateChunkSize
+    stateChunkData
+    stateTrailer
+    stateUntilClose
+)
+
+// parser follows the messages of one direction as the data arrives.
+type parser struct {
+    response  bool
+    failed    bool
+    state     parserState
+    buf       []byte
+    // remaining is what is left of the current body or chunk, including
+    // a chunk's trailing CRLF.
+    remaining int64
+}
+
+func (p *parser) feed(data []byte, header func([]string, textproto.MIMEHeader) bodyKind, body func(int64)) {
+    if p.failed {
+        return
+    }
+    p.buf = append(p.buf, data...)
+    for len(p.buf) > 0 && !p.failed {
+        switch p.state {
+        case stateHeader:
+            if !p.looksLikeHTTP() {
+                p.fail()
+                return
+            }
+            end := bytes.Index(p.buf, []byte("\r\n\r\n"))
+            if end < 0 {
+                if len(p.buf) > maxHeader {
+                    p.fail()
+                }
+                return
+            }
+            start, hdr, ok := p.parseHeader(p.buf[:end+4])
+            p.buf = p.buf[end+4:]
+            if !ok {
+                p.fail()
+                return
+            }
+            b := header(start, hdr)
+            switch b.kind {
+            case bodyChunked:
+                p.state = stateChunkSize
+            case bodyUntilClose:
+                p.state = stateUntilClose
+            default:
+                if b.n > 0 {
+                    p.state, p.remaining = stateBody, b.n
+                }
+            }
+
+        case stateBody, stateChunkData:
+            n := min(p.remaining, int64(len(p.buf)))
+            if p.state == stateBody {
+                body(n)
+            } else {
+                // The chunk's CRLF is not part of the body.
+                body(min(n, max(0, p.remaining-2)))
+            }
+            p.remaining -= n
+            p.buf = p.buf[n:]
+            if p.remaining == 0 {
+                if p.state == stateBody {
+                    p.state = stateHeader
+                } else {
+                    p.state = stateChunkSize
+                }
+            }
+
+        case stateChunkSize, stateTrailer:
+            line, ok := p.line()
+            if !ok {
+                return
+            }
+            if p.state == stateTrailer {
+                if line == "" {
+                    p.state = stateHeader
+                }
+                continue
+            }
+            sizeStr, _, _ := strings.Cut(line, ";")
+            size, err := strconv.ParseInt(strings.TrimSpace(sizeStr), 16, 64)
+            if err != nil || size < 0 {
+                p.fail()
+                return
+            }
+            if size == 0 {
+                p.state = stateTrailer
+            } else {
+                p.state, p.remaining = stateChunkData, size+2
+            }
+
+        case stateUntilClose:
+            body(int64(len(p.buf)))
+            p.buf = p.buf[:0]
+        }
+    }
+}
+
+func (p *parser) gap(n int64, body func(int64)) {
+    switch {
+    case p.failed:
+    case p.state == stateUntilClose:
+        body(n)
+    case p.state == stateBody && len(p.buf) == 0 && n <= p.remaining:
+        body(n)
+        if p.remaining -= n; p.remaining == 0 {
+            p.state = stateHeader
+        }
+    default:
+        p.fail()
+    }
+}
+
+// line takes the next CRLF-terminated line from the buffer.
+func (p *parser) line() (string, bool) {
+    i := bytes.Index(p.buf, []byte("\r\n"))
+    if i < 0 {
+        if len(p.buf) > maxHeader {
+            p.fail()
+        }
+        return "", false
+    }
+    line := string(p.buf[:i])
+    p.buf = p.buf[i+2:]
+    return line, true
+}
+
+// looksLikeHTTP checks the start of a message as soon as a few bytes are
+// in, so that other protocols are given up on early.
+func (p *parser) looksLikeHTTP() bool {
+    if p.response {
+        prefix := "HTTP/1."
+        n := min(len(p.buf), len(prefix))
+        return string(p.buf[:n]) == prefix[:n]
+    }
+    // A method token: upper-case letters up to the first space.
+    for i, c := range p.buf {
+        switch {
+        case c == ' ' && i > 0:
+            return true
+        case c < 'A' || c > 'Z' || i >= 16:
+            return false
+        }
+    }
+    return true
+}
+
+// parseHeader splits a header block into the words of its start line and
+// its fields.
+func (p *parser) parseHeader(block []byte) ([]string, textproto.MIMEHeader, bool) {
+    r := textproto.NewReader(bufio.NewReader(bytes.NewReader(block)))
+    first, err := r.ReadLine()
+    if err != nil {
+        return nil, nil, false
+    }
+    start := strings.SplitN(first, " ", 3)
+    if len(start) < 2 {
+        return nil, nil, false
+    }
+    version := start[len(start)-1]
+    if p.response {
+        version = start[0]
+    }
+    if !strings.HasPrefix(version, "HTTP/1.") {
+        return nil, nil, false
+    }
+    hdr, err := r.ReadMIMEHeader()
+    if err != nil {
+        return nil, nil, false
+    }
+    return start, hdr, true
+}
+
+func (p *parser) fail() {
+    p.failed, p.buf = true, nil
+}
@@ -0,0 +1,174 @@
+package httpinfo
+
+import (
+    "fmt"
+    "testing"
+)
+
+// step is client or server data, or a gap of that many bytes when data
+// is empty.
+type step struct {
+    server bool
+    data   string
+    gap    int
+}
+
+func client(data string) step { return step{data: data} }
+func server(data string) step { return step{server: true, data: data} }
+
+type want struct {
+    requests, responses int
+    body, respBody      int64
+    // statuses are the recorded requests' response codes.
+    statuses []int
+    done     bool
+}
+
+func TestInfo(t *testing.T) {
+    tests := []struct {
+        name  string
+        steps []step
+        want  want
+    }{
+        {"get", []step{
+            client("GET /index.html HTTP/1.1\r\nHost: example.com\r\nUser-Agent: curl/8.0\r\n\r\n"),
+            server("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Length: 5\r\n\r\nhello"),
+        }, want{1, 1, 0, 5, []int{200}, false}},
+        {"post with body", []step{
+            client("POST /gate.php HTTP/1.1\r\nHost: c2\r\nContent-Length: 11\r\n\r\nid=1&x=abcd"),
+            server("HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n"),
+        }, want{1, 1, 11, 0, []int{404}, false}},
+        {"pipelined", []step{
+            client("GET /a HTTP/1.1\r\n\r\nGET /b HTTP/1.1\r\n\r\n"),
+            server("HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nabHTTP/1.1 301 Moved\r\nContent-Length: 3\r\n\r\nxyz"),
+        }, want{2, 2, 0, 5, []int{200, 301}, false}},
+        {"chunked", []step{
+            client("GET / HTTP/1.1\r\n\r\nGET /again HTTP/1.1\r\n\r\n"),
+            server("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n" +
+                "5\r\nhello\r\n" + "a;ext=1\r\n0123456789\r\n" + "0\r\nX-Trailer: 1\r\n\r\n" +
+                "HTTP/1.1 200 OK\r\nContent-Length: 1\r\n\r\n!"),
+        }, want{2, 2, 0, 16, []int{200, 200}, false}},
+        {"bad chunk size", []step{
+            client("GET / HTTP/1.1\r\n\r\n"),
+            server("HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n"),
+        }, want{1, 1, 0, 0, []int{200}, false}},
+        {"interim response", []step{
+            client("POST /upload HTTP/1.1\r\nExpect: 100-continue\r\nContent-Length: 4\r\n\r\n"),
+            server("HTTP/1.1 100 Continue\r\n\r\n"),
+            client("data"),
+            server("HTTP/1.1 201 Created\r\nContent-Length: 2\r\n\r\nok"),
+        }, want{1, 1, 4, 2, []int{201}, false}},
+        {"head has no body", []step{
+            client("HEAD / HTTP/1.1\r\n\r\nGET / HTTP/1.1\r\n\r\n"),
+            server("HTTP/1.1 200 OK\r\nContent-Length: 1000\r\n\r\nHTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok"),
+        }, want{2, 2, 0, 2, []int{200, 200}, false}},
+        {"204 and 304 have no body", []step{
+            client("GET /a HTTP/1.1\r\n\r\nGET /b HTTP/1.1\r\n\r\nGET /c HTTP/1.1\r\n\r\n"),
+            server("HTTP/1.1 204 No Content\r\nContent-Length: 10\r\n\r\n" +
+                "HTTP/1.1 304 Not Modified\r\nContent-Length: 10\r\n\r\n" +
+                "HTTP/1.1 200 OK\r\nContent-Length: 1\r\n\r\n!"),
+        }, want{3, 3, 0, 1, []int{204, 304, 200}, false}},
+        {"body until close", []step{
+            client("GET / HTTP/1.0\r\n\r\n"),
+            server("HTTP/1.0 200 OK\r\n\r\nsome"),
+            server("more"),
+            {server: true, gap: 100},
+            server("end"),
+        }, want{1, 1, 0, 111, []int{200}, false}},
+        {"gap inside body", []step{
+            client("POST / HTTP/1.1\r\nContent-Length: 10\r\n\r\nab"),
+            {gap: 6},
+            client("cd"),
+            client("GET /next HTTP/1.1\r\n\r\n"),
+        }, want{2, 0, 10, 0, []int{0, 0}, false}},
+        {"gap beyond body", []step{
+            client("POST / HTTP/1.1\r\nContent-Length: 4\r\n\r\nab"),
+            {gap: 50},
+            client("GET /lost HTTP/1.1\r\n\r\n"),
+        }, want{1, 0, 2, 0, []int{0}, false}},
+        {"gap in header", []step{
+            client("GET / HTTP/1.1\r\nHo"),
+            {gap: 10},
+            client("GET /lost HTTP/1.1\r\n\r\n"),
+        }, want{0, 0, 0, 0, nil, false}},
+        {"not http", []step{
+            client("\x16\x03\x01\x02\x00\x01\x00\x01\xfc\x03\x03"),
+            server("\x16\x03\x03\x00\x5a\x02\x00\x00\x56"),
+        }, want{0, 0, 0, 0, nil, true}},
+        {"lower-case method", []step{
+            client("get / HTTP/1.1\r\n\r\n"),
+        }, want{0, 0, 0, 0, nil, false}},
+        {"http/2 preface", []step{
+            client("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"),
+        }, want{0, 0, 0, 0, nil, false}},
+    }
+    for _, tt := range tests {
+        // Data arrives whole, and again one byte at a time, as
+        // reassembly may hand it over.
+        for _, split := range []bool{false, true} {
+            t.Run(fmt.Sprintf("%s/split=%v", tt.name, split), func(t *testing.T) {
+                var info Info
+                for _, s := range tt.steps {
+                    feed(&info, s, split)
+                }
+                got := want{info.RequestCount, info.ResponseCount, info.BodyBytes, info.ResponseBodyBytes, nil, info.Done()}
+                for _, r := range info.Requests {
+                    got.statuses = append(got.statuses, r.Status)
+                }
+                if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+                    t.Errorf("got  %+v\nwant %+v", got, tt.want)
+                }
+            })
+        }
+    }
+}
+
+func feed(info *Info, s step, split bool) {
+    switch {
+    case s.data == "" && s.server:
+        info.ServerGap(s.gap)
+    case s.data == "":
+        info.ClientGap(s.gap)
+    case !split:
+        if s.server {
+            info.Server([]byte(s.data))
+        } else {
+            info.Client([]byte(s.data))
+        }
+    default:
+        for i := range s.data {
+            feed(info, step{server: s.server, data: s.data[i : i+1]}, false)
+        }
+    }
+}
+
+func TestRequestMetadata(t *testing.T) {
+    var info Info
+    info.Client([]byte("POST /gate.php?id=7 HTTP/1.1\r\nHost: c2.example:8080\r\n" +
+        "User-Agent: Mozilla/4.08 (Charon; Inferno)\r\nContent-Type: application/octet-stream\r\n" +
+        "Content-Length: 3\r\n\r\nabc"))
+    info.Server([]byte("HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nContent-Length: 2\r\n\r\nok"))
+
+    want := Request{
+        Method: "POST", Host: "c2.example:8080", URI: "/gate.php?id=7",
+        UserAgent: "Mozilla/4.08 (Charon; Inferno)", ContentType: "application/octet-stream", BodySize: 3,
+        Status: 200, ResponseContentType: "text/plain", ResponseBodySize: 2,
+    }
+    if len(info.Requests) != 1 || info.Requests[0] != want {
+        t.Errorf("requests = %+v\nwant %+v", info.Requests, want)
+    }
+    if !info.Seen() {
+        t.Error("Seen() = false")
+    }
+}
+
+func TestMaxRecorded(t *testing.T) {
+    var info Info
+    for i := 0; i < maxRecorded+8; i++ {
+        info.Client([]byte("GET / HTTP/1.1\r\nContent-Length: 1\r\n\r\nx"))
+        info.Server([]byte("HTTP/1.1 200 OK\r\nContent-Length: 1\r\n\r\ny"))
+    }
+    if len(info.Requests) != maxRecorded || info.RequestCount != maxRecorded+8 || info.BodyBytes != maxRecorded+8 {
+        t.Errorf("recorded %d of %d requests, %d body bytes", len(info.Requests), info.RequestCount, info.BodyBytes)
+    }
+}
@@ -0,0 +1,180 @@
+// Package ioc matches flows against offline threat-intelligence lists of
+// IP addresses, networks, domains and JA3 hashes.
+package ioc
+
+import (
+    "fmt"
+    "net"
+    "strings"
+
+    "github.com/Tushar98644/PacketSentry/pkg/features"
+    "github.com/Tushar98644/PacketSentry/pkg/verdict"
+)
+
+// Source is the alert source of indicator matches.
+const Source = "ioc"
+
+// Kinds of indicator.
+const (
+    KindIP     = "ip"
+    KindCIDR   = "cidr"
+    KindDomain = "domain"
+    KindJA3    = "ja3"
+)
+
+// Indicator is one entry of a threat-intelligence list.
+type Indicator struct {
+    Kind        string
+    Value       string
+    Description string
+    // File is the list the indicator came from.
+    File        string
+}
+
+// Set holds loaded indicators, indexed for lookup. Networks are kept in a
+// radix tree per address family; addresses, domains and hashes in maps.
+type Set struct {
+    ips     map[string]*Indicator
+    v4, v6  radix
+    domains map[string]*Indicator
+    hashes  map[string]*Indicator
+    n       int
+}
+
+func newSet() *Set {
+    return &Set{
+        ips:     make(map[string]*Indicator),
+        domains: make(map[string]*Indicator),
+        hashes:  make(map[string]*Indicator),
+    }
+}
+
+// Len returns the number of indicators in the set.
+func (s *Set) Len() int {
+    if s == nil {
+        return 0
+    }
+    return s.n
+}
+
+// add indexes an indicator whose value parse has already checked.
+func (s *Set) add(ind *Indicator) {
+    s.n++
+    switch ind.Kind {
+    case KindIP:
+        s.ips[net.ParseIP(ind.Value).String()] = ind
+    case KindCIDR:
+        _, n, _ := net.ParseCIDR(ind.Value)
+        if n.IP.To4() != nil {
+            s.v4.insert(n, ind)
+        } else {
+            s.v6.insert(n, ind)
+        }
+    case KindDomain:
+        s.domains[ind.Value] = ind
+    case KindJA3:
+        s.hashes[ind.Value] = ind
+    }
+}
+
+// MatchIP returns the indicator listing ip, either the address itself or
+// the most specific network holding it.
+func (s *Set) MatchIP(ip net.IP) *Indicator {
+    if s == nil || ip == nil {
+        return nil
+    }
+    if ind := s.ips[ip.String()]; ind != nil {
+        return ind
+    }
+    if v4 := ip.To4(); v4 != nil {
+        return s.v4.lookup(v4)
+    }
+    return s.v6.lookup(ip.To16())
+}
+
+// MatchDomain returns the indicator listing name or a domain above it:
+// evil.example lists www.evil.example too.
+func (s *Set) MatchDomain(name string) *Indicator {
+    if s == nil {
+        return nil
+    }
+    name = strings.TrimSuffix(strings.ToLower(name), ".")
+    for name != "" {
+        if ind := s.domains[name]; ind != nil {
+            return ind
+        }
+        _, name, _ = strings.Cut(name, ".")
+    }
+    return nil
+}
+
+// MatchHash returns the indicator listing a JA3 or JA3S hash.
+func (s *Set) MatchHash(hash string) *Indicator {
+    if s == nil || hash == "" {
+        return nil
+    }
+    return s.hashes[strings.ToLower(hash)]
+}
+
+// Check matches the flow's addresses, the names it resolved or connected
+// to (DNS queries, TLS SNI and HTTP Host) and its JA3 and JA3S hashes, and
+// returns an alert for every indicator hit.
+func (s *Set) Check(ftr features.FlowFeatures) []verdict.Alert {
+    if s.Len() == 0 {
+        return nil
+    }
+    var alerts []verdict.Alert
+    seen := make(map[*Indicator]bool)
+    hit := func(what, observed string, ind *Indicator) {
+        if ind == nil || seen[ind] {
+            return
+        }
+        seen[ind] = true
+        alerts = append(alerts, verdict.Alert{Source: Source, Detail: describe(what, observed, ind), Severity: verdict.High})
+    }
+
+    hit("src_ip", ftr.SrcIP.String(), s.MatchIP(ftr.SrcIP))
+    hit("dst_ip", ftr.DstIP.String(), s.MatchIP(ftr.DstIP))
+    if ftr.DNS != nil {
+        for _, q := range ftr.DNS.Queries {
+            hit("dns query", q.Name, s.MatchDomain(q.Name))
+        }
+        for _, ip := range ftr.DNS.Answers {
+            hit("dns answer", ip.String(), s.MatchIP(ip))
+        }
+    }
+    if ftr.TLS != nil {
+        if ftr.TLS.SNI != "" {
+            hit("sni", ftr.TLS.SNI, s.MatchDomain(ftr.TLS.SNI))
+        }
+        hit("ja3", ftr.TLS.JA3, s.MatchHash(ftr.TLS.JA3))
+        hit("ja3s", ftr.TLS.JA3S, s.MatchHash(ftr.TLS.JA3S))
+    }
+    if ftr.HTTP != nil {
+        for _, r := range ftr.HTTP.Requests {
+            if host := hostOnly(r.Host); host != "" {
+                hit("http host", host, s.MatchDomain(host))
+            }
+        }
+    }
+    return alerts
+}
+
+func describe(what, observed string, ind *Indicator) string {
+    d := what + " " + observed
+    if ind.Value != observed {
+        d += " matches " + ind.Value
+    }
+    if ind.Description != "" {
+        d += " (" + ind.Description + ")"
+    }
+    return fmt.Sprintf("%s [%s]", d, ind.File)
+}
+
+// hostOnly strips the port from an HTTP Host header.
+func hostOnly(host string) string {
+    if h, _, err := net.SplitHostPort(host); err == nil {
+        return h
+    }
+    return host
+}
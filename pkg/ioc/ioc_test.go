@@ -0,0 +1,219 @@
+package ioc
+
+import (
+    "net"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/Tushar98644/PacketSentry/pkg/dns"
+    "github.com/Tushar98644/PacketSentry/pkg/features"
+    "github.com/Tushar98644/PacketSentry/pkg/httpinfo"
+    "github.com/Tushar98644/PacketSentry/pkg/tlsfp"
+)
+
+const flatList = `# test list
+203.0.113.7 known C2
+198.51.100.0/24
+2001:DB8::/32 documentation range
+Evil.Example. phishing kit
+E7D705A3286E19EA42F587B344EE6865 cobalt strike default
+`
+
+const stixList = `{
+  "type": "bundle",
+  "objects": [
+    {"type": "identity", "name": "ignored"},
+    {"type": "indicator", "name": "lokibot panel", "pattern_type": "stix",
+     "pattern": "[domain-name:value = 'panel.example' OR ipv4-addr:value = '192.0.2.10']"},
+    {"type": "indicator", "description": "trickbot hello",
+     "pattern": "[network-traffic:extensions.'tls-ext'.ja3 = '6734f37431670b3ab4292b8f60f29984']"},
+    {"type": "indicator", "name": "file hash",
+     "pattern": "[file:hashes.MD5 = 'd41d8cd98f00b204e9800998ecf8427e']"},
+    {"type": "indicator", "name": "sigma rule", "pattern_type": "sigma", "pattern": "title: x"}
+  ]
+}`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+    t.Helper()
+    path := filepath.Join(dir, name)
+    if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+func TestParse(t *testing.T) {
+    tests := []struct {
+        value, kind, want string
+    }{
+        {"203.0.113.7", KindIP, "203.0.113.7"},
+        {"2001:DB8::1", KindIP, "2001:db8::1"},
+        {"10.0.0.0/8", KindCIDR, "10.0.0.0/8"},
+        {"Evil.Example.", KindDomain, "evil.example"},
+        {"xn--80ak6aa92e.com", KindDomain, "xn--80ak6aa92e.com"},
+        {"E7D705A3286E19EA42F587B344EE6865", KindJA3, "e7d705a3286e19ea42f587b344ee6865"},
+        {"10.0.0.0/33", "", ""},
+        {"localhost", "", ""},
+        {"bad_domain-.com", "", ""},
+        {"http://evil.example/", "", ""},
+    }
+    for _, tt := range tests {
+        ind, err := parse(tt.value)
+        if tt.kind == "" {
+            if err == nil {
+                t.Errorf("parse(%q) = %+v, want an error", tt.value, ind)
+            }
+            continue
+        }
+        if err != nil || ind.Kind != tt.kind || ind.Value != tt.want {
+            t.Errorf("parse(%q) = %+v, %v; want %s %s", tt.value, ind, err, tt.kind, tt.want)
+        }
+    }
+}
+
+func TestLoad(t *testing.T) {
+    dir := t.TempDir()
+    writeFile(t, dir, "list.txt", flatList)
+    writeFile(t, dir, "intel.json", stixList)
+    writeFile(t, dir, ".hidden", "not an indicator")
+
+    s, err := Load([]string{dir})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if s.Len() != 8 {
+        t.Errorf("loaded %d indicators, want 8", s.Len())
+    }
+
+    tests := []struct {
+        name  string
+        match *Indicator
+        want  string
+        desc  string
+        file  string
+    }{
+        {"ip", s.MatchIP(net.ParseIP("203.0.113.7")), "203.0.113.7", "known C2", "list.txt"},
+        {"network", s.MatchIP(net.ParseIP("198.51.100.99")), "198.51.100.0/24", "", "list.txt"},
+        {"ipv6 network", s.MatchIP(net.ParseIP("2001:db8::5")), "2001:db8::/32", "documentation range", "list.txt"},
+        {"domain", s.MatchDomain("evil.example"), "evil.example", "phishing kit", "list.txt"},
+        {"subdomain", s.MatchDomain("WWW.Evil.Example."), "evil.example", "phishing kit", "list.txt"},
+        {"hash", s.MatchHash("E7D705A3286E19EA42F587B344EE6865"), "e7d705a3286e19ea42f587b344ee6865", "cobalt strike default", "list.txt"},
+        {"stix domain", s.MatchDomain("panel.example"), "panel.example", "lokibot panel", "intel.json"},
+        {"stix ip", s.MatchIP(net.ParseIP("192.0.2.10")), "192.0.2.10", "lokibot panel", "intel.json"},
+        {"stix ja3", s.MatchHash("6734f37431670b3ab4292b8f60f29984"), "6734f37431670b3ab4292b8f60f29984", "trickbot hello", "intel.json"},
+        {"file hash skipped", s.MatchHash("d41d8cd98f00b204e9800998ecf8427e"), "", "", ""},
+        {"unlisted", s.MatchIP(net.ParseIP("198.51.101.1")), "", "", ""},
+        {"parent domain", s.MatchDomain("example"), "", "", ""},
+    }
+    for _, tt := range tests {
+        var got Indicator
+        if tt.match != nil {
+            got = *tt.match
+        }
+        if got.Value != tt.want || got.Description != tt.desc || got.File != tt.file {
+            t.Errorf("%s: matched %+v, want %q (%q) from %q", tt.name, got, tt.want, tt.desc, tt.file)
+        }
+    }
+}
+
+func TestLoadErrors(t *testing.T) {
+    tests := []struct {
+        name, file, content, err string
+    }{
+        {"bad flat line", "list.txt", "# ok\n203.0.113.7\nnot-an-indicator\n", "list.txt:3"},
+        {"bad json", "intel.json", "{", "intel.json"},
+        {"not a bundle", "intel.json", `{"type": "indicator"}`, "not a STIX bundle"},
+        {"bad stix value", "intel.json", `{"type": "bundle", "objects": [{"type": "indicator", "name": "x",
+            "pattern": "[ipv4-addr:value = '10.0.0.0/40']"}]}`, `indicator "x"`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            path := writeFile(t, t.TempDir(), tt.file, tt.content)
+            _, err := Load([]string{path})
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Errorf("Load error = %v, want %q", err, tt.err)
+            }
+        })
+    }
+    if _, err := Load([]string{filepath.Join(t.TempDir(), "missing")}); err == nil {
+        t.Error("Load of a missing file succeeded")
+    }
+}
+
+func TestCheck(t *testing.T) {
+    dir := t.TempDir()
+    s, err := Load([]string{writeFile(t, dir, "list.txt", flatList), writeFile(t, dir, "intel.json", stixList)})
+    if err != nil {
+        t.Fatal(err)
+    }
+    ftr := features.FlowFeatures{
+        SrcIP: net.ParseIP("10.0.0.5"),
+        DstIP: net.ParseIP("203.0.113.7"),
+        DNS: &dns.Info{
+            Queries: []dns.Query{{Name: "cdn.evil.example"}, {Name: "www.evil.example"}},
+            Answers: []net.IP{net.ParseIP("192.0.2.10")},
+        },
+        TLS:  &tlsfp.Fingerprint{SNI: "panel.example", JA3: "6734f37431670b3ab4292b8f60f29984"},
+        HTTP: &httpinfo.Info{Requests: []httpinfo.Request{{Host: "evil.example:8080"}}},
+    }
+    var got []string
+    for _, a := range s.Check(ftr) {
+        got = append(got, a.Detail)
+    }
+    want := []string{
+        "dst_ip 203.0.113.7 (known C2) [list.txt]",
+        // Each indicator is reported once, for the first name it matched.
+        "dns query cdn.evil.example matches evil.example (phishing kit) [list.txt]",
+        "dns answer 192.0.2.10 (lokibot panel) [intel.json]",
+        "sni panel.example (lokibot panel) [intel.json]",
+        "ja3 6734f37431670b3ab4292b8f60f29984 (trickbot hello) [intel.json]",
+    }
+    if strings.Join(got, "\n") != strings.Join(want, "\n") {
+        t.Errorf("alerts:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+    }
+
+    var empty *Set
+    if alerts := empty.Check(ftr); alerts != nil {
+        t.Errorf("a nil set raised %v", alerts)
+    }
+}
+
+func TestStoreReload(t *testing.T) {
+    dir := t.TempDir()
+    path := writeFile(t, dir, "list.txt", "203.0.113.7\n")
+    st, err := Open([]string{dir})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if ok, err := st.Reload(); ok || err != nil {
+        t.Fatalf("Reload of unchanged files = %v, %v", ok, err)
+    }
+
+    // A half-written list keeps the indicators loaded before.
+    writeFile(t, dir, "list.txt", "203.0.113.7\n198.51.100.0/\n")
+    if ok, err := st.Reload(); ok || err == nil {
+        t.Fatalf("Reload of a bad list = %v, %v", ok, err)
+    }
+    if st.Set().Len() != 1 {
+        t.Errorf("%d indicators kept, want 1", st.Set().Len())
+    }
+
+    writeFile(t, dir, "list.txt", "203.0.113.7\n198.51.100.0/24\n")
+    // Make sure the modification time moves even on coarse file systems.
+    later := time.Now().Add(time.Minute)
+    os.Chtimes(path, later, later)
+    if ok, err := st.Reload(); !ok || err != nil {
+        t.Fatalf("Reload of a changed list = %v, %v", ok, err)
+    }
+    if st.Set().Len() != 2 {
+        t.Errorf("%d indicators after reload, want 2", st.Set().Len())
+    }
+
+    writeFile(t, dir, "more.txt", "evil.example\n")
+    if ok, _ := st.Reload(); !ok || st.Set().Len() != 3 {
+        t.Errorf("Reload after adding a file = %v with %d indicators", ok, st.Set().Len())
+    }
+}
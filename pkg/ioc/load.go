@@ -0,0 +1,179 @@
+package ioc
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "net"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+var (
+    md5Pattern    = regexp.MustCompile(`^[0-9a-f]{32}$`)
+    domainPattern = regexp.MustCompile(`^([a-z0-9_]([a-z0-9_-]*[a-z0-9_])?\.)*[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+    // stixComparison matches one "object:path = 'value'" comparison of a
+    // STIX pattern.
+    stixComparison = regexp.MustCompile(`([a-z0-9-]+):([^\s=\]]+)\s*=\s*'((?:[^'\\]|\\.)*)'`)
+)
+
+// Load reads the indicator lists at paths. A directory stands for every
+// file in it. Files ending in .json are STIX 2 bundles; any other file is
+// a flat list.
+func Load(paths []string) (*Set, error) {
+    files, err := expand(paths)
+    if err != nil {
+        return nil, err
+    }
+    s := newSet()
+    for _, file := range files {
+        if strings.EqualFold(filepath.Ext(file), ".json") {
+            err = loadSTIX(s, file)
+        } else {
+            err = loadFlat(s, file)
+        }
+        if err != nil {
+            return nil, err
+        }
+    }
+    return s, nil
+}
+
+// expand replaces directories in paths by the files in them, in name
+// order.
+func expand(paths []string) ([]string, error) {
+    var files []string
+    for _, p := range paths {
+        info, err := os.Stat(p)
+        if err != nil {
+            return nil, fmt.Errorf("open indicators: %w", err)
+        }
+        if !info.IsDir() {
+            files = append(files, p)
+            continue
+        }
+        entries, err := os.ReadDir(p)
+        if err != nil {
+            return nil, fmt.Errorf("open indicators: %w", err)
+        }
+        var names []string
+        for _, e := range entries {
+            if e.Type().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+                names = append(names, filepath.Join(p, e.Name()))
+            }
+        }
+        sort.Strings(names)
+        files = append(files, names...)
+    }
+    return files, nil
+}
+
+// loadFlat reads a flat list: one IP address, CIDR network, domain or JA3
+// hash per line, optionally followed by a description. Blank lines and
+// lines starting with # are skipped.
+func loadFlat(s *Set, path string) error {
+    f, err := os.Open(path)
+    if err != nil {
+        return fmt.Errorf("open indicators: %w", err)
+    }
+    defer f.Close()
+
+    sc := bufio.NewScanner(f)
+    for n := 1; sc.Scan(); n++ {
+        line := strings.TrimSpace(sc.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        value, desc, _ := strings.Cut(line, " ")
+        ind, err := parse(value)
+        if err != nil {
+            return fmt.Errorf("%s:%d: %w", path, n, err)
+        }
+        ind.Description, ind.File = strings.TrimSpace(desc), filepath.Base(path)
+        s.add(ind)
+    }
+    if err := sc.Err(); err != nil {
+        return fmt.Errorf("read %s: %w", path, err)
+    }
+    return nil
+}
+
+// parse works out the kind of an indicator from its value.
+func parse(value string) (*Indicator, error) {
+    v := strings.ToLower(strings.TrimSpace(value))
+    switch {
+    case net.ParseIP(v) != nil:
+        return &Indicator{Kind: KindIP, Value: v}, nil
+    case strings.Contains(v, "/"):
+        if _, _, err := net.ParseCIDR(v); err != nil {
+            return nil, fmt.Errorf("%q is not a CIDR network", value)
+        }
+        return &Indicator{Kind: KindCIDR, Value: v}, nil
+    case md5Pattern.MatchString(v):
+        return &Indicator{Kind: KindJA3, Value: v}, nil
+    }
+    v = strings.TrimSuffix(v, ".")
+    if !strings.Contains(v, ".") || !domainPattern.MatchString(v) {
+        return nil, fmt.Errorf("%q is not an IP address, CIDR network, domain or JA3 hash", value)
+    }
+    return &Indicator{Kind: KindDomain, Value: v}, nil
+}
+
+// stixBundle is the part of a STIX 2 bundle that is read.
+type stixBundle struct {
+    Type    string `json:"type"`
+    Objects []struct {
+        Type        string `json:"type"`
+        Name        string `json:"name"`
+        Description string `json:"description"`
+        Pattern     string `json:"pattern"`
+        PatternType string `json:"pattern_type"`
+    } `json:"objects"`
+}
+
+// loadSTIX reads the indicator objects of a STIX 2 bundle. Every equality
+// comparison in a pattern on an ipv4-addr, ipv6-addr or domain-name value,
+// or on a property whose path names JA3, becomes an indicator of its own;
+// other comparisons and objects are skipped.
+func loadSTIX(s *Set, path string) error {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return fmt.Errorf("open indicators: %w", err)
+    }
+    var b stixBundle
+    if err := json.Unmarshal(data, &b); err != nil {
+        return fmt.Errorf("%s: %w", path, err)
+    }
+    if b.Type != "bundle" {
+        return fmt.Errorf("%s: not a STIX bundle", path)
+    }
+
+    for _, o := range b.Objects {
+        if o.Type != "indicator" || (o.PatternType != "" && o.PatternType != "stix") {
+            continue
+        }
+        desc := o.Name
+        if desc == "" {
+            desc = o.Description
+        }
+        for _, m := range stixComparison.FindAllStringSubmatch(o.Pattern, -1) {
+            object, prop, value := m[1], strings.ToLower(m[2]), strings.ReplaceAll(m[3], `\'`, `'`)
+            switch {
+            case (object == "ipv4-addr" || object == "ipv6-addr" || object == "domain-name") && prop == "value":
+            case strings.Contains(prop, "ja3"):
+            default:
+                continue
+            }
+            ind, err := parse(value)
+            if err != nil {
+                return fmt.Errorf("%s: indicator %q: %w", path, o.Name, err)
+            }
+            ind.Description, ind.File = desc, filepath.Base(path)
+            s.add(ind)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,86 @@
+package ioc
+
+import "net"
+
+// radix is a path-compressed binary trie of IP prefixes, one per address
+// family. Lookups return the longest prefix holding an address, so a
+// specific entry wins over the network around it.
+type radix struct {
+    root *radixNode
+}
+
+type radixNode struct {
+    // key holds the node's prefix bits, left-aligned; bits is its length.
+    key   []byte
+    bits  int
+    child [2]*radixNode
+    // ind is nil for nodes that only join two branches.
+    ind   *Indicator
+}
+
+// insert stores ind under network n. A later insert of the same network
+// replaces the earlier one.
+func (t *radix) insert(n *net.IPNet, ind *Indicator) {
+    ones, _ := n.Mask.Size()
+    key := n.IP.Mask(n.Mask)
+    at := &t.root
+    for {
+        node := *at
+        if node == nil {
+            *at = &radixNode{key: key, bits: ones, ind: ind}
+            return
+        }
+        common := commonBits(key, node.key, min(ones, node.bits))
+        if common < node.bits {
+            // Split the node where the new prefix leaves it.
+            parent := &radixNode{key: key, bits: common}
+            parent.child[bit(node.key, common)] = node
+            if common == ones {
+                parent.ind = ind
+            } else {
+                parent.child[bit(key, common)] = &radixNode{key: key, bits: ones, ind: ind}
+            }
+            *at = parent
+            return
+        }
+        if ones == node.bits {
+            node.ind = ind
+            return
+        }
+        at = &node.child[bit(key, node.bits)]
+    }
+}
+
+// lookup returns the indicator of the longest prefix holding ip, or nil.
+func (t *radix) lookup(ip net.IP) *Indicator {
+    var found *Indicator
+    node := t.root
+    for node != nil {
+        if commonBits(ip, node.key, node.bits) < node.bits {
+            break
+        }
+        if node.ind != nil {
+            found = node.ind
+        }
+        if node.bits == len(ip)*8 {
+            break
+        }
+        node = node.child[bit(ip, node.bits)]
+    }
+    return found
+}
+
+// bit returns bit i of key, counting from the most significant.
+func bit(key []byte, i int) int {
+    return int(key[i/8]>>(7-i%8)) & 1
+}
+
+// commonBits returns how many of the first n bits a and b share.
+func commonBits(a, b []byte, n int) int {
+    for i := 0; i < n; i++ {
+        if bit(a, i) != bit(b, i) {
+            return i
+        }
+    }
+    return n
+}
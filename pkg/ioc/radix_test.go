@@ -0,0 +1,103 @@
+package ioc
+
+import (
+    "math/rand"
+    "net"
+    "testing"
+)
+
+func TestRadix(t *testing.T) {
+    tests := []struct {
+        name     string
+        networks []string
+        lookups  map[string]string
+    }{
+        {"single", []string{"10.0.0.0/8"}, map[string]string{
+            "10.1.2.3": "10.0.0.0/8", "11.0.0.1": "",
+        }},
+        {"more specific below", []string{"10.0.0.0/8", "10.1.0.0/16"}, map[string]string{
+            "10.1.2.3": "10.1.0.0/16", "10.2.0.1": "10.0.0.0/8",
+        }},
+        // The /16 is inserted first, so the /8 splits it and takes the
+        // new parent's place.
+        {"split above", []string{"10.1.0.0/16", "10.0.0.0/8"}, map[string]string{
+            "10.1.2.3": "10.1.0.0/16", "10.2.0.1": "10.0.0.0/8", "9.0.0.1": "",
+        }},
+        // Siblings split at the first bit they differ in, leaving a node
+        // without an indicator that a lookup must pass through.
+        {"split between siblings", []string{"192.168.1.0/24", "192.168.2.0/24", "192.168.3.128/25"}, map[string]string{
+            "192.168.1.7": "192.168.1.0/24", "192.168.2.7": "192.168.2.0/24",
+            "192.168.3.200": "192.168.3.128/25", "192.168.3.1": "", "192.168.0.1": "",
+        }},
+        {"host route", []string{"10.0.0.0/8", "10.9.9.9/32"}, map[string]string{
+            "10.9.9.9": "10.9.9.9/32", "10.9.9.8": "10.0.0.0/8",
+        }},
+        {"default route", []string{"0.0.0.0/0", "10.0.0.0/8"}, map[string]string{
+            "8.8.8.8": "0.0.0.0/0", "10.0.0.1": "10.0.0.0/8",
+        }},
+        {"replace", []string{"10.0.0.0/8", "10.0.0.0/8"}, map[string]string{
+            "10.0.0.1": "10.0.0.0/8",
+        }},
+        {"ipv6", []string{"2001:db8::/32", "2001:db8:1::/48"}, map[string]string{
+            "2001:db8:1::1": "2001:db8:1::/48", "2001:db8:2::1": "2001:db8::/32", "2001:db9::1": "",
+        }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var r radix
+            for _, s := range tt.networks {
+                _, n, err := net.ParseCIDR(s)
+                if err != nil {
+                    t.Fatal(err)
+                }
+                r.insert(n, &Indicator{Kind: KindCIDR, Value: s})
+            }
+            for addr, want := range tt.lookups {
+                ip := net.ParseIP(addr)
+                if v4 := ip.To4(); v4 != nil {
+                    ip = v4
+                }
+                var got string
+                if ind := r.lookup(ip); ind != nil {
+                    got = ind.Value
+                }
+                if got != want {
+                    t.Errorf("lookup(%s) = %q, want %q", addr, got, want)
+                }
+            }
+        })
+    }
+}
+
+// TestRadixRandom checks lookups against a linear scan for the longest
+// matching prefix, over networks inserted in random order.
+func TestRadixRandom(t *testing.T) {
+    rng := rand.New(rand.NewSource(1))
+    var r radix
+    var nets []*net.IPNet
+    for i := 0; i < 500; i++ {
+        // Few distinct top bits, so that prefixes nest and split often.
+        ip := net.IPv4(10, byte(rng.Intn(4)), byte(rng.Intn(256)), byte(rng.Intn(256))).To4()
+        bits := 8 + rng.Intn(25)
+        n := &net.IPNet{IP: ip.Mask(net.CIDRMask(bits, 32)), Mask: net.CIDRMask(bits, 32)}
+        nets = append(nets, n)
+        r.insert(n, &Indicator{Kind: KindCIDR, Value: n.String()})
+    }
+
+    for i := 0; i < 5000; i++ {
+        ip := net.IPv4(10, byte(rng.Intn(4)), byte(rng.Intn(256)), byte(rng.Intn(256))).To4()
+        want, best := "", -1
+        for _, n := range nets {
+            if ones, _ := n.Mask.Size(); n.Contains(ip) && ones > best {
+                want, best = n.String(), ones
+            }
+        }
+        var got string
+        if ind := r.lookup(ip); ind != nil {
+            got = ind.Value
+        }
+        if got != want {
+            t.Fatalf("lookup(%s) = %q, want %q", ip, got, want)
+        }
+    }
+}
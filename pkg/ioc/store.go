@@ -0,0 +1,85 @@
+package ioc
+
+import (
+    "os"
+    "time"
+)
+
+// Store keeps the indicators loaded from a list of paths and reloads them
+// when the files change on disk.
+type Store struct {
+    paths  []string
+    set    *Set
+    stamps map[string]stamp
+}
+
+// stamp identifies a version of a file.
+type stamp struct {
+    mod  time.Time
+    size int64
+}
+
+// Open loads the indicators at paths; see Load.
+func Open(paths []string) (*Store, error) {
+    st := &Store{paths: paths}
+    if _, err := st.Reload(); err != nil {
+        return nil, err
+    }
+    return st, nil
+}
+
+// Set returns the indicators currently loaded.
+func (st *Store) Set() *Set {
+    if st == nil {
+        return nil
+    }
+    return st.set
+}
+
+// Reload loads the indicators again if a file was added, removed or
+// modified since the last load, and reports whether it did. On error the
+// indicators loaded before are kept, so that a list caught half-written
+// does not empty the set.
+func (st *Store) Reload() (bool, error) {
+    stamps, err := st.stat()
+    if err != nil {
+        return false, err
+    }
+    if st.set != nil && sameStamps(stamps, st.stamps) {
+        return false, nil
+    }
+    set, err := Load(st.paths)
+    if err != nil {
+        return false, err
+    }
+    st.set, st.stamps = set, stamps
+    return true, nil
+}
+
+func (st *Store) stat() (map[string]stamp, error) {
+    files, err := expand(st.paths)
+    if err != nil {
+        return nil, err
+    }
+    stamps := make(map[string]stamp, len(files))
+    for _, f := range files {
+        info, err := os.Stat(f)
+        if err != nil {
+            return nil, err
+        }
+        stamps[f] = stamp{mod: info.ModTime(), size: info.Size()}
+    }
+    return stamps, nil
+}
+
+func sameStamps(a, b map[string]stamp) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for f, s := range a {
+        if t, ok := b[f]; !ok || !t.mod.Equal(s.mod) || t.size != s.size {
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,86 @@
+package output
+
+import (
+    "fmt"
+    "html"
+    "os"
+    "strconv"
+
+    "github.com/go-echarts/go-echarts/v2/charts"
+    "github.com/go-echarts/go-echarts/v2/opts"
+    "github.com/go-echarts/go-echarts/v2/types"
+
+    "github.com/Tushar98644/PacketSentry/pkg/verdict"
+)
+
+// severityColors are the chart bar colours for each severity.
+var severityColors = map[verdict.Severity]string{
+    verdict.None:     "#91cc75",
+    verdict.Low:      "#fac858",
+    verdict.Medium:   "#fc8452",
+    verdict.High:     "#ee6666",
+    verdict.Critical: "#9a1b1b",
+}
+
+// ChartWriter collects one bar per scored flow and renders the HTML
+// probability chart when it is closed.
+type ChartWriter struct {
+    path    string
+    data    []opts.BarData
+    xLabels []string
+}
+
+// NewChartWriter returns a writer that renders its chart to path on Close.
+func NewChartWriter(path string) *ChartWriter {
+    return &ChartWriter{path: path}
+}
+
+func (cw *ChartWriter) Write(r Result) error {
+    ftr := r.Features
+
+    // Per-bar tooltip listing the flow's severity, alerts and why the model
+    // scored it as it did; bars are coloured by severity.
+    tip := fmt.Sprintf("{b}: {c} (%s)<br/>%s:%d -> %s:%d (%s)", r.Severity, ftr.SrcIP, ftr.SrcPort, ftr.DstIP, ftr.DstPort, ftr.Protocol)
+    if ftr.TLS != nil && ftr.TLS.SNI != "" {
+        // The SNI is chosen by the client, and the tooltip is HTML.
+        tip += "<br/>SNI " + html.EscapeString(ftr.TLS.SNI)
+    }
+    for _, a := range r.Alerts {
+        tip += "<br/>" + html.EscapeString(a.String())
+    }
+    for _, c := range r.Contributions {
+        tip += fmt.Sprintf("<br/>%s: %+.3f", c.Feature, c.Value)
+    }
+    cw.data = append(cw.data, opts.BarData{
+        Value:     r.Probability,
+        ItemStyle: &opts.ItemStyle{Color: severityColors[r.Severity]},
+        Tooltip:   &opts.Tooltip{Show: opts.Bool(true), Formatter: types.FuncStr(tip)},
+    })
+    cw.xLabels = append(cw.xLabels, "Flow "+strconv.Itoa(r.FlowID))
+    return nil
+}
+
+// Close renders the chart.
+func (cw *ChartWriter) Close() error {
+    bar := charts.NewBar()
+    bar.SetGlobalOptions(
+        charts.WithTitleOpts(opts.Title{
+            Title: "Flow Probability Chart",
+        }),
+        charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "item"}),
+        charts.WithYAxisOpts(opts.YAxis{Name: "Probability"}),
+        charts.WithXAxisOpts(opts.XAxis{Name: "Flow ID"}),
+    )
+    bar.SetXAxis(cw.xLabels).
+        AddSeries("Malicious Probability", cw.data)
+
+    f, err := os.Create(cw.path)
+    if err != nil {
+        return fmt.Errorf("could not create chart file: %w", err)
+    }
+    if err := bar.Render(f); err != nil {
+        f.Close()
+        return fmt.Errorf("chart rendering failed: %w", err)
+    }
+    return f.Close()
+}
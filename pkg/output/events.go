@@ -0,0 +1,79 @@
+package output
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "os"
+    "time"
+
+    "github.com/Tushar98644/PacketSentry/pkg/scan"
+)
+
+// EventsPath returns the file host-level events are written to for the
+// results base path.
+func EventsPath(base string) string {
+    return base + "_events.ndjson"
+}
+
+// eventRecord is one line of the events file.
+type eventRecord struct {
+    Kind     string    `json:"kind"`
+    Src      string    `json:"src_ip,omitempty"`
+    Dst      string    `json:"dst_ip,omitempty"`
+    Port     uint16    `json:"dst_port,omitempty"`
+    Protocol string    `json:"protocol"`
+    Count    int       `json:"count"`
+    Targets  []string  `json:"targets"`
+    First    time.Time `json:"first"`
+    Last     time.Time `json:"last"`
+    Severity string    `json:"severity"`
+}
+
+// EventsWriter writes scans, sweeps and floods, one JSON object per line.
+// Unlike results they describe many flows, so they have a file of their
+// own.
+type EventsWriter struct {
+    f   *os.File
+    w   *bufio.Writer
+    enc *json.Encoder
+}
+
+// NewEventsWriter creates the events file at path.
+func NewEventsWriter(path string) (*EventsWriter, error) {
+    f, err := os.Create(path)
+    if err != nil {
+        return nil, fmt.Errorf("could not create events file: %w", err)
+    }
+    w := bufio.NewWriter(f)
+    return &EventsWriter{f: f, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+// Write appends one event and flushes it to disk.
+func (ew *EventsWriter) Write(e scan.Event) error {
+    rec := eventRecord{
+        Kind:     e.Kind,
+        Src:      e.Src,
+        Dst:      e.Dst,
+        Port:     e.Port,
+        Protocol: e.Protocol,
+        Count:    e.Count,
+        Targets:  e.Targets,
+        First:    e.First.UTC(),
+        Last:     e.Last.UTC(),
+        Severity: e.Severity.String(),
+    }
+    if err := ew.enc.Encode(rec); err != nil {
+        return fmt.Errorf("could not write %s event: %w", e.Kind, err)
+    }
+    return ew.w.Flush()
+}
+
+// Close flushes any buffered lines and closes the file.
+func (ew *EventsWriter) Close() error {
+    if err := ew.w.Flush(); err != nil {
+        ew.f.Close()
+        return err
+    }
+    return ew.f.Close()
+}
@@ -0,0 +1,82 @@
+package output
+
+import (
+    "encoding/csv"
+    "fmt"
+    "os"
+    "strconv"
+    "time"
+
+    "github.com/Tushar98644/PacketSentry/pkg/host"
+)
+
+// HostsPath returns the file host profiles are written to for the results
+// base path.
+func HostsPath(base string) string {
+    return base + "_hosts.csv"
+}
+
+// WriteHostsCSV writes one row per host profile to a CSV file at path.
+// Unlike results the profiles change with every flow, so the table is
+// written once, when the outputs are finished.
+func WriteHostsCSV(path string, profiles []host.Profile) error {
+    f, err := os.Create(path)
+    if err != nil {
+        return fmt.Errorf("could not create hosts file: %w", err)
+    }
+
+    w := csv.NewWriter(f)
+    if err := w.Write(hostsHeader()); err != nil {
+        f.Close()
+        return fmt.Errorf("could not write header: %w", err)
+    }
+    for _, p := range profiles {
+        if err := w.Write(hostsRow(p)); err != nil {
+            f.Close()
+            return fmt.Errorf("could not write row: %w", err)
+        }
+    }
+    w.Flush()
+    if err := w.Error(); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+func hostsHeader() []string {
+    header := []string{
+        "Host", "FirstSeen", "LastSeen", "Flows", "OutboundFlows", "BytesOut", "BytesIn",
+        "Destinations", "FanOut", "NewDstRate",
+        "TCPFlows", "UDPFlows", "TLSFlows", "DNSFlows", "HTTPFlows",
+    }
+    for h := 0; h < 24; h++ {
+        header = append(header, fmt.Sprintf("Hour%02d", h))
+    }
+    return header
+}
+
+func hostsRow(p host.Profile) []string {
+    itoa := strconv.Itoa
+    row := []string{
+        p.Host,
+        p.First.UTC().Format(time.RFC3339Nano),
+        p.Last.UTC().Format(time.RFC3339Nano),
+        itoa(p.Flows),
+        itoa(p.Outbound),
+        strconv.FormatInt(p.BytesOut, 10),
+        strconv.FormatInt(p.BytesIn, 10),
+        itoa(p.Destinations),
+        itoa(p.FanOut),
+        fmt.Sprintf("%.3f", p.NewDstRate),
+        itoa(p.TCP),
+        itoa(p.UDP),
+        itoa(p.TLS),
+        itoa(p.DNS),
+        itoa(p.HTTP),
+    }
+    for _, n := range p.Hours {
+        row = append(row, itoa(n))
+    }
+    return row
+}
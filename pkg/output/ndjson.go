@@ -0,0 +1,200 @@
+package output
+
+import (
+    "bufio"
+    "crypto/tls"
+    "encoding/json"
+    "fmt"
+    "math"
+    "os"
+    "time"
+
+    "github.com/Tushar98644/PacketSentry/pkg/features"
+    "github.com/Tushar98644/PacketSentry/pkg/tlsfp"
+)
+
+// ndjsonRecord is one line of the NDJSON results. Feature values are keyed
+// by their column name, as in the CSV files and model bundles.
+type ndjsonRecord struct {
+    FlowID        int                 `json:"flow_id"`
+    SrcIP         string              `json:"src_ip"`
+    DstIP         string              `json:"dst_ip"`
+    SrcPort       uint16              `json:"src_port"`
+    DstPort       uint16              `json:"dst_port"`
+    Protocol      string              `json:"protocol"`
+    Start         time.Time           `json:"start"`
+    End           time.Time           `json:"end"`
+    EndReason     string              `json:"end_reason,omitempty"`
+    Features      map[string]*float64 `json:"features"`
+    TLS           *ndjsonTLS          `json:"tls,omitempty"`
+    DNS           *ndjsonDNS          `json:"dns,omitempty"`
+    HTTP          []ndjsonHTTP        `json:"http,omitempty"`
+    Probability   float64             `json:"probability"`
+    Label         string              `json:"label"`
+    Severity      string              `json:"severity"`
+    Alerts        []ndjsonAlert       `json:"alerts,omitempty"`
+    Contributions []ndjsonContrib     `json:"contributions,omitempty"`
+}
+
+// ndjsonTLS is the TLS handshake of a flow; cipher suites are written by
+// their IANA names.
+type ndjsonTLS struct {
+    SNI          string   `json:"sni,omitempty"`
+    Version      string   `json:"version,omitempty"`
+    ALPN         []string `json:"alpn,omitempty"`
+    CipherSuites []string `json:"cipher_suites,omitempty"`
+    CipherSuite  string   `json:"cipher_suite,omitempty"`
+    JA3          string   `json:"ja3,omitempty"`
+    JA3S         string   `json:"ja3s,omitempty"`
+    JA4          string   `json:"ja4,omitempty"`
+}
+
+type ndjsonDNS struct {
+    Queries  []ndjsonQuery  `json:"queries,omitempty"`
+    Answers  []string       `json:"answers,omitempty"`
+    RCodes   map[string]int `json:"rcodes,omitempty"`
+    DGAName  string         `json:"dga_name,omitempty"`
+    DGAScore float64        `json:"dga_score"`
+}
+
+type ndjsonQuery struct {
+    Name string `json:"name"`
+    Type string `json:"type"`
+}
+
+// ndjsonHTTP is one HTTP request and its response.
+type ndjsonHTTP struct {
+    Method              string `json:"method"`
+    Host                string `json:"host,omitempty"`
+    URI                 string `json:"uri"`
+    UserAgent           string `json:"user_agent,omitempty"`
+    ContentType         string `json:"content_type,omitempty"`
+    BodyBytes           int64  `json:"body_bytes"`
+    Status              int    `json:"status,omitempty"`
+    ResponseContentType string `json:"response_content_type,omitempty"`
+    ResponseBodyBytes   int64  `json:"response_body_bytes"`
+}
+
+type ndjsonAlert struct {
+    Source   string `json:"source"`
+    Detail   string `json:"detail"`
+    Severity string `json:"severity"`
+}
+
+type ndjsonContrib struct {
+    Feature string  `json:"feature"`
+    Value   float64 `json:"value"`
+}
+
+// NDJSONWriter writes one JSON object per scored flow and line, ready for
+// jq or a bulk loader.
+type NDJSONWriter struct {
+    f   *os.File
+    w   *bufio.Writer
+    enc *json.Encoder
+}
+
+// NewNDJSONWriter creates the NDJSON file at path.
+func NewNDJSONWriter(path string) (*NDJSONWriter, error) {
+    f, err := os.Create(path)
+    if err != nil {
+        return nil, fmt.Errorf("could not create NDJSON file: %w", err)
+    }
+    w := bufio.NewWriter(f)
+    return &NDJSONWriter{f: f, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+// Write appends one result and flushes it to disk.
+func (nw *NDJSONWriter) Write(r Result) error {
+    ftr := r.Features
+    rec := ndjsonRecord{
+        FlowID:      r.FlowID,
+        SrcIP:       ftr.SrcIP.String(),
+        DstIP:       ftr.DstIP.String(),
+        SrcPort:     ftr.SrcPort,
+        DstPort:     ftr.DstPort,
+        Protocol:    ftr.Protocol,
+        Start:       ftr.Start.UTC(),
+        End:         ftr.End.UTC(),
+        EndReason:   ftr.EndReason,
+        Features:    featureValues(ftr),
+        Probability: r.Probability,
+        Label:       r.Label,
+        Severity:    r.Severity.String(),
+    }
+    if fp := ftr.TLS; fp != nil {
+        rec.TLS = &ndjsonTLS{
+            SNI:     fp.SNI,
+            Version: tlsfp.VersionName(fp.Version),
+            ALPN:    fp.ALPN,
+            JA3:     fp.JA3,
+            JA3S:    fp.JA3S,
+            JA4:     fp.JA4,
+        }
+        for _, cs := range fp.CipherSuites {
+            rec.TLS.CipherSuites = append(rec.TLS.CipherSuites, tls.CipherSuiteName(cs))
+        }
+        if fp.CipherSuite != 0 {
+            rec.TLS.CipherSuite = tls.CipherSuiteName(fp.CipherSuite)
+        }
+    }
+    if d := ftr.DNS; d != nil {
+        rec.DNS = &ndjsonDNS{RCodes: d.RCodes, DGAName: d.DGAName, DGAScore: d.DGAScore}
+        for _, q := range d.Queries {
+            rec.DNS.Queries = append(rec.DNS.Queries, ndjsonQuery{Name: q.Name, Type: q.Type})
+        }
+        for _, ip := range d.Answers {
+            rec.DNS.Answers = append(rec.DNS.Answers, ip.String())
+        }
+    }
+    if h := ftr.HTTP; h != nil {
+        for _, req := range h.Requests {
+            rec.HTTP = append(rec.HTTP, ndjsonHTTP{
+                Method:              req.Method,
+                Host:                req.Host,
+                URI:                 req.URI,
+                UserAgent:           req.UserAgent,
+                ContentType:         req.ContentType,
+                BodyBytes:           req.BodySize,
+                Status:              req.Status,
+                ResponseContentType: req.ResponseContentType,
+                ResponseBodyBytes:   req.ResponseBodySize,
+            })
+        }
+    }
+    for _, a := range r.Alerts {
+        rec.Alerts = append(rec.Alerts, ndjsonAlert{Source: a.Source, Detail: a.Detail, Severity: a.Severity.String()})
+    }
+    for _, c := range r.Contributions {
+        rec.Contributions = append(rec.Contributions, ndjsonContrib{Feature: c.Feature, Value: c.Value})
+    }
+
+    if err := nw.enc.Encode(rec); err != nil {
+        return fmt.Errorf("could not write flow %d: %w", r.FlowID, err)
+    }
+    return nw.w.Flush()
+}
+
+// Close flushes any buffered lines and closes the file.
+func (nw *NDJSONWriter) Close() error {
+    if err := nw.w.Flush(); err != nil {
+        nw.f.Close()
+        return err
+    }
+    return nw.f.Close()
+}
+
+// featureValues maps every feature column to its value. JSON has no NaN or
+// infinity, so those are written as null.
+func featureValues(ftr features.FlowFeatures) map[string]*float64 {
+    vals := make(map[string]*float64)
+    for _, c := range features.Columns() {
+        v := c.Value(ftr)
+        if math.IsNaN(v) || math.IsInf(v, 0) {
+            vals[c.Name] = nil
+            continue
+        }
+        vals[c.Name] = &v
+    }
+    return vals
+}
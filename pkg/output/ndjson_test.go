@@ -0,0 +1,131 @@
+package output
+
+import (
+    "bytes"
+    "encoding/json"
+    "math"
+    "net"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/Tushar98644/PacketSentry/internal/ml"
+    "github.com/Tushar98644/PacketSentry/pkg/features"
+    "github.com/Tushar98644/PacketSentry/pkg/verdict"
+)
+
+func TestNDJSONWriter(t *testing.T) {
+    // Timestamps are written in UTC whatever zone they were read in.
+    start := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.FixedZone("CEST", 2*60*60))
+    ftr := features.FlowFeatures{
+        SrcIP:    net.ParseIP("10.0.0.1"),
+        DstIP:    net.ParseIP("2001:db8::1"),
+        SrcPort:  40000,
+        DstPort:  443,
+        Protocol: "TCP",
+        Start:    start,
+        End:      start.Add(1500 * time.Millisecond),
+    }
+    ftr.PacketStats.Count, ftr.PacketStats.Sum = 3, 180
+    // JSON has no NaN or infinity; they must not break the line.
+    ftr.PacketStats.Mean = math.NaN()
+    ftr.PacketStats.Std = math.Inf(1)
+
+    results := []Result{
+        {FlowID: 1, Features: ftr, Probability: 0.91, Label: "malicious", Severity: verdict.High,
+            Contributions: []ml.Contribution{{Feature: "PktSum", Value: 1.5}}},
+        {FlowID: 2, Features: ftr, Probability: 0.125, Label: "benign"},
+    }
+    path := filepath.Join(t.TempDir(), "results.ndjson")
+    w, err := NewNDJSONWriter(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, r := range results {
+        if err := w.Write(r); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := w.Close(); err != nil {
+        t.Fatal(err)
+    }
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+    if len(lines) != len(results) {
+        t.Fatalf("%d lines, want %d:\n%s", len(lines), len(results), data)
+    }
+
+    for i, line := range lines {
+        want := results[i]
+        var rec struct {
+            FlowID        int                 `json:"flow_id"`
+            SrcIP         string              `json:"src_ip"`
+            DstIP         string              `json:"dst_ip"`
+            SrcPort       uint16              `json:"src_port"`
+            DstPort       uint16              `json:"dst_port"`
+            Protocol      string              `json:"protocol"`
+            Start         string              `json:"start"`
+            End           string              `json:"end"`
+            Features      map[string]*float64 `json:"features"`
+            Probability   float64             `json:"probability"`
+            Label         string              `json:"label"`
+            Severity      string              `json:"severity"`
+            Contributions []struct {
+                Feature string  `json:"feature"`
+                Value   float64 `json:"value"`
+            } `json:"contributions"`
+        }
+        if err := json.Unmarshal(line, &rec); err != nil {
+            t.Fatalf("line %d: %v\n%s", i+1, err, line)
+        }
+
+        if rec.FlowID != want.FlowID || rec.SrcIP != "10.0.0.1" || rec.DstIP != "2001:db8::1" ||
+            rec.SrcPort != 40000 || rec.DstPort != 443 || rec.Protocol != "TCP" {
+            t.Errorf("line %d: flow %d %s:%d -> %s:%d %s", i+1, rec.FlowID, rec.SrcIP, rec.SrcPort, rec.DstIP, rec.DstPort, rec.Protocol)
+        }
+        if rec.Start != "2024-05-01T10:00:00.123456789Z" || rec.End != "2024-05-01T10:00:01.623456789Z" {
+            t.Errorf("line %d: start %s, end %s", i+1, rec.Start, rec.End)
+        }
+        for _, ts := range []string{rec.Start, rec.End} {
+            if _, err := time.Parse(time.RFC3339, ts); err != nil {
+                t.Errorf("line %d: %v", i+1, err)
+            }
+        }
+        if rec.Probability != want.Probability || rec.Label != want.Label || rec.Severity != want.Severity.String() {
+            t.Errorf("line %d: probability %v, label %q, severity %q", i+1, rec.Probability, rec.Label, rec.Severity)
+        }
+        if len(rec.Contributions) != len(want.Contributions) {
+            t.Errorf("line %d: %d contributions, want %d", i+1, len(rec.Contributions), len(want.Contributions))
+        }
+
+        if len(rec.Features) != len(features.Columns()) {
+            t.Errorf("line %d: %d features, want %d", i+1, len(rec.Features), len(features.Columns()))
+        }
+        nulls := 0
+        for _, c := range features.Columns() {
+            got, ok := rec.Features[c.Name]
+            v := c.Value(ftr)
+            switch {
+            case !ok:
+                t.Errorf("line %d: no feature %s", i+1, c.Name)
+            case math.IsNaN(v) || math.IsInf(v, 0):
+                nulls++
+                if got != nil {
+                    t.Errorf("line %d: %s = %v, want null", i+1, c.Name, *got)
+                }
+            case got == nil:
+                t.Errorf("line %d: %s = null, want %v", i+1, c.Name, v)
+            case *got != v:
+                t.Errorf("line %d: %s = %v, want %v", i+1, c.Name, *got, v)
+            }
+        }
+        if nulls < 2 {
+            t.Errorf("line %d: only %d features were NaN or infinite", i+1, nulls)
+        }
+    }
+}
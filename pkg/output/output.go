@@ -5,70 +5,89 @@ import (
     "fmt"
     "os"
     "strconv"
-    "time"
 
     "github.com/Tushar98644/PacketSentry/pkg/features"
 )
 
+// FlowFeaturesWriter streams flow features to a CSV file, one row per flow,
+// so that features can be written while a live capture is still running.
+type FlowFeaturesWriter struct {
+    f *os.File
+    w *csv.Writer
+}
 
-func WriteFlowFeaturesCSV(path string, feats []features.FlowFeatures) error {
+// NewFlowFeaturesWriter creates the CSV file at path and writes its header.
+func NewFlowFeaturesWriter(path string) (*FlowFeaturesWriter, error) {
     f, err := os.Create(path)
     if err != nil {
-        return fmt.Errorf("could not create CSV file: %w", err)
+        return nil, fmt.Errorf("could not create CSV file: %w", err)
     }
-    defer f.Close()
 
-    w := csv.NewWriter(f)
-    defer w.Flush()
+    fw := &FlowFeaturesWriter{f: f, w: csv.NewWriter(f)}
+    if err := fw.w.Write(featuresHeader()); err != nil {
+        f.Close()
+        return nil, fmt.Errorf("could not write header: %w", err)
+    }
+    return fw, nil
+}
 
-    header := []string{
-        "Duration_ms",
-        "PacketCount",
-        "PktCount",
-        "PktSum",
-        "PktMean",
-        "PktMin",
-        "PktMax",
-        "PktStd",
-        "IATCount",
-        "IATSum_ms",
-        "IATMean_ms",
-        "IATMin_ms",
-        "IATMax_ms",
-        "IATStd_ms",
+// Write appends one flow's features and flushes them to disk.
+func (fw *FlowFeaturesWriter) Write(ftr features.FlowFeatures) error {
+    if err := fw.w.Write(featuresRow(ftr)); err != nil {
+        return fmt.Errorf("could not write row: %w", err)
     }
-    if err := w.Write(header); err != nil {
-        return fmt.Errorf("could not write header: %w", err)
+    fw.w.Flush()
+    return fw.w.Error()
+}
+
+// Close flushes any buffered rows and closes the file.
+func (fw *FlowFeaturesWriter) Close() error {
+    fw.w.Flush()
+    if err := fw.w.Error(); err != nil {
+        fw.f.Close()
+        return err
     }
+    return fw.f.Close()
+}
 
-    for _, ftr := range feats {
-        durMs := float64(ftr.Duration) / float64(time.Millisecond)
-        iatSumMs := float64(ftr.IATStats.Sum) / float64(time.Millisecond)
-        iatMeanMs := float64(ftr.IATStats.Mean) / float64(time.Millisecond)
-        iatMinMs := float64(ftr.IATStats.Min) / float64(time.Millisecond)
-        iatMaxMs := float64(ftr.IATStats.Max) / float64(time.Millisecond)
-        iatStdMs := float64(ftr.IATStats.Std) / float64(time.Millisecond)
+func WriteFlowFeaturesCSV(path string, feats []features.FlowFeatures) error {
+    fw, err := NewFlowFeaturesWriter(path)
+    if err != nil {
+        return err
+    }
 
-        row := []string{
-            fmt.Sprintf("%.3f", durMs),
-            strconv.Itoa(ftr.PacketCount),
-            strconv.Itoa(ftr.PacketStats.Count),
-            strconv.Itoa(ftr.PacketStats.Sum),
-            fmt.Sprintf("%.3f", ftr.PacketStats.Mean),
-            strconv.Itoa(ftr.PacketStats.Min),
-            strconv.Itoa(ftr.PacketStats.Max),
-            fmt.Sprintf("%.3f", ftr.PacketStats.Std),
-            strconv.Itoa(ftr.IATStats.Count),
-            fmt.Sprintf("%.3f", iatSumMs),
-            fmt.Sprintf("%.3f", iatMeanMs),
-            fmt.Sprintf("%.3f", iatMinMs),
-            fmt.Sprintf("%.3f", iatMaxMs),
-            fmt.Sprintf("%.3f", iatStdMs),
-        }
-        if err := w.Write(row); err != nil {
-            return fmt.Errorf("could not write row: %w", err)
+    for _, ftr := range feats {
+        if err := fw.Write(ftr); err != nil {
+            fw.Close()
+            return err
         }
     }
 
-    return nil
-}
\ No newline at end of file
+    return fw.Close()
+}
+
+func featuresHeader() []string {
+    var header []string
+    for _, c := range features.Columns() {
+        header = append(header, c.Name)
+    }
+    return header
+}
+
+func featuresRow(ftr features.FlowFeatures) []string {
+    var row []string
+    for _, c := range features.Columns() {
+        row = append(row, formatColumn(c, ftr))
+    }
+    return row
+}
+
+// formatColumn renders one feature value the way the CSV files store it:
+// integers as-is, everything else with three decimals.
+func formatColumn(c features.Column, ftr features.FlowFeatures) string {
+    v := c.Value(ftr)
+    if c.Integer {
+        return strconv.FormatFloat(v, 'f', 0, 64)
+    }
+    return fmt.Sprintf("%.3f", v)
+}
@@ -0,0 +1,201 @@
+package output
+
+import (
+    "encoding/csv"
+    "errors"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+
+    "github.com/Tushar98644/PacketSentry/internal/ml"
+    "github.com/Tushar98644/PacketSentry/pkg/features"
+    "github.com/Tushar98644/PacketSentry/pkg/tlsfp"
+    "github.com/Tushar98644/PacketSentry/pkg/verdict"
+)
+
+// Result is one scored flow as it is written to the result outputs.
+type Result struct {
+    FlowID        int
+    Features      features.FlowFeatures
+    Probability   float64
+    Label         string
+    Severity      verdict.Severity
+    Contributions []ml.Contribution
+    // Alerts are the detections besides the model that fired on the flow.
+    Alerts        []verdict.Alert
+}
+
+// Writer receives scored flows as they are produced. Close flushes
+// whatever is still buffered and finishes the output.
+type Writer interface {
+    Write(r Result) error
+    Close() error
+}
+
+// Result output formats.
+const (
+    FormatCSV    = "csv"
+    FormatNDJSON = "ndjson"
+    FormatChart  = "chart"
+)
+
+// Formats lists every result output format.
+func Formats() []string {
+    return []string{FormatCSV, FormatNDJSON, FormatChart}
+}
+
+// Path returns the file a format is written to for the results base path,
+// e.g. data/results/capture.
+func Path(format, base string) string {
+    switch format {
+    case FormatNDJSON:
+        return base + ".ndjson"
+    case FormatChart:
+        return base + "_chart.html"
+    default:
+        return base + ".csv"
+    }
+}
+
+// NewWriter creates the writer for format at Path(format, base). explainTop
+// is the number of contributions reported per flow.
+func NewWriter(format, base string, explainTop int) (Writer, error) {
+    path := Path(format, base)
+    switch format {
+    case FormatCSV:
+        return NewResultsCSVWriter(path, explainTop)
+    case FormatNDJSON:
+        return NewNDJSONWriter(path)
+    case FormatChart:
+        return NewChartWriter(path), nil
+    default:
+        return nil, fmt.Errorf("unknown output format %q", format)
+    }
+}
+
+// multiWriter writes every result to each of its writers.
+type multiWriter []Writer
+
+// MultiWriter returns a Writer that duplicates its writes to all of ws.
+func MultiWriter(ws ...Writer) Writer {
+    return multiWriter(ws)
+}
+
+func (m multiWriter) Write(r Result) error {
+    var errs []error
+    for _, w := range m {
+        errs = append(errs, w.Write(r))
+    }
+    return errors.Join(errs...)
+}
+
+func (m multiWriter) Close() error {
+    var errs []error
+    for _, w := range m {
+        errs = append(errs, w.Close())
+    }
+    return errors.Join(errs...)
+}
+
+// ResultsCSVWriter writes one row per scored flow: its ID, 5-tuple, every
+// feature column, the TLS fingerprints, DNS names, the first HTTP request,
+// the verdict with its alerts and the
+// top contributing features.
+type ResultsCSVWriter struct {
+    f          *os.File
+    w          *csv.Writer
+    explainTop int
+}
+
+// NewResultsCSVWriter creates the CSV file at path and writes its header.
+func NewResultsCSVWriter(path string, explainTop int) (*ResultsCSVWriter, error) {
+    f, err := os.Create(path)
+    if err != nil {
+        return nil, fmt.Errorf("could not create results file: %w", err)
+    }
+
+    header := []string{"FlowID", "SrcIP", "DstIP", "SrcPort", "DstPort", "Protocol"}
+    header = append(header, featuresHeader()...)
+    header = append(header, "SNI", "TLSVersion", "JA3", "JA3S", "JA4", "DNSQueries", "DNSAnswers")
+    header = append(header, "HTTPMethod", "HTTPHost", "HTTPURI", "HTTPUserAgent", "HTTPContentType")
+    header = append(header, "Probability", "Label", "Severity", "Alerts")
+    for n := 1; n <= explainTop; n++ {
+        header = append(header, fmt.Sprintf("Top%dFeature", n), fmt.Sprintf("Top%dContribution", n))
+    }
+
+    rw := &ResultsCSVWriter{f: f, w: csv.NewWriter(f), explainTop: explainTop}
+    if err := rw.w.Write(header); err != nil {
+        f.Close()
+        return nil, fmt.Errorf("could not write header: %w", err)
+    }
+    return rw, nil
+}
+
+// Write appends one result and flushes it to disk.
+func (rw *ResultsCSVWriter) Write(r Result) error {
+    ftr := r.Features
+    row := []string{
+        strconv.Itoa(r.FlowID),
+        ftr.SrcIP.String(),
+        ftr.DstIP.String(),
+        strconv.Itoa(int(ftr.SrcPort)),
+        strconv.Itoa(int(ftr.DstPort)),
+        ftr.Protocol,
+    }
+    row = append(row, featuresRow(ftr)...)
+    if fp := ftr.TLS; fp != nil {
+        row = append(row, fp.SNI, tlsfp.VersionName(fp.Version), fp.JA3, fp.JA3S, fp.JA4)
+    } else {
+        row = append(row, "", "", "", "", "")
+    }
+    if d := ftr.DNS; d != nil {
+        names := make([]string, len(d.Queries))
+        for i, q := range d.Queries {
+            names[i] = q.Name + "/" + q.Type
+        }
+        ips := make([]string, len(d.Answers))
+        for i, ip := range d.Answers {
+            ips[i] = ip.String()
+        }
+        row = append(row, strings.Join(names, " "), strings.Join(ips, " "))
+    } else {
+        row = append(row, "", "")
+    }
+    // Only the first request fits a row; NDJSON has them all.
+    if h := ftr.HTTP; h != nil && len(h.Requests) > 0 {
+        req := h.Requests[0]
+        row = append(row, req.Method, req.Host, req.URI, req.UserAgent, req.ContentType)
+    } else {
+        row = append(row, "", "", "", "", "")
+    }
+    alerts := make([]string, len(r.Alerts))
+    for i, a := range r.Alerts {
+        alerts[i] = a.String()
+    }
+    row = append(row, fmt.Sprintf("%.3f", r.Probability), r.Label, r.Severity.String(), strings.Join(alerts, "; "))
+    for n := 0; n < rw.explainTop; n++ {
+        if n < len(r.Contributions) {
+            c := r.Contributions[n]
+            row = append(row, c.Feature, fmt.Sprintf("%+.3f", c.Value))
+        } else {
+            row = append(row, "", "")
+        }
+    }
+
+    if err := rw.w.Write(row); err != nil {
+        return fmt.Errorf("could not write row for flow %d: %w", r.FlowID, err)
+    }
+    rw.w.Flush()
+    return rw.w.Error()
+}
+
+// Close flushes any buffered rows and closes the file.
+func (rw *ResultsCSVWriter) Close() error {
+    rw.w.Flush()
+    if err := rw.w.Error(); err != nil {
+        rw.f.Close()
+        return err
+    }
+    return rw.f.Close()
+}
@@ -0,0 +1,249 @@
+package pcap
+
+import (
+    "bufio"
+    "bytes"
+    "compress/gzip"
+    "container/heap"
+    "context"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "github.com/google/gopacket"
+    "github.com/google/gopacket/layers"
+    "github.com/google/gopacket/pcap"
+    "github.com/google/gopacket/pcapgo"
+    "github.com/klauspost/compress/zstd"
+)
+
+// captureExts are the file extensions picked up when a directory is given
+// as input. Compressed captures carry an extra .gz or .zst suffix.
+var captureExts = []string{".pcap", ".pcapng", ".cap"}
+
+// Magic numbers used to detect the container and compression of a file.
+var (
+    gzipMagic   = []byte{0x1f, 0x8b}
+    zstdMagic   = []byte{0x28, 0xb5, 0x2f, 0xfd}
+    pcapngMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+)
+
+// bpfSnaplen is the capture length assumed when compiling a BPF filter for
+// a capture file; it only needs to cover the headers a filter looks at.
+const bpfSnaplen = 65535
+
+// ResolvePaths expands inputs into a sorted list of capture files. Each
+// input may be a file, a glob such as /captures/2026-10-17/*.pcapng, or a
+// directory, which is walked for capture files.
+func ResolvePaths(inputs []string) ([]string, error) {
+    seen := make(map[string]bool)
+    var paths []string
+    add := func(p string) {
+        if !seen[p] {
+            seen[p] = true
+            paths = append(paths, p)
+        }
+    }
+
+    for _, in := range inputs {
+        matches := []string{in}
+        if strings.ContainsAny(in, "*?[") {
+            var err error
+            matches, err = filepath.Glob(in)
+            if err != nil {
+                return nil, fmt.Errorf("bad glob %q: %w", in, err)
+            }
+            if len(matches) == 0 {
+                return nil, fmt.Errorf("no files match %q", in)
+            }
+        }
+
+        for _, m := range matches {
+            info, err := os.Stat(m)
+            if err != nil {
+                return nil, err
+            }
+            if !info.IsDir() {
+                add(m)
+                continue
+            }
+            err = filepath.WalkDir(m, func(p string, d fs.DirEntry, err error) error {
+                if err != nil {
+                    return err
+                }
+                if !d.IsDir() && isCaptureFile(p) {
+                    add(p)
+                }
+                return nil
+            })
+            if err != nil {
+                return nil, err
+            }
+        }
+    }
+
+    if len(paths) == 0 {
+        return nil, fmt.Errorf("no capture files found in %s", strings.Join(inputs, ", "))
+    }
+    sort.Strings(paths)
+    return paths, nil
+}
+
+// isCaptureFile reports whether p looks like a (possibly compressed) capture.
+func isCaptureFile(p string) bool {
+    p = strings.TrimSuffix(strings.TrimSuffix(p, ".gz"), ".zst")
+    ext := strings.ToLower(filepath.Ext(p))
+    for _, e := range captureExts {
+        if ext == e {
+            return true
+        }
+    }
+    return false
+}
+
+// packetReader is what both pcapgo readers provide.
+type packetReader interface {
+    gopacket.PacketDataSource
+    LinkType() layers.LinkType
+}
+
+// captureFile is one open capture file, decompressed and decoded.
+type captureFile struct {
+    path    string
+    closers []io.Closer
+    reader  packetReader
+    filter  *pcap.BPF
+}
+
+// openCaptureFile opens path, unwraps gzip or zstd compression and picks a
+// pcap or pcapng reader, all based on the file's magic bytes rather than its
+// name. If bpf is set, only packets matching it are returned.
+func openCaptureFile(path, bpf string) (*captureFile, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    cf := &captureFile{path: path, closers: []io.Closer{f}}
+
+    r := bufio.NewReader(f)
+    magic, _ := r.Peek(4)
+    var data io.Reader = r
+    switch {
+    case bytes.HasPrefix(magic, gzipMagic):
+        zr, err := gzip.NewReader(r)
+        if err != nil {
+            cf.Close()
+            return nil, fmt.Errorf("%s: %w", path, err)
+        }
+        cf.closers = append(cf.closers, zr)
+        data = zr
+    case bytes.HasPrefix(magic, zstdMagic):
+        zr, err := zstd.NewReader(r)
+        if err != nil {
+            cf.Close()
+            return nil, fmt.Errorf("%s: %w", path, err)
+        }
+        cf.closers = append(cf.closers, zr.IOReadCloser())
+        data = zr
+    }
+
+    br := bufio.NewReader(data)
+    magic, _ = br.Peek(4)
+    if bytes.Equal(magic, pcapngMagic) {
+        cf.reader, err = pcapgo.NewNgReader(br, pcapgo.DefaultNgReaderOptions)
+    } else {
+        cf.reader, err = pcapgo.NewReader(br)
+    }
+    if err != nil {
+        cf.Close()
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+
+    if bpf != "" {
+        cf.filter, err = pcap.NewBPF(cf.reader.LinkType(), bpfSnaplen, bpf)
+        if err != nil {
+            cf.Close()
+            return nil, fmt.Errorf("%s: bpf filter %q: %w", path, bpf, err)
+        }
+    }
+    return cf, nil
+}
+
+// ReadPacketData returns the next packet that passes the file's filter.
+func (cf *captureFile) ReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+    for {
+        data, ci, err := cf.reader.ReadPacketData()
+        if err != nil || cf.filter == nil || cf.filter.Matches(ci, data) {
+            return data, ci, err
+        }
+    }
+}
+
+func (cf *captureFile) Close() error {
+    var first error
+    for i := len(cf.closers) - 1; i >= 0; i-- {
+        if err := cf.closers[i].Close(); err != nil && first == nil {
+            first = err
+        }
+    }
+    return first
+}
+
+// mergeFiles decodes every file and merges their packets into a single
+// channel ordered by timestamp, until the files end or ctx is canceled.
+// The files are read in the merging goroutine itself, so nothing is left
+// blocked once it returns.
+func mergeFiles(ctx context.Context, files []*captureFile) <-chan gopacket.Packet {
+    ch := make(chan gopacket.Packet)
+    go func() {
+        defer close(ch)
+
+        h := &packetHeap{}
+        for _, cf := range files {
+            src := gopacket.NewPacketSource(cf, cf.reader.LinkType())
+            if pkt, err := src.NextPacket(); err == nil {
+                heap.Push(h, heapItem{pkt: pkt, src: src})
+            }
+        }
+
+        for h.Len() > 0 {
+            item := heap.Pop(h).(heapItem)
+            select {
+            case ch <- item.pkt:
+            case <-ctx.Done():
+                return
+            }
+            // Any error ends the file, as a truncated last record would.
+            if pkt, err := item.src.NextPacket(); err == nil {
+                heap.Push(h, heapItem{pkt: pkt, src: item.src})
+            }
+        }
+    }()
+    return ch
+}
+
+// heapItem is the next unsent packet of one file.
+type heapItem struct {
+    pkt gopacket.Packet
+    src *gopacket.PacketSource
+}
+
+// packetHeap orders the head packets of all files by timestamp.
+type packetHeap []heapItem
+
+func (h packetHeap) Len() int { return len(h) }
+func (h packetHeap) Less(i, j int) bool {
+    return h[i].pkt.Metadata().Timestamp.Before(h[j].pkt.Metadata().Timestamp)
+}
+func (h packetHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *packetHeap) Push(x interface{}) { *h = append(*h, x.(heapItem)) }
+func (h *packetHeap) Pop() interface{} {
+    old := *h
+    item := old[len(old)-1]
+    *h = old[:len(old)-1]
+    return item
+}
@@ -1,7 +1,12 @@
 package pcap
 
 import (
+    "context"
+    "errors"
     "fmt"
+    "io"
+    "strings"
+    "time"
 
     "github.com/google/gopacket"
     "github.com/google/gopacket/pcap"
@@ -10,37 +15,241 @@ import (
     "github.com/Tushar98644/PacketSentry/pkg/constants"
 )
 
-// OpenHandle opens the pcap handle (live or offline),
-// using cfg for mode & filename, and constants for device/timeouts.
-func OpenHandle(cfg *config.Config) (*pcap.Handle, error) {
+// Limits bounds how much ReadPackets reads before it stops.
+// A zero value means no limit.
+type Limits struct {
+    MaxPackets int
+    MaxBytes   int64
+    Duration   time.Duration
+    // WallClock measures Duration in wall-clock time from the start of the
+    // read, as for a live capture. Otherwise it is measured in capture time
+    // from the first packet's timestamp.
+    WallClock  bool
+}
+
+// LimitsFromConfig returns the capture limits set in cfg.
+func LimitsFromConfig(cfg *config.Config) Limits {
+    return Limits{
+        MaxPackets: cfg.MaxPackets,
+        MaxBytes:   cfg.MaxBytes,
+        Duration:   cfg.Duration,
+        WallClock:  cfg.LiveCapture,
+    }
+}
+
+// Reasons ReadPackets stopped, reported in Summary.StopReason.
+const (
+    StopEOF        = "end of input"
+    StopMaxPackets = "packet limit reached"
+    StopMaxBytes   = "byte limit reached"
+    StopDuration   = "duration limit reached"
+    StopCanceled   = "interrupted"
+)
+
+// Summary describes what ReadPackets read. It is only complete, and only
+// safe to read, once the packet channel has been closed.
+type Summary struct {
+    Packets    int
+    Bytes      int64
+    StopReason string
+}
+
+func (s *Summary) String() string {
+    return fmt.Sprintf("%s after %d packets (%d bytes)", s.StopReason, s.Packets, s.Bytes)
+}
+
+// Source is an open packet input: either a live device or a set of
+// capture files read as one stream.
+type Source struct {
+    handle *pcap.Handle
+    files  []*captureFile
+}
+
+// Open opens the packet input described by cfg: the live device, the
+// files named by cfg.Inputs, or the legacy packets/<fname>.pcap file.
+func Open(cfg *config.Config) (*Source, error) {
     if cfg.LiveCapture {
-        return pcap.OpenLive(
-            cfg.Device,
-            cfg.SnapshotLen,
-            cfg.Promiscuous,
-            cfg.Timeout,
-        )
-    }
-
-    fname := fmt.Sprintf(
-        "%s/%s%s",
-        constants.PacketFolder,
-        cfg.FileName,
-        constants.PacketFileType,
-    )
-    return pcap.OpenOffline(fname)
+        handle, err := OpenHandle(cfg)
+        if err != nil {
+            return nil, err
+        }
+        return &Source{handle: handle}, nil
+    }
+
+    inputs := cfg.Inputs
+    if len(inputs) == 0 {
+        inputs = []string{fmt.Sprintf(
+            "%s/%s%s",
+            constants.PacketFolder,
+            cfg.FileName,
+            constants.PacketFileType,
+        )}
+    }
+    paths, err := ResolvePaths(inputs)
+    if err != nil {
+        return nil, err
+    }
+
+    src := &Source{}
+    for _, p := range paths {
+        cf, err := openCaptureFile(p, cfg.BPFFilter)
+        if err != nil {
+            src.Close()
+            return nil, err
+        }
+        src.files = append(src.files, cf)
+    }
+    return src, nil
 }
 
-// ReadPackets spins up a goroutine that reads from handle
-// and sends every packet into the returned channel.
-func ReadPackets(handle *pcap.Handle) <-chan gopacket.Packet {
-    src := gopacket.NewPacketSource(handle, handle.LinkType())
+// Files returns the paths of the capture files being read, if any.
+func (s *Source) Files() []string {
+    paths := make([]string, len(s.files))
+    for i, cf := range s.files {
+        paths[i] = cf.path
+    }
+    return paths
+}
+
+// Close releases the device handle or all open files.
+func (s *Source) Close() {
+    if s.handle != nil {
+        s.handle.Close()
+    }
+    for _, cf := range s.files {
+        cf.Close()
+    }
+}
+
+// packets returns the raw, unlimited packet stream of the source, which
+// ends when ctx is canceled.
+func (s *Source) packets(ctx context.Context) <-chan gopacket.Packet {
+    if s.handle != nil {
+        return livePackets(ctx, s.handle)
+    }
+    return mergeFiles(ctx, s.files)
+}
+
+// livePackets decodes the packets of a live handle into a channel until
+// the handle is closed or ctx is canceled. Like gopacket's own PacketSource
+// channel, it retries read timeouts and other passing errors after a short
+// pause.
+func livePackets(ctx context.Context, handle *pcap.Handle) <-chan gopacket.Packet {
     ch := make(chan gopacket.Packet)
+    src := gopacket.NewPacketSource(handle, handle.LinkType())
     go func() {
         defer close(ch)
-        for pkt := range src.Packets() {
-            ch <- pkt
+        for ctx.Err() == nil {
+            pkt, err := src.NextPacket()
+            switch {
+            case err == nil:
+                select {
+                case ch <- pkt:
+                case <-ctx.Done():
+                    return
+                }
+            case errors.Is(err, io.EOF) || strings.Contains(err.Error(), "closed"):
+                return
+            default:
+                time.Sleep(5 * time.Millisecond)
+            }
         }
     }()
     return ch
 }
+
+// OpenHandle opens a live capture handle on cfg.Device and applies
+// cfg.BPFFilter to it, if set.
+func OpenHandle(cfg *config.Config) (*pcap.Handle, error) {
+    handle, err := pcap.OpenLive(
+        cfg.Device,
+        cfg.SnapshotLen,
+        cfg.Promiscuous,
+        cfg.Timeout,
+    )
+    if err != nil {
+        return nil, err
+    }
+
+    if cfg.BPFFilter != "" {
+        if err := handle.SetBPFFilter(cfg.BPFFilter); err != nil {
+            handle.Close()
+            return nil, fmt.Errorf("set bpf filter %q: %w", cfg.BPFFilter, err)
+        }
+    }
+    return handle, nil
+}
+
+// ReadPackets spins up a goroutine that reads from src and sends every
+// packet into the returned channel until the input ends, one of lim is
+// reached or ctx is canceled, then closes the channel. Multiple capture
+// files are merged into a single stream ordered by timestamp.
+func ReadPackets(ctx context.Context, src *Source, lim Limits) (<-chan gopacket.Packet, *Summary) {
+    ch := make(chan gopacket.Packet)
+    sum := &Summary{StopReason: StopEOF}
+
+    // The source stops with the reader, whatever ends it.
+    srcCtx, cancel := context.WithCancel(ctx)
+    go func() {
+        defer close(ch)
+        defer cancel()
+
+        var deadline <-chan time.Time
+        if lim.Duration > 0 && lim.WallClock {
+            t := time.NewTimer(lim.Duration)
+            defer t.Stop()
+            deadline = t.C
+        }
+
+        var first time.Time
+        packets := src.packets(srcCtx)
+        for {
+            var pkt gopacket.Packet
+            select {
+            case p, ok := <-packets:
+                if !ok {
+                    // The source also ends early when ctx is canceled.
+                    if ctx.Err() != nil {
+                        sum.StopReason = StopCanceled
+                    }
+                    return
+                }
+                pkt = p
+            case <-deadline:
+                sum.StopReason = StopDuration
+                return
+            case <-ctx.Done():
+                sum.StopReason = StopCanceled
+                return
+            }
+
+            ts := pkt.Metadata().Timestamp
+            if first.IsZero() {
+                first = ts
+            }
+            if lim.Duration > 0 && !lim.WallClock && ts.Sub(first) > lim.Duration {
+                sum.StopReason = StopDuration
+                return
+            }
+
+            select {
+            case ch <- pkt:
+            case <-ctx.Done():
+                sum.StopReason = StopCanceled
+                return
+            }
+            sum.Packets++
+            sum.Bytes += int64(len(pkt.Data()))
+
+            if lim.MaxPackets > 0 && sum.Packets >= lim.MaxPackets {
+                sum.StopReason = StopMaxPackets
+                return
+            }
+            if lim.MaxBytes > 0 && sum.Bytes >= lim.MaxBytes {
+                sum.StopReason = StopMaxBytes
+                return
+            }
+        }
+    }()
+    return ch, sum
+}
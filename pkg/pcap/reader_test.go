@@ -0,0 +1,77 @@
+package pcap
+
+import (
+    "context"
+    "runtime"
+    "testing"
+    "time"
+
+    "github.com/Tushar98644/PacketSentry/pkg/config"
+)
+
+// fixtures are capture files in packets/test.
+var fixtures = []string{
+    "../../packets/test/lokibot.pcap",
+    "../../packets/test/redline.pcap",
+}
+
+func openFixtures(t *testing.T) *Source {
+    t.Helper()
+    src, err := Open(&config.Config{Inputs: fixtures})
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(src.Close)
+    return src
+}
+
+func TestReadPackets(t *testing.T) {
+    tests := []struct {
+        name   string
+        lim    Limits
+        cancel bool
+        stop   string
+    }{
+        {"to the end", Limits{}, false, StopEOF},
+        {"packet limit", Limits{MaxPackets: 10}, false, StopMaxPackets},
+        {"byte limit", Limits{MaxBytes: 2000}, false, StopMaxBytes},
+        {"capture duration", Limits{Duration: time.Second}, false, StopDuration},
+        {"canceled", Limits{}, true, StopCanceled},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            before := runtime.NumGoroutine()
+            ctx, cancel := context.WithCancel(context.Background())
+            defer cancel()
+
+            ch, sum := ReadPackets(ctx, openFixtures(t), tt.lim)
+            var last time.Time
+            n := 0
+            for pkt := range ch {
+                ts := pkt.Metadata().Timestamp
+                if ts.Before(last) {
+                    t.Fatalf("packet %d at %v is older than %v", n, ts, last)
+                }
+                last = ts
+                if n++; tt.cancel && n == 5 {
+                    cancel()
+                }
+            }
+            if sum.StopReason != tt.stop {
+                t.Errorf("stopped with %q, want %q", sum.StopReason, tt.stop)
+            }
+            if !tt.cancel && n != sum.Packets {
+                t.Errorf("received %d packets, summary says %d", n, sum.Packets)
+            }
+
+            // Stopping early must not leave the file readers behind.
+            deadline := time.Now().Add(time.Second)
+            for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+                time.Sleep(time.Millisecond)
+            }
+            if g := runtime.NumGoroutine(); g > before {
+                t.Errorf("%d goroutines left running", g-before)
+            }
+        })
+    }
+}
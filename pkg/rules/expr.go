@@ -0,0 +1,335 @@
+package rules
+
+import (
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+    "unicode"
+
+    "github.com/Tushar98644/PacketSentry/pkg/features"
+)
+
+// An expression is a boolean combination of comparisons between a field
+// and literals:
+//
+//    dst_port == 4444 && pkt_mean < 100 && iat_std_ms < 5
+//    http.user_agent contains "Charon" || !(tls.sni endswith ".example.com")
+//    dst_port in [4444, 8080] && protocol == "TCP"
+//
+// Comparisons are ==, !=, <, <=, >, >=, contains, startswith, endswith,
+// matches (a regular expression) and in (a list). A field with several
+// values, such as the names a DNS flow queried, matches if any value
+// does; a field of a protocol the flow does not carry matches nothing.
+type node interface {
+    eval(ftr features.FlowFeatures) bool
+}
+
+type andNode struct{ l, r node }
+type orNode struct{ l, r node }
+type notNode struct{ n node }
+
+func (n andNode) eval(ftr features.FlowFeatures) bool { return n.l.eval(ftr) && n.r.eval(ftr) }
+func (n orNode) eval(ftr features.FlowFeatures) bool  { return n.l.eval(ftr) || n.r.eval(ftr) }
+func (n notNode) eval(ftr features.FlowFeatures) bool { return !n.n.eval(ftr) }
+
+// cmpNode compares every value of a field with its literals.
+type cmpNode struct {
+    f    field
+    test func(v value) bool
+}
+
+func (n cmpNode) eval(ftr features.FlowFeatures) bool {
+    for _, v := range n.f.values(ftr) {
+        if n.test(v) {
+            return true
+        }
+    }
+    return false
+}
+
+// value is a number or a string, as its field's kind says.
+type value struct {
+    num float64
+    str string
+}
+
+// parse compiles an expression.
+func parse(src string) (node, error) {
+    toks, err := lex(src)
+    if err != nil {
+        return nil, err
+    }
+    p := &parser{toks: toks}
+    n, err := p.or()
+    if err != nil {
+        return nil, err
+    }
+    if t := p.peek(); t.kind != tokEOF {
+        return nil, p.errorf(t, "unexpected %s", t)
+    }
+    return n, nil
+}
+
+type tokKind int
+
+const (
+    tokEOF tokKind = iota
+    tokIdent
+    tokNumber
+    tokString
+    tokOp
+)
+
+type token struct {
+    kind tokKind
+    text string
+    pos  int
+}
+
+func (t token) String() string {
+    if t.kind == tokEOF {
+        return "end of expression"
+    }
+    return strconv.Quote(t.text)
+}
+
+// operators, longest first so that "<=" is not read as "<".
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ","}
+
+func lex(src string) ([]token, error) {
+    var toks []token
+    for i := 0; i < len(src); {
+        c := rune(src[i])
+        switch {
+        case unicode.IsSpace(c):
+            i++
+        case c == '"':
+            j := i + 1
+            for j < len(src) && src[j] != '"' {
+                if src[j] == '\\' {
+                    j++
+                }
+                j++
+            }
+            if j >= len(src) {
+                return nil, fmt.Errorf("unterminated string at %d", i+1)
+            }
+            s, err := strconv.Unquote(src[i : j+1])
+            if err != nil {
+                return nil, fmt.Errorf("bad string at %d: %w", i+1, err)
+            }
+            toks = append(toks, token{tokString, s, i})
+            i = j + 1
+        case c == '-' || c == '.' || unicode.IsDigit(c):
+            j := i + 1
+            for j < len(src) && (src[j] == '.' || unicode.IsDigit(rune(src[j])) || unicode.IsLetter(rune(src[j]))) {
+                j++
+            }
+            toks = append(toks, token{tokNumber, src[i:j], i})
+            i = j
+        case c == '_' || unicode.IsLetter(c):
+            j := i + 1
+            for j < len(src) && (src[j] == '_' || src[j] == '.' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+                j++
+            }
+            toks = append(toks, token{tokIdent, src[i:j], i})
+            i = j
+        default:
+            op := ""
+            for _, o := range operators {
+                if strings.HasPrefix(src[i:], o) {
+                    op = o
+                    break
+                }
+            }
+            if op == "" {
+                return nil, fmt.Errorf("unexpected %q at %d", c, i+1)
+            }
+            toks = append(toks, token{tokOp, op, i})
+            i += len(op)
+        }
+    }
+    return append(toks, token{tokEOF, "", len(src)}), nil
+}
+
+type parser struct {
+    toks []token
+    i    int
+}
+
+func (p *parser) peek() token {
+    return p.toks[p.i]
+}
+
+func (p *parser) next() token {
+    t := p.toks[p.i]
+    if t.kind != tokEOF {
+        p.i++
+    }
+    return t
+}
+
+func (p *parser) accept(op string) bool {
+    if t := p.peek(); t.kind == tokOp && t.text == op {
+        p.i++
+        return true
+    }
+    return false
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+    return fmt.Errorf("at %d: %s", t.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) or() (node, error) {
+    l, err := p.and()
+    for err == nil && p.accept("||") {
+        var r node
+        if r, err = p.and(); err == nil {
+            l = orNode{l, r}
+        }
+    }
+    return l, err
+}
+
+func (p *parser) and() (node, error) {
+    l, err := p.unary()
+    for err == nil && p.accept("&&") {
+        var r node
+        if r, err = p.unary(); err == nil {
+            l = andNode{l, r}
+        }
+    }
+    return l, err
+}
+
+func (p *parser) unary() (node, error) {
+    if p.accept("!") {
+        n, err := p.unary()
+        return notNode{n}, err
+    }
+    if p.accept("(") {
+        n, err := p.or()
+        if err != nil {
+            return nil, err
+        }
+        if t := p.peek(); !p.accept(")") {
+            return nil, p.errorf(t, "expected \")\", got %s", t)
+        }
+        return n, nil
+    }
+    return p.comparison()
+}
+
+func (p *parser) comparison() (node, error) {
+    t := p.next()
+    if t.kind != tokIdent {
+        return nil, p.errorf(t, "expected a field, got %s", t)
+    }
+    f, ok := lookupField(t.text)
+    if !ok {
+        return nil, p.errorf(t, "unknown field %q", t.text)
+    }
+
+    opTok := p.next()
+    op := opTok.text
+    if opTok.kind != tokOp && opTok.kind != tokIdent {
+        return nil, p.errorf(opTok, "expected an operator after %s, got %s", t.text, opTok)
+    }
+
+    if op == "in" {
+        if !p.accept("[") {
+            return nil, p.errorf(p.peek(), "expected \"[\" after in")
+        }
+        var list []value
+        for {
+            v, err := p.literal(f)
+            if err != nil {
+                return nil, err
+            }
+            list = append(list, v)
+            if p.accept("]") {
+                break
+            }
+            if !p.accept(",") {
+                return nil, p.errorf(p.peek(), "expected \",\" or \"]\", got %s", p.peek())
+            }
+        }
+        return cmpNode{f, func(v value) bool {
+            for _, l := range list {
+                if v == l {
+                    return true
+                }
+            }
+            return false
+        }}, nil
+    }
+
+    litTok := p.peek()
+    lit, err := p.literal(f)
+    if err != nil {
+        return nil, err
+    }
+    test, err := compare(f, op, lit)
+    if err != nil {
+        return nil, p.errorf(litTok, "%v", err)
+    }
+    return cmpNode{f, test}, nil
+}
+
+// literal reads a literal of the field's kind.
+func (p *parser) literal(f field) (value, error) {
+    t := p.next()
+    switch {
+    case f.str && t.kind == tokString:
+        return value{str: t.text}, nil
+    case !f.str && t.kind == tokNumber:
+        n, err := strconv.ParseFloat(t.text, 64)
+        if err != nil {
+            return value{}, p.errorf(t, "bad number %s", t)
+        }
+        return value{num: n}, nil
+    case f.str:
+        return value{}, p.errorf(t, "%s is a string field, got %s", f.name, t)
+    default:
+        return value{}, p.errorf(t, "%s is a numeric field, got %s", f.name, t)
+    }
+}
+
+func compare(f field, op string, lit value) (func(value) bool, error) {
+    switch op {
+    case "==":
+        return func(v value) bool { return v == lit }, nil
+    case "!=":
+        return func(v value) bool { return v != lit }, nil
+    }
+    if !f.str {
+        switch op {
+        case "<":
+            return func(v value) bool { return v.num < lit.num }, nil
+        case "<=":
+            return func(v value) bool { return v.num <= lit.num }, nil
+        case ">":
+            return func(v value) bool { return v.num > lit.num }, nil
+        case ">=":
+            return func(v value) bool { return v.num >= lit.num }, nil
+        }
+        return nil, fmt.Errorf("operator %q does not apply to numeric field %s", op, f.name)
+    }
+    switch op {
+    case "contains":
+        return func(v value) bool { return strings.Contains(v.str, lit.str) }, nil
+    case "startswith":
+        return func(v value) bool { return strings.HasPrefix(v.str, lit.str) }, nil
+    case "endswith":
+        return func(v value) bool { return strings.HasSuffix(v.str, lit.str) }, nil
+    case "matches":
+        re, err := regexp.Compile(lit.str)
+        if err != nil {
+            return nil, err
+        }
+        return func(v value) bool { return re.MatchString(v.str) }, nil
+    }
+    return nil, fmt.Errorf("operator %q does not apply to string field %s", op, f.name)
+}
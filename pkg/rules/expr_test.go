@@ -0,0 +1,153 @@
+package rules
+
+import (
+    "net"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/Tushar98644/PacketSentry/pkg/dns"
+    "github.com/Tushar98644/PacketSentry/pkg/features"
+    "github.com/Tushar98644/PacketSentry/pkg/httpinfo"
+    "github.com/Tushar98644/PacketSentry/pkg/stats"
+    "github.com/Tushar98644/PacketSentry/pkg/tlsfp"
+)
+
+// testFlow is an HTTP flow to port 4444 that also carries a TLS hello and
+// DNS queries, so that every kind of field has values.
+func testFlow() features.FlowFeatures {
+    return features.FlowFeatures{
+        SrcIP:       net.ParseIP("10.0.0.5"),
+        DstIP:       net.ParseIP("203.0.113.7"),
+        SrcPort:     50123,
+        DstPort:     4444,
+        Protocol:    "TCP",
+        PacketStats: stats.IntStats{Count: 12, Mean: 80},
+        IATStats:    stats.DurationStats{Std: 2 * time.Millisecond},
+        TLS:         &tlsfp.Fingerprint{SNI: "c2.example.com", ALPN: []string{"h2", "http/1.1"}},
+        DNS: &dns.Info{Queries: []dns.Query{
+            {Name: "www.example.com", Type: "A"},
+            {Name: "xjw7qkzv3pt9rmb2.com", Type: "TXT"},
+        }},
+        HTTP: &httpinfo.Info{Requests: []httpinfo.Request{
+            {Method: "GET", Host: "c2.example.com", URI: "/gate.php?id=1", UserAgent: "Charon/1.0", Status: 200},
+            {Method: "POST", URI: "/upload", Status: 404},
+        }},
+    }
+}
+
+func TestLex(t *testing.T) {
+    toks, err := lex(`dst_port>=4444&&!(http.uri matches "a\"b") || x in [1, -2.5]`)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var got []string
+    for _, tok := range toks {
+        got = append(got, tok.text)
+    }
+    want := []string{"dst_port", ">=", "4444", "&&", "!", "(", "http.uri", "matches", `a"b`, ")",
+        "||", "x", "in", "[", "1", ",", "-2.5", "]", ""}
+    if strings.Join(got, " ") != strings.Join(want, " ") {
+        t.Errorf("tokens = %q\nwant %q", got, want)
+    }
+    if toks[len(toks)-1].kind != tokEOF || toks[8].kind != tokString || toks[16].kind != tokNumber {
+        t.Errorf("token kinds = %v", toks)
+    }
+
+    for _, src := range []string{`tls.sni == "open`, `dst_port = 1`, `a $ b`} {
+        if _, err := lex(src); err == nil {
+            t.Errorf("lex(%q) succeeded", src)
+        }
+    }
+}
+
+func TestEval(t *testing.T) {
+    tests := []struct {
+        expr string
+        want bool
+    }{
+        {`dst_port == 4444`, true},
+        {`dst_port != 4444`, false},
+        {`dst_port == 4444 && pkt_mean < 100 && iat_std_ms < 5`, true},
+        {`pkt_mean <= 80 && pkt_mean >= 80`, true},
+        {`pkt_mean > 80`, false},
+        {`PktMean < 100`, true},
+        {`dst_port in [80, 4444]`, true},
+        {`dst_port in [80, 443]`, false},
+        {`protocol == "TCP" && src_ip == "10.0.0.5"`, true},
+        {`dst_ip startswith "203.0.113."`, true},
+        // && binds tighter than ||.
+        {`dst_port == 80 && protocol == "TCP" || pkt_mean < 100`, true},
+        {`dst_port == 80 && (protocol == "TCP" || pkt_mean < 100)`, false},
+        {`!(dst_port == 80)`, true},
+        {`!!(dst_port == 80)`, false},
+        {`tls.sni endswith ".example.com"`, true},
+        {`!(tls.sni endswith ".example.com")`, false},
+        {`tls.alpn == "http/1.1"`, true},
+        // A hello without JA3S has no value, not an empty one.
+        {`tls.ja3s == ""`, false},
+        // A field with several values matches if any does.
+        {`dns.query == "xjw7qkzv3pt9rmb2.com"`, true},
+        {`dns.qtype in ["TXT", "NULL"]`, true},
+        {`dns.rcode == "NXDOMAIN"`, false},
+        {`http.user_agent contains "Charon"`, true},
+        {`http.method == "POST" && http.status >= 400`, true},
+        {`http.uri matches "^/gate\\.php\\?id=[0-9]+$"`, true},
+        {`http.host == ""`, true},
+        {`http.status == 500`, false},
+    }
+    ftr := testFlow()
+    for _, tt := range tests {
+        n, err := parse(tt.expr)
+        if err != nil {
+            t.Errorf("parse(%q): %v", tt.expr, err)
+            continue
+        }
+        if got := n.eval(ftr); got != tt.want {
+            t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+        }
+    }
+}
+
+func TestEvalMissingProtocol(t *testing.T) {
+    // A field of a protocol the flow does not carry matches nothing, not
+    // even a negated comparison.
+    ftr := testFlow()
+    ftr.TLS, ftr.DNS, ftr.HTTP = nil, nil, nil
+    for _, expr := range []string{`tls.sni != "x"`, `dns.query != "x"`, `http.status != 0`} {
+        n, err := parse(expr)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if n.eval(ftr) {
+            t.Errorf("%s matched a flow without the protocol", expr)
+        }
+    }
+}
+
+func TestParseErrors(t *testing.T) {
+    tests := []struct {
+        expr string
+        err  string
+    }{
+        {`nonsense > 1`, "unknown field"},
+        {`dst_port > "a"`, "numeric field"},
+        {`tls.sni == 4`, "string field"},
+        {`dst_port contains 4`, "does not apply to numeric"},
+        {`tls.sni < "a"`, "does not apply to string"},
+        {`tls.sni matches "("`, "at 17"},
+        {`(dst_port == 1`, "expected \")\""},
+        {`dst_port == 1 dst_port`, "unexpected"},
+        {`dst_port in 1`, "expected \"[\""},
+        {`dst_port in [1 2]`, "expected \",\" or \"]\""},
+        {`== 1`, "expected a field"},
+        {`dst_port`, "expected an operator"},
+        {`dst_port == 1x`, "bad number"},
+    }
+    for _, tt := range tests {
+        _, err := parse(tt.expr)
+        if err == nil || !strings.Contains(err.Error(), tt.err) {
+            t.Errorf("parse(%q) error = %v, want %q", tt.expr, err, tt.err)
+        }
+    }
+}
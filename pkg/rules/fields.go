@@ -0,0 +1,178 @@
+package rules
+
+import (
+    "sort"
+    "strings"
+    "unicode"
+
+    "github.com/Tushar98644/PacketSentry/pkg/features"
+    "github.com/Tushar98644/PacketSentry/pkg/httpinfo"
+    "github.com/Tushar98644/PacketSentry/pkg/tlsfp"
+)
+
+// field is a name rules can test, with the values it takes for a flow.
+type field struct {
+    name   string
+    str    bool
+    values func(features.FlowFeatures) []value
+}
+
+func num(n float64) []value { return []value{{num: n}} }
+func str(s string) []value  { return []value{{str: s}} }
+
+// metaFields are the flow's addressing and the parsed protocol metadata.
+var metaFields = []field{
+    {"src_ip", true, func(f features.FlowFeatures) []value { return str(f.SrcIP.String()) }},
+    {"dst_ip", true, func(f features.FlowFeatures) []value { return str(f.DstIP.String()) }},
+    {"src_port", false, func(f features.FlowFeatures) []value { return num(float64(f.SrcPort)) }},
+    {"dst_port", false, func(f features.FlowFeatures) []value { return num(float64(f.DstPort)) }},
+    {"protocol", true, func(f features.FlowFeatures) []value { return str(f.Protocol) }},
+    {"end_reason", true, func(f features.FlowFeatures) []value { return str(f.EndReason) }},
+
+    {"tls.sni", true, tlsField(func(fp *tlsfp.Fingerprint) []string { return []string{fp.SNI} })},
+    {"tls.version", true, tlsField(func(fp *tlsfp.Fingerprint) []string { return []string{tlsfp.VersionName(fp.Version)} })},
+    {"tls.alpn", true, tlsField(func(fp *tlsfp.Fingerprint) []string { return fp.ALPN })},
+    {"tls.ja3", true, tlsField(func(fp *tlsfp.Fingerprint) []string { return []string{fp.JA3} })},
+    {"tls.ja3s", true, tlsField(func(fp *tlsfp.Fingerprint) []string { return []string{fp.JA3S} })},
+    {"tls.ja4", true, tlsField(func(fp *tlsfp.Fingerprint) []string { return []string{fp.JA4} })},
+
+    {"dns.query", true, func(f features.FlowFeatures) []value {
+        if f.DNS == nil {
+            return nil
+        }
+        var vs []value
+        for _, q := range f.DNS.Queries {
+            vs = append(vs, value{str: q.Name})
+        }
+        return vs
+    }},
+    {"dns.qtype", true, func(f features.FlowFeatures) []value {
+        if f.DNS == nil {
+            return nil
+        }
+        var vs []value
+        for _, q := range f.DNS.Queries {
+            vs = append(vs, value{str: q.Type})
+        }
+        return vs
+    }},
+    {"dns.rcode", true, func(f features.FlowFeatures) []value {
+        if f.DNS == nil {
+            return nil
+        }
+        var vs []value
+        for rc := range f.DNS.RCodes {
+            vs = append(vs, value{str: rc})
+        }
+        return vs
+    }},
+    {"dns.answer", true, func(f features.FlowFeatures) []value {
+        if f.DNS == nil {
+            return nil
+        }
+        var vs []value
+        for _, ip := range f.DNS.Answers {
+            vs = append(vs, value{str: ip.String()})
+        }
+        return vs
+    }},
+
+    {"http.method", true, httpField(func(r httpinfo.Request) value { return value{str: r.Method} })},
+    {"http.host", true, httpField(func(r httpinfo.Request) value { return value{str: r.Host} })},
+    {"http.uri", true, httpField(func(r httpinfo.Request) value { return value{str: r.URI} })},
+    {"http.user_agent", true, httpField(func(r httpinfo.Request) value { return value{str: r.UserAgent} })},
+    {"http.content_type", true, httpField(func(r httpinfo.Request) value { return value{str: r.ContentType} })},
+    {"http.status", false, httpField(func(r httpinfo.Request) value { return value{num: float64(r.Status)} })},
+}
+
+func tlsField(get func(*tlsfp.Fingerprint) []string) func(features.FlowFeatures) []value {
+    return func(f features.FlowFeatures) []value {
+        if f.TLS == nil {
+            return nil
+        }
+        var vs []value
+        for _, s := range get(f.TLS) {
+            // A side of the handshake that was not seen has no value.
+            if s != "" {
+                vs = append(vs, value{str: s})
+            }
+        }
+        return vs
+    }
+}
+
+func httpField(get func(httpinfo.Request) value) func(features.FlowFeatures) []value {
+    return func(f features.FlowFeatures) []value {
+        if f.HTTP == nil {
+            return nil
+        }
+        vs := make([]value, len(f.HTTP.Requests))
+        for i, r := range f.HTTP.Requests {
+            vs[i] = get(r)
+        }
+        return vs
+    }
+}
+
+// fields maps every field name to its field: the metadata fields and
+// each feature column, under its column name and its FieldName.
+var fields = func() map[string]field {
+    m := make(map[string]field)
+    for _, f := range metaFields {
+        m[f.name] = f
+    }
+    for _, c := range features.Columns() {
+        get := c.Value
+        f := field{name: FieldName(c.Name), values: func(ftr features.FlowFeatures) []value { return num(get(ftr)) }}
+        m[c.Name] = f
+        m[f.name] = f
+    }
+    return m
+}()
+
+func lookupField(name string) (field, bool) {
+    f, ok := fields[name]
+    return f, ok
+}
+
+// acronyms are kept whole when column names are turned into field names.
+var acronyms = func() []string {
+    a := []string{"DNS", "DGA", "NX", "HTTP", "URI", "TLS", "RTT", "IAT",
+        "SYN", "ACK", "FIN", "RST", "PSH", "URG", "ECE", "CWR", "IQR", "CV"}
+    // Longest first, so that a longer acronym wins over its prefix.
+    sort.Slice(a, func(i, j int) bool { return len(a[i]) > len(a[j]) })
+    return a
+}()
+
+// FieldName returns the snake-case field name rules use for a feature
+// column: PktMean is pkt_mean, IATStd_ms is iat_std_ms and
+// DNSNXDomainCount is dns_nx_domain_count.
+func FieldName(column string) string {
+    var words []string
+    for i := 0; i < len(column); {
+        if column[i] == '_' {
+            i++
+            continue
+        }
+        n := wordLen(column[i:])
+        words = append(words, strings.ToLower(column[i:i+n]))
+        i += n
+    }
+    return strings.Join(words, "_")
+}
+
+// wordLen returns the length of the word s starts with: a known acronym
+// not running into a lower-case letter, else a capitalized word or a run
+// of digits.
+func wordLen(s string) int {
+    for _, a := range acronyms {
+        if strings.HasPrefix(s, a) && (len(s) == len(a) || !unicode.IsLower(rune(s[len(a)]))) {
+            return len(a)
+        }
+    }
+    n := 1
+    for n < len(s) && s[n] != '_' && !unicode.IsUpper(rune(s[n])) {
+        n++
+    }
+    return n
+}
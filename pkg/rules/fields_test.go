@@ -0,0 +1,50 @@
+package rules
+
+import (
+    "testing"
+
+    "github.com/Tushar98644/PacketSentry/pkg/features"
+)
+
+func TestFieldName(t *testing.T) {
+    tests := []struct {
+        column, want string
+    }{
+        {"PktMean", "pkt_mean"},
+        {"IATStd_ms", "iat_std_ms"},
+        {"DNSNXDomainCount", "dns_nx_domain_count"},
+        {"Duration_ms", "duration_ms"},
+        {"FwdPktP95", "fwd_pkt_p95"},
+        {"HandshakeRTT_ms", "handshake_rtt_ms"},
+        {"SYNCount", "syn_count"},
+        {"PktCV", "pkt_cv"},
+        {"HTTPURILenMax", "http_uri_len_max"},
+        {"HostFanOut", "host_fan_out"},
+        {"DNSQueryCount", "dns_query_count"},
+        // An acronym running into a lower-case letter is not one.
+        {"CVector", "c_vector"},
+    }
+    for _, tt := range tests {
+        if got := FieldName(tt.column); got != tt.want {
+            t.Errorf("FieldName(%q) = %q, want %q", tt.column, got, tt.want)
+        }
+    }
+}
+
+func TestColumnFields(t *testing.T) {
+    // Every column is a field under both of its names, and no two columns
+    // share a field name.
+    seen := make(map[string]string)
+    for _, c := range features.Columns() {
+        name := FieldName(c.Name)
+        if prev, ok := seen[name]; ok {
+            t.Errorf("%s and %s are both %s", prev, c.Name, name)
+        }
+        seen[name] = c.Name
+        for _, n := range []string{c.Name, name} {
+            if _, ok := lookupField(n); !ok {
+                t.Errorf("no field %s", n)
+            }
+        }
+    }
+}
@@ -0,0 +1,148 @@
+// Package rules matches flows against hand-written signatures: boolean
+// expressions over the flow features and the parsed protocol metadata.
+package rules
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+
+    "github.com/Tushar98644/PacketSentry/pkg/features"
+    "github.com/Tushar98644/PacketSentry/pkg/verdict"
+)
+
+// Source is the alert source of rule matches.
+const Source = "rule"
+
+// Rule is one signature. Expr is compiled by Load; see node for its
+// syntax.
+type Rule struct {
+    ID          string
+    Description string
+    Severity    verdict.Severity
+    Expr        string
+
+    match node
+}
+
+// Match reports whether the flow matches the rule.
+func (r *Rule) Match(ftr features.FlowFeatures) bool {
+    return r.match.eval(ftr)
+}
+
+// Alert returns the alert raised when the rule matches.
+func (r *Rule) Alert() verdict.Alert {
+    detail := r.ID
+    if r.Description != "" {
+        detail += " " + r.Description
+    }
+    return verdict.Alert{Source: Source, Detail: detail, Severity: r.Severity}
+}
+
+// Set is a list of rules, in the order they were loaded.
+type Set []*Rule
+
+// Match returns an alert for every rule the flow matches.
+func (s Set) Match(ftr features.FlowFeatures) []verdict.Alert {
+    var alerts []verdict.Alert
+    for _, r := range s {
+        if r.Match(ftr) {
+            alerts = append(alerts, r.Alert())
+        }
+    }
+    return alerts
+}
+
+// ruleFile is the layout of a rule file:
+//
+//    rules:
+//      - id: PS-0001
+//        description: reverse shell on the Metasploit default port
+//        severity: high
+//        expr: dst_port == 4444 && pkt_mean < 100 && iat_std_ms < 5
+type ruleFile struct {
+    Rules []struct {
+        ID          string `yaml:"id"`
+        Description string `yaml:"description"`
+        Severity    string `yaml:"severity"`
+        Expr        string `yaml:"expr"`
+    } `yaml:"rules"`
+}
+
+// Load reads every .yaml and .yml file in dir, in name order, and compiles
+// their rules. Rule IDs must be unique across the directory; severity
+// defaults to medium.
+func Load(dir string) (Set, error) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return nil, fmt.Errorf("read rules: %w", err)
+    }
+    var names []string
+    for _, e := range entries {
+        if ext := filepath.Ext(e.Name()); !e.IsDir() && (ext == ".yaml" || ext == ".yml") {
+            names = append(names, e.Name())
+        }
+    }
+    sort.Strings(names)
+
+    var set Set
+    seen := make(map[string]string)
+    for _, name := range names {
+        path := filepath.Join(dir, name)
+        rules, err := loadFile(path)
+        if err != nil {
+            return nil, err
+        }
+        for _, r := range rules {
+            if prev, ok := seen[r.ID]; ok {
+                return nil, fmt.Errorf("%s: rule %s already defined in %s", path, r.ID, prev)
+            }
+            seen[r.ID] = path
+            set = append(set, r)
+        }
+    }
+    return set, nil
+}
+
+func loadFile(path string) ([]*Rule, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, fmt.Errorf("read rules: %w", err)
+    }
+    defer f.Close()
+
+    var file ruleFile
+    dec := yaml.NewDecoder(f)
+    dec.KnownFields(true)
+    if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+
+    rules := make([]*Rule, 0, len(file.Rules))
+    for i, fr := range file.Rules {
+        r := Rule{ID: fr.ID, Description: fr.Description, Expr: fr.Expr}
+        if r.ID == "" {
+            return nil, fmt.Errorf("%s: rule %d has no id", path, i+1)
+        }
+        r.Severity = verdict.Medium
+        if fr.Severity != "" {
+            if r.Severity, err = verdict.ParseSeverity(fr.Severity); err != nil {
+                return nil, fmt.Errorf("%s: rule %s: %w", path, r.ID, err)
+            }
+        }
+        if strings.TrimSpace(r.Expr) == "" {
+            return nil, fmt.Errorf("%s: rule %s has no expr", path, r.ID)
+        }
+        if r.match, err = parse(r.Expr); err != nil {
+            return nil, fmt.Errorf("%s: rule %s: expr %w", path, r.ID, err)
+        }
+        rules = append(rules, &r)
+    }
+    return rules, nil
+}
@@ -0,0 +1,84 @@
+package rules
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/Tushar98644/PacketSentry/pkg/verdict"
+)
+
+func writeRules(t *testing.T, files map[string]string) string {
+    t.Helper()
+    dir := t.TempDir()
+    for name, content := range files {
+        if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return dir
+}
+
+func TestLoad(t *testing.T) {
+    dir := writeRules(t, map[string]string{
+        "b.yml": `
+rules:
+  - id: PS-0002
+    expr: http.user_agent contains "Charon"
+`,
+        "a.yaml": `
+rules:
+  - id: PS-0001
+    description: reverse shell on the Metasploit default port
+    severity: high
+    expr: dst_port == 4444 && pkt_mean < 100
+`,
+        "notes.txt": "not a rule file",
+    })
+    set, err := Load(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(set) != 2 || set[0].ID != "PS-0001" || set[1].ID != "PS-0002" {
+        t.Fatalf("loaded %v, want PS-0001 and PS-0002 in file order", set)
+    }
+    if set[0].Severity != verdict.High || set[1].Severity != verdict.Medium {
+        t.Errorf("severities %s, %s; want high, medium", set[0].Severity, set[1].Severity)
+    }
+
+    alerts := set.Match(testFlow())
+    if len(alerts) != 2 {
+        t.Fatalf("alerts = %v, want both rules", alerts)
+    }
+    want := "PS-0001 reverse shell on the Metasploit default port"
+    if alerts[0].Source != Source || alerts[0].Detail != want {
+        t.Errorf("alert = %+v, want detail %q", alerts[0], want)
+    }
+}
+
+func TestLoadErrors(t *testing.T) {
+    tests := []struct {
+        name  string
+        files map[string]string
+        err   string
+    }{
+        {"duplicate id", map[string]string{
+            "a.yaml": "rules:\n  - id: X\n    expr: dst_port == 1\n",
+            "b.yaml": "rules:\n  - id: X\n    expr: dst_port == 2\n",
+        }, "already defined"},
+        {"no id", map[string]string{"a.yaml": "rules:\n  - expr: dst_port == 1\n"}, "has no id"},
+        {"no expr", map[string]string{"a.yaml": "rules:\n  - id: X\n"}, "has no expr"},
+        {"bad severity", map[string]string{"a.yaml": "rules:\n  - id: X\n    severity: dire\n    expr: dst_port == 1\n"}, "rule X"},
+        {"bad expr", map[string]string{"a.yaml": "rules:\n  - id: X\n    expr: dst_port ==\n"}, "rule X: expr"},
+        {"unknown key", map[string]string{"a.yaml": "rules:\n  - id: X\n    exp: dst_port == 1\n"}, "a.yaml"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := Load(writeRules(t, tt.files))
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Errorf("Load error = %v, want %q", err, tt.err)
+            }
+        })
+    }
+}
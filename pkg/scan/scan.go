@@ -0,0 +1,252 @@
+// Package scan finds reconnaissance and floods across flows: one source
+// probing many ports of a host (vertical scan) or one port on many hosts
+// (horizontal sweep), and many half-open connections to one service (SYN
+// flood). Each probe is a flow of its own, harmless alone, so they are
+// reported as host-level events rather than flow alerts.
+package scan
+
+import (
+    "fmt"
+    "net"
+    "sort"
+    "strconv"
+    "time"
+
+    "github.com/Tushar98644/PacketSentry/pkg/features"
+    "github.com/Tushar98644/PacketSentry/pkg/verdict"
+)
+
+// Kinds of event.
+const (
+    KindVertical   = "vertical-scan"
+    KindHorizontal = "horizontal-sweep"
+    KindSYNFlood   = "syn-flood"
+)
+
+const (
+    // sampleSize is how many of the targets an event lists.
+    sampleSize = 10
+    // pruneEvery is how many flows pass between sweeps of keys that fell
+    // out of the window.
+    pruneEvery = 1024
+)
+
+// Thresholds tune the Detector. A zero count disables that detection.
+type Thresholds struct {
+    Window time.Duration
+    // Ports is the number of distinct ports of one host a source may
+    // probe within Window, Hosts the number of distinct hosts it may
+    // probe on one port, and HalfOpen the number of TCP connections to
+    // one service left half-open within Window.
+    Ports    int
+    Hosts    int
+    HalfOpen int
+}
+
+// DefaultThresholds stay above what browsers and service discovery do.
+func DefaultThresholds() Thresholds {
+    return Thresholds{Window: time.Minute, Ports: 50, Hosts: 30, HalfOpen: 200}
+}
+
+// Event is a scan, sweep or flood seen within the window. Src is empty for
+// floods, which are often spoofed; Dst is empty for sweeps and Port zero
+// for vertical scans.
+type Event struct {
+    Kind     string
+    Src      string
+    Dst      string
+    Port     uint16
+    Protocol string
+    // Count is the number of distinct ports, hosts or half-open
+    // connections within the window, and Targets a sample of them.
+    Count    int
+    Targets  []string
+    First    time.Time
+    Last     time.Time
+    Severity verdict.Severity
+}
+
+func (e Event) String() string {
+    switch e.Kind {
+    case KindVertical:
+        return fmt.Sprintf("%s: %s probed %d %s ports of %s within %s", e.Kind, e.Src, e.Count, e.Protocol, e.Dst, e.Last.Sub(e.First).Round(time.Millisecond))
+    case KindHorizontal:
+        return fmt.Sprintf("%s: %s probed %s port %d on %d hosts within %s", e.Kind, e.Src, e.Protocol, e.Port, e.Count, e.Last.Sub(e.First).Round(time.Millisecond))
+    default:
+        return fmt.Sprintf("%s: %d half-open connections to %s within %s", e.Kind, e.Count, net.JoinHostPort(e.Dst, strconv.Itoa(int(e.Port))), e.Last.Sub(e.First).Round(time.Millisecond))
+    }
+}
+
+// Detector counts the distinct targets of every source, or the half-open
+// connections to every service, within a sliding window.
+type Detector struct {
+    th      Thresholds
+    tracks  map[trackKey]*track
+    flows   int
+}
+
+// trackKey identifies what is counted: the ports of Src on Dst, the hosts
+// of Src on Port, or the half-open connections to Dst on Port.
+type trackKey struct {
+    kind     string
+    src, dst string
+    port     uint16
+    protocol string
+}
+
+type track struct {
+    // seen maps each target probed within the window to when it was last
+    // probed; probes queues every probe in arrival order so that expired
+    // targets are found without a scan.
+    seen     map[string]time.Time
+    probes   []probe
+    newest   time.Time
+    // reported is when the last event was raised; one is raised per
+    // window while the scan goes on.
+    reported time.Time
+}
+
+type probe struct {
+    target string
+    at     time.Time
+}
+
+func NewDetector(th Thresholds) *Detector {
+    return &Detector{th: th, tracks: make(map[trackKey]*track)}
+}
+
+// Check counts a finished flow and returns the events it completes. Flows
+// must be checked roughly in time order.
+func (d *Detector) Check(ftr features.FlowFeatures) []Event {
+    if d.th.Window <= 0 || (d.th.Ports <= 0 && d.th.Hosts <= 0 && d.th.HalfOpen <= 0) {
+        return nil
+    }
+    at := ftr.Start
+    if d.flows++; d.flows%pruneEvery == 0 {
+        d.prune(at)
+    }
+
+    src, dst := ftr.SrcIP.String(), ftr.DstIP.String()
+    var events []Event
+    if isProbe(ftr) {
+        if e, ok := d.observe(trackKey{KindVertical, src, dst, 0, ftr.Protocol}, strconv.Itoa(int(ftr.DstPort)), at, d.th.Ports); ok {
+            events = append(events, e)
+        }
+        if e, ok := d.observe(trackKey{KindHorizontal, src, "", ftr.DstPort, ftr.Protocol}, dst, at, d.th.Hosts); ok {
+            events = append(events, e)
+        }
+    }
+    if isHalfOpen(ftr) {
+        // Every connection counts, so the target is the client's end.
+        client := net.JoinHostPort(src, strconv.Itoa(int(ftr.SrcPort)))
+        if e, ok := d.observe(trackKey{KindSYNFlood, "", dst, ftr.DstPort, ftr.Protocol}, client, at, d.th.HalfOpen); ok {
+            events = append(events, e)
+        }
+    }
+    return events
+}
+
+// isProbe reports whether a flow exchanged no data: a TCP connection that
+// was refused, went unanswered or was closed right after the handshake,
+// or a UDP datagram that got no reply.
+func isProbe(ftr features.FlowFeatures) bool {
+    switch ftr.Protocol {
+    case "TCP":
+        return ftr.Flags.SYN > 0 && (ftr.HandshakeRTT == 0 || ftr.PayloadStats.Sum == 0)
+    case "UDP":
+        return ftr.BwdPacketStats.Count == 0 && ftr.FwdPacketStats.Count <= 2
+    }
+    return false
+}
+
+// isHalfOpen reports whether a TCP connection was opened with a SYN but
+// never completed its handshake.
+func isHalfOpen(ftr features.FlowFeatures) bool {
+    return ftr.Protocol == "TCP" && ftr.Flags.SYN > 0 && ftr.HandshakeRTT == 0 && ftr.PayloadStats.Sum == 0
+}
+
+// observe records target under key at time at and returns an event if the
+// key has reached threshold distinct targets within the window and none
+// was reported for it within the window.
+func (d *Detector) observe(key trackKey, target string, at time.Time, threshold int) (Event, bool) {
+    if threshold <= 0 {
+        return Event{}, false
+    }
+    t := d.tracks[key]
+    if t == nil {
+        t = &track{seen: make(map[string]time.Time)}
+        d.tracks[key] = t
+    }
+    if at.After(t.seen[target]) {
+        t.seen[target] = at
+    }
+    if at.After(t.newest) {
+        t.newest = at
+    }
+    t.probes = append(t.probes, probe{target, at})
+    t.expire(t.newest.Add(-d.th.Window))
+    if len(t.seen) < threshold || (!t.reported.IsZero() && t.newest.Sub(t.reported) < d.th.Window) {
+        return Event{}, false
+    }
+    t.reported = t.newest
+    return t.event(key), true
+}
+
+// expire forgets the targets last probed before cutoff.
+func (t *track) expire(cutoff time.Time) {
+    for len(t.probes) > 0 && t.probes[0].at.Before(cutoff) {
+        p := t.probes[0]
+        if last, ok := t.seen[p.target]; ok && last.Before(cutoff) {
+            delete(t.seen, p.target)
+        }
+        t.probes = t.probes[1:]
+    }
+}
+
+func (t *track) event(key trackKey) Event {
+    e := Event{
+        Kind:     key.kind,
+        Src:      key.src,
+        Dst:      key.dst,
+        Port:     key.port,
+        Protocol: key.protocol,
+        Count:    len(t.seen),
+        Last:     t.newest,
+        Severity: verdict.Medium,
+    }
+    if key.kind == KindSYNFlood {
+        e.Severity = verdict.High
+    }
+    targets := make([]string, 0, len(t.seen))
+    e.First = t.newest
+    for tg, last := range t.seen {
+        targets = append(targets, tg)
+        if last.Before(e.First) {
+            e.First = last
+        }
+    }
+    sortTargets(targets)
+    e.Targets = targets[:min(len(targets), sampleSize)]
+    return e
+}
+
+// sortTargets orders ports numerically and everything else as text.
+func sortTargets(targets []string) {
+    sort.Slice(targets, func(i, j int) bool {
+        a, errA := strconv.Atoi(targets[i])
+        b, errB := strconv.Atoi(targets[j])
+        if errA == nil && errB == nil {
+            return a < b
+        }
+        return targets[i] < targets[j]
+    })
+}
+
+// prune forgets keys with nothing seen within the window before now.
+func (d *Detector) prune(now time.Time) {
+    for k, t := range d.tracks {
+        if now.Sub(t.newest) > d.th.Window {
+            delete(d.tracks, k)
+        }
+    }
+}
@@ -0,0 +1,125 @@
+package scan
+
+import (
+    "fmt"
+    "net"
+    "testing"
+    "time"
+
+    "github.com/Tushar98644/PacketSentry/pkg/features"
+    "github.com/Tushar98644/PacketSentry/pkg/flow"
+)
+
+var t0 = time.Unix(1000, 0)
+
+// syn is an unanswered TCP connection attempt.
+func syn(at time.Duration, src, dst string, srcPort, dstPort int) features.FlowFeatures {
+    return features.FlowFeatures{
+        SrcIP: net.ParseIP(src), DstIP: net.ParseIP(dst),
+        SrcPort: uint16(srcPort), DstPort: uint16(dstPort),
+        Protocol: "TCP", Start: t0.Add(at),
+        Flags: flow.TCPFlags{SYN: 1},
+    }
+}
+
+func TestDetector(t *testing.T) {
+    th := Thresholds{Window: time.Minute, Ports: 20, Hosts: 10, HalfOpen: 50}
+
+    tests := []struct {
+        name  string
+        th    Thresholds
+        flows func() []features.FlowFeatures
+        want  []string
+    }{
+        {"vertical scan", th, func() (fs []features.FlowFeatures) {
+            for p := 1; p <= 30; p++ {
+                fs = append(fs, syn(time.Duration(p)*time.Second, "10.0.0.5", "10.0.0.9", 40000, p))
+            }
+            return fs
+        }, []string{"vertical-scan 10.0.0.5 -> 10.0.0.9:0 count 20"}},
+        {"horizontal sweep", th, func() (fs []features.FlowFeatures) {
+            for h := 1; h <= 12; h++ {
+                fs = append(fs, syn(time.Duration(h)*time.Second, "10.0.0.5", fmt.Sprintf("10.1.0.%d", h), 40000, 22))
+            }
+            return fs
+        }, []string{"horizontal-sweep 10.0.0.5 -> :22 count 10"}},
+        {"syn flood", th, func() (fs []features.FlowFeatures) {
+            for c := 0; c < 60; c++ {
+                fs = append(fs, syn(time.Duration(c)*time.Millisecond, fmt.Sprintf("172.16.0.%d", c), "10.0.0.80", 1024+c, 80))
+            }
+            return fs
+        }, []string{"syn-flood  -> 10.0.0.80:80 count 50"}},
+        {"slower than the window", th, func() (fs []features.FlowFeatures) {
+            for p := 1; p <= 30; p++ {
+                fs = append(fs, syn(time.Duration(p)*5*time.Second, "10.0.0.5", "10.0.0.9", 40000, p))
+            }
+            return fs
+        }, nil},
+        {"raised once per window", th, func() (fs []features.FlowFeatures) {
+            for p := 1; p <= 100; p++ {
+                fs = append(fs, syn(time.Duration(p)*time.Second, "10.0.0.5", "10.0.0.9", 40000, p))
+            }
+            return fs
+        }, []string{
+            "vertical-scan 10.0.0.5 -> 10.0.0.9:0 count 20",
+            "vertical-scan 10.0.0.5 -> 10.0.0.9:0 count 61",
+        }},
+        {"connections with data are not probes", th, func() (fs []features.FlowFeatures) {
+            for p := 1; p <= 30; p++ {
+                f := syn(time.Duration(p)*time.Second, "10.0.0.5", "10.0.0.9", 40000, p)
+                f.HandshakeRTT = time.Millisecond
+                f.PayloadStats.Sum = 100
+                fs = append(fs, f)
+            }
+            return fs
+        }, nil},
+        {"unanswered UDP", th, func() (fs []features.FlowFeatures) {
+            for p := 1; p <= 25; p++ {
+                f := syn(time.Duration(p)*time.Second, "10.0.0.5", "10.0.0.9", 40000, p)
+                f.Protocol, f.Flags = "UDP", flow.TCPFlags{}
+                f.FwdPacketStats.Count = 1
+                fs = append(fs, f)
+            }
+            return fs
+        }, []string{"vertical-scan 10.0.0.5 -> 10.0.0.9:0 count 20"}},
+        {"disabled", Thresholds{Window: time.Minute}, func() (fs []features.FlowFeatures) {
+            for p := 1; p <= 30; p++ {
+                fs = append(fs, syn(time.Duration(p)*time.Second, "10.0.0.5", "10.0.0.9", 40000, p))
+            }
+            return fs
+        }, nil},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            d := NewDetector(tt.th)
+            var got []string
+            for _, f := range tt.flows() {
+                for _, e := range d.Check(f) {
+                    got = append(got, fmt.Sprintf("%s %s -> %s:%d count %d", e.Kind, e.Src, e.Dst, e.Port, e.Count))
+                }
+            }
+            if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+                t.Errorf("events = %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestEventTargets(t *testing.T) {
+    d := NewDetector(Thresholds{Window: time.Minute, Ports: 12})
+    var events []Event
+    for _, p := range []int{443, 22, 8080, 80, 3389, 21, 25, 110, 143, 993, 995, 53} {
+        events = append(events, d.Check(syn(time.Duration(p)*time.Millisecond, "10.0.0.5", "10.0.0.9", 40000, p))...)
+    }
+    if len(events) != 1 {
+        t.Fatalf("got %d events, want 1", len(events))
+    }
+    e := events[0]
+    want := "[21 22 25 53 80 110 143 443 993 995]"
+    if got := fmt.Sprint(e.Targets); got != want {
+        t.Errorf("targets = %s, want %s", got, want)
+    }
+    if got, want := e.Last.Sub(e.First), 8080*time.Millisecond-21*time.Millisecond; got != want {
+        t.Errorf("span = %s, want %s", got, want)
+    }
+}
@@ -0,0 +1,61 @@
+package stats
+
+import "math"
+
+// ByteHistogram counts how often each byte value occurs.
+type ByteHistogram [256]int
+
+// Add counts every byte of b.
+func (h *ByteHistogram) Add(b []byte) {
+    for _, c := range b {
+        h[c]++
+    }
+}
+
+// Total returns the number of bytes counted.
+func (h *ByteHistogram) Total() int {
+    n := 0
+    for _, c := range h {
+        n += c
+    }
+    return n
+}
+
+// Entropy returns the Shannon entropy of the counted bytes in bits per
+// byte, from 0 for a single repeated value to 8 for uniformly random data.
+func (h *ByteHistogram) Entropy() float64 {
+    total := float64(h.Total())
+    if total == 0 {
+        return 0
+    }
+    var e float64
+    for _, c := range h {
+        if c == 0 {
+            continue
+        }
+        p := float64(c) / total
+        e -= p * math.Log2(p)
+    }
+    return e
+}
+
+// Printable returns the share of counted bytes that are printable ASCII or
+// whitespace, close to 1 for plaintext protocols.
+func (h *ByteHistogram) Printable() float64 {
+    total := h.Total()
+    if total == 0 {
+        return 0
+    }
+    n := h['\t'] + h['\n'] + h['\r']
+    for c := ' '; c <= '~'; c++ {
+        n += h[c]
+    }
+    return float64(n) / float64(total)
+}
+
+// Entropy returns the Shannon entropy of b in bits per byte.
+func Entropy(b []byte) float64 {
+    var h ByteHistogram
+    h.Add(b)
+    return h.Entropy()
+}
@@ -0,0 +1,131 @@
+package stats
+
+import (
+    "math"
+    "sort"
+)
+
+// DefaultSketchK is a sketch size that keeps quantiles exact up to that
+// many values and within about 2% of rank after.
+const DefaultSketchK = 128
+
+// Sketch is a KLL quantile sketch (Karnin, Lang and Liberty, 2016). It
+// keeps a bounded sample of a series in levels of compactors, where a value
+// at level h stands for 2^h values, so its memory stays at about 3k values
+// however long the series runs.
+//
+// Compactions keep alternately the odd and even values rather than a
+// random half, so the same series always gives the same quantiles.
+type Sketch struct {
+    k      int
+    n      int
+    levels [][]float64
+    size   int
+    max    int
+    odd    bool
+}
+
+// NewSketch returns a sketch whose top level holds k values; it is exact
+// up to k values.
+func NewSketch(k int) *Sketch {
+    s := &Sketch{k: max(k, 2)}
+    s.grow()
+    return s
+}
+
+// capacity is how many values level h holds before it is compacted;
+// levels further down get smaller by a factor of 2/3.
+func (s *Sketch) capacity(h int) int {
+    depth := len(s.levels) - h - 1
+    return int(math.Ceil(math.Pow(2.0/3, float64(depth))*float64(s.k))) + 1
+}
+
+func (s *Sketch) grow() {
+    s.levels = append(s.levels, nil)
+    s.max = 0
+    for h := range s.levels {
+        s.max += s.capacity(h)
+    }
+}
+
+// Add records the next value.
+func (s *Sketch) Add(x float64) {
+    s.n++
+    s.levels[0] = append(s.levels[0], x)
+    s.size++
+    if s.size >= s.max {
+        s.compress()
+    }
+}
+
+// Count returns the number of values added.
+func (s *Sketch) Count() int {
+    return s.n
+}
+
+func (s *Sketch) compress() {
+    for h := 0; h < len(s.levels); h++ {
+        if len(s.levels[h]) < s.capacity(h) {
+            continue
+        }
+        if h+1 == len(s.levels) {
+            s.grow()
+        }
+        // Promote every other value; an odd one out stays behind.
+        level := s.levels[h]
+        sort.Float64s(level)
+        keep := 0
+        if s.odd {
+            keep = 1
+        }
+        s.odd = !s.odd
+        var rest []float64
+        if len(level)%2 == 1 {
+            rest, level = level[len(level)-1:], level[:len(level)-1]
+        }
+        for i := keep; i < len(level); i += 2 {
+            s.levels[h+1] = append(s.levels[h+1], level[i])
+        }
+        s.levels[h] = append(s.levels[h][:0], rest...)
+
+        s.size = 0
+        for _, l := range s.levels {
+            s.size += len(l)
+        }
+        if s.size < s.max {
+            return
+        }
+    }
+}
+
+// Quantile returns the value of rank q (0 to 1) in the series: the
+// smallest value with at least q of the series at or below it. It is zero
+// for an empty sketch.
+func (s *Sketch) Quantile(q float64) float64 {
+    if s.n == 0 {
+        return 0
+    }
+    type item struct {
+        v float64
+        w int
+    }
+    var items []item
+    total := 0
+    for h, l := range s.levels {
+        for _, v := range l {
+            items = append(items, item{v, 1 << h})
+            total += 1 << h
+        }
+    }
+    sort.Slice(items, func(i, j int) bool { return items[i].v < items[j].v })
+
+    rank := q * float64(total)
+    cum := 0
+    for _, it := range items {
+        cum += it.w
+        if float64(cum) >= rank {
+            return it.v
+        }
+    }
+    return items[len(items)-1].v
+}
@@ -0,0 +1,96 @@
+package stats
+
+import (
+    "math/rand"
+    "sort"
+    "testing"
+)
+
+// rankError returns how far, as a share of the series, the rank of the
+// value the sketch returns for q is from q.
+func rankError(sorted []float64, v, q float64) float64 {
+    lo := sort.SearchFloat64s(sorted, v)
+    hi := sort.Search(len(sorted), func(i int) bool { return sorted[i] > v })
+    // Any rank among equal values will do.
+    want := q * float64(len(sorted))
+    n := float64(len(sorted))
+    switch {
+    case want < float64(lo):
+        return (float64(lo) - want) / n
+    case want > float64(hi):
+        return (want - float64(hi)) / n
+    }
+    return 0
+}
+
+func TestSketchExact(t *testing.T) {
+    rng := rand.New(rand.NewSource(3))
+    s := NewSketch(DefaultSketchK)
+    xs := make([]float64, DefaultSketchK)
+    for i := range xs {
+        xs[i] = float64(rng.Intn(1000))
+        s.Add(xs[i])
+    }
+    sort.Float64s(xs)
+    for _, q := range []float64{0, 0.1, 0.25, 0.5, 0.75, 0.95, 1} {
+        // The smallest value with at least q of the series at or below it.
+        i := max(0, int(float64(len(xs))*q+0.999999)-1)
+        if got := s.Quantile(q); got != xs[i] {
+            t.Errorf("Quantile(%g) = %g, want %g", q, got, xs[i])
+        }
+    }
+}
+
+func TestSketchAccuracy(t *testing.T) {
+    rng := rand.New(rand.NewSource(4))
+    const n = 100000
+    orders := []struct {
+        name string
+        gen  func(i int) float64
+    }{
+        {"ascending", func(i int) float64 { return float64(i) }},
+        {"descending", func(i int) float64 { return float64(n - i) }},
+        {"random", func(int) float64 { return rng.Float64() }},
+        {"heavy tail", func(int) float64 { return rng.ExpFloat64() * rng.ExpFloat64() }},
+        {"few values", func(int) float64 { return float64(rng.Intn(5)) }},
+    }
+    for _, o := range orders {
+        t.Run(o.name, func(t *testing.T) {
+            s := NewSketch(DefaultSketchK)
+            xs := make([]float64, n)
+            for i := range xs {
+                xs[i] = o.gen(i)
+                s.Add(xs[i])
+            }
+            sort.Float64s(xs)
+            if s.Count() != n {
+                t.Errorf("Count() = %d", s.Count())
+            }
+            if s.size > 3*DefaultSketchK+len(s.levels) {
+                t.Errorf("sketch keeps %d values", s.size)
+            }
+            for _, q := range []float64{0.01, 0.05, 0.25, 0.5, 0.75, 0.95, 0.99} {
+                if e := rankError(xs, s.Quantile(q), q); e > 0.01 {
+                    t.Errorf("Quantile(%g) is %.2f%% of rank off", q, 100*e)
+                }
+            }
+        })
+    }
+}
+
+func TestSketchDeterministic(t *testing.T) {
+    a, b := NewSketch(16), NewSketch(16)
+    for i := 0; i < 5000; i++ {
+        x := float64(i * 7919 % 5003)
+        a.Add(x)
+        b.Add(x)
+    }
+    for _, q := range []float64{0.1, 0.5, 0.9} {
+        if a.Quantile(q) != b.Quantile(q) {
+            t.Errorf("Quantile(%g) differs between runs", q)
+        }
+    }
+    if NewSketch(16).Quantile(0.5) != 0 {
+        t.Error("an empty sketch has a median")
+    }
+}
@@ -0,0 +1,143 @@
+package stats
+
+import (
+    "math"
+    "time"
+)
+
+// Accumulator keeps the count, sum, mean, minimum, maximum and central
+// moments of a series as values arrive, using Welford's algorithm extended
+// to the third and fourth moment, so that the values themselves need not
+// be kept. The zero value is an empty series.
+type Accumulator struct {
+    n          int
+    sum        float64
+    mean       float64
+    m2, m3, m4 float64
+    min, max   float64
+
+    // Sketch, if set, is fed every value too, for quantiles.
+    Sketch *Sketch
+}
+
+// Add records the next value.
+func (a *Accumulator) Add(x float64) {
+    a.n++
+    a.sum += x
+    if a.n == 1 {
+        a.min, a.max = x, x
+    } else {
+        a.min = math.Min(a.min, x)
+        a.max = math.Max(a.max, x)
+    }
+    n := float64(a.n)
+    delta := x - a.mean
+    deltaN := delta / n
+    deltaN2 := deltaN * deltaN
+    term := delta * deltaN * (n - 1)
+    a.mean += deltaN
+    a.m4 += term*deltaN2*(n*n-3*n+3) + 6*deltaN2*a.m2 - 4*deltaN*a.m3
+    a.m3 += term*deltaN*(n-2) - 3*deltaN*a.m2
+    a.m2 += term
+
+    if a.Sketch != nil {
+        a.Sketch.Add(x)
+    }
+}
+
+func (a *Accumulator) Count() int    { return a.n }
+func (a *Accumulator) Sum() float64  { return a.sum }
+func (a *Accumulator) Mean() float64 { return a.mean }
+func (a *Accumulator) Min() float64  { return a.min }
+func (a *Accumulator) Max() float64  { return a.max }
+
+// Variance is the population variance, zero for fewer than two values.
+func (a *Accumulator) Variance() float64 {
+    if a.n < 2 {
+        return 0
+    }
+    return a.m2 / float64(a.n)
+}
+
+func (a *Accumulator) Std() float64 {
+    return math.Sqrt(a.Variance())
+}
+
+// Skewness is the population skewness: positive when the values trail off
+// above the mean. It is zero when all values are equal.
+func (a *Accumulator) Skewness() float64 {
+    if a.m2 == 0 {
+        return 0
+    }
+    return math.Sqrt(float64(a.n)) * a.m3 / math.Pow(a.m2, 1.5)
+}
+
+// Kurtosis is the population excess kurtosis: zero for a normal
+// distribution and higher for series with rare large outliers. It is zero
+// when all values are equal.
+func (a *Accumulator) Kurtosis() float64 {
+    if a.m2 == 0 {
+        return 0
+    }
+    return float64(a.n)*a.m4/(a.m2*a.m2) - 3
+}
+
+// CV is the coefficient of variation, the standard deviation over the
+// mean; it is zero when the mean is.
+func (a *Accumulator) CV() float64 {
+    if a.mean == 0 {
+        return 0
+    }
+    return a.Std() / math.Abs(a.mean)
+}
+
+// Quantile returns the value of rank q (0 to 1) from the Sketch, or zero
+// without one.
+func (a *Accumulator) Quantile(q float64) float64 {
+    if a.Sketch == nil {
+        return 0
+    }
+    return a.Sketch.Quantile(q)
+}
+
+// IntStats summarizes a series of integers, such as packet sizes.
+func (a *Accumulator) IntStats() IntStats {
+    return IntStats{
+        Count: a.n,
+        Sum:   int(math.Round(a.sum)),
+        Mean:  a.mean,
+        Min:   int(a.min),
+        Max:   int(a.max),
+        Std:   a.Std(),
+
+        Median:   a.Quantile(0.5),
+        P25:      a.Quantile(0.25),
+        P75:      a.Quantile(0.75),
+        P95:      a.Quantile(0.95),
+        IQR:      a.Quantile(0.75) - a.Quantile(0.25),
+        Skewness: a.Skewness(),
+        Kurtosis: a.Kurtosis(),
+        CV:       a.CV(),
+    }
+}
+
+// DurationStats summarizes a series of durations added in nanoseconds.
+func (a *Accumulator) DurationStats() DurationStats {
+    return DurationStats{
+        Count: a.n,
+        Sum:   time.Duration(a.sum),
+        Mean:  time.Duration(a.mean),
+        Min:   time.Duration(a.min),
+        Max:   time.Duration(a.max),
+        Std:   time.Duration(a.Std()),
+
+        Median:   time.Duration(a.Quantile(0.5)),
+        P25:      time.Duration(a.Quantile(0.25)),
+        P75:      time.Duration(a.Quantile(0.75)),
+        P95:      time.Duration(a.Quantile(0.95)),
+        IQR:      time.Duration(a.Quantile(0.75) - a.Quantile(0.25)),
+        Skewness: a.Skewness(),
+        Kurtosis: a.Kurtosis(),
+        CV:       a.CV(),
+    }
+}
@@ -0,0 +1,144 @@
+package stats
+
+import (
+    "math"
+    "math/rand"
+    "testing"
+)
+
+// moments computes the mean and population variance, skewness and excess
+// kurtosis of xs the textbook way, in two passes.
+func moments(xs []float64) (mean, variance, skew, kurt float64) {
+    n := float64(len(xs))
+    for _, x := range xs {
+        mean += x
+    }
+    mean /= n
+    var m2, m3, m4 float64
+    for _, x := range xs {
+        d := x - mean
+        m2 += d * d
+        m3 += d * d * d
+        m4 += d * d * d * d
+    }
+    m2, m3, m4 = m2/n, m3/n, m4/n
+    if m2 == 0 {
+        return mean, 0, 0, 0
+    }
+    return mean, m2, m3 / math.Pow(m2, 1.5), m4/(m2*m2) - 3
+}
+
+func near(a, b, tol float64) bool {
+    return math.Abs(a-b) <= tol*math.Max(1, math.Abs(b))
+}
+
+func TestAccumulator(t *testing.T) {
+    rng := rand.New(rand.NewSource(1))
+    series := func(n int, f func(i int) float64) []float64 {
+        xs := make([]float64, n)
+        for i := range xs {
+            xs[i] = f(i)
+        }
+        return xs
+    }
+
+    tests := []struct {
+        name string
+        xs   []float64
+    }{
+        {"two values", []float64{3, 7}},
+        {"one to five", []float64{1, 2, 3, 4, 5}},
+        {"packet sizes", []float64{60, 1514, 60, 60, 590, 1514, 60, 54, 1514, 66}},
+        {"normal", series(10000, func(int) float64 { return rng.NormFloat64()*50 + 500 })},
+        {"exponential", series(10000, func(int) float64 { return rng.ExpFloat64() * 1e6 })},
+        // Large values around a small spread lose everything to
+        // cancellation in the naive sum-of-powers formulas.
+        {"large offset", series(1000, func(i int) float64 { return 1e9 + float64(i%7) })},
+        {"outlier", append(series(999, func(int) float64 { return 100 }), 10000)},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var a Accumulator
+            for _, x := range tt.xs {
+                a.Add(x)
+            }
+            mean, variance, skew, kurt := moments(tt.xs)
+            if a.Count() != len(tt.xs) || !near(a.Mean(), mean, 1e-12) || !near(a.Variance(), variance, 1e-8) {
+                t.Errorf("count %d, mean %g, variance %g; want %d, %g, %g", a.Count(), a.Mean(), a.Variance(), len(tt.xs), mean, variance)
+            }
+            if !near(a.Skewness(), skew, 1e-6) || !near(a.Kurtosis(), kurt, 1e-6) {
+                t.Errorf("skewness %g, kurtosis %g; want %g, %g", a.Skewness(), a.Kurtosis(), skew, kurt)
+            }
+        })
+    }
+}
+
+func TestAccumulatorShapes(t *testing.T) {
+    rng := rand.New(rand.NewSource(2))
+    tests := []struct {
+        name       string
+        draw       func() float64
+        skew, kurt float64
+    }{
+        {"normal", rng.NormFloat64, 0, 0},
+        {"exponential", rng.ExpFloat64, 2, 6},
+        {"uniform", rng.Float64, 0, -1.2},
+    }
+    for _, tt := range tests {
+        var a Accumulator
+        for i := 0; i < 200000; i++ {
+            a.Add(tt.draw())
+        }
+        if math.Abs(a.Skewness()-tt.skew) > 0.1 || math.Abs(a.Kurtosis()-tt.kurt) > 0.1*math.Max(1, tt.kurt)+0.1 {
+            t.Errorf("%s: skewness %.3f, kurtosis %.3f; want about %g, %g", tt.name, a.Skewness(), a.Kurtosis(), tt.skew, tt.kurt)
+        }
+    }
+}
+
+func TestAccumulatorEdges(t *testing.T) {
+    var a Accumulator
+    if a.Variance() != 0 || a.Skewness() != 0 || a.Kurtosis() != 0 || a.CV() != 0 || a.Quantile(0.5) != 0 {
+        t.Error("an empty series has nonzero statistics")
+    }
+    for i := 0; i < 10; i++ {
+        a.Add(42)
+    }
+    if a.Variance() != 0 || a.Skewness() != 0 || a.Kurtosis() != 0 || a.Min() != 42 || a.Max() != 42 {
+        t.Errorf("constant series: variance %g, skewness %g, kurtosis %g", a.Variance(), a.Skewness(), a.Kurtosis())
+    }
+
+    s := ComputeIntStats([]int{4, 1, 3, 2, 10})
+    if s.Count != 5 || s.Sum != 20 || s.Mean != 4 || s.Min != 1 || s.Max != 10 || s.Median != 3 || s.P25 != 2 || s.P75 != 4 || s.IQR != 2 {
+        t.Errorf("ComputeIntStats = %+v", s)
+    }
+    if !near(s.CV, s.Std/4, 1e-12) || s.Skewness <= 0 {
+        t.Errorf("CV %g, skewness %g", s.CV, s.Skewness)
+    }
+}
+
+func TestEntropy(t *testing.T) {
+    all := make([]byte, 256*4)
+    for i := range all {
+        all[i] = byte(i)
+    }
+    tests := []struct {
+        name      string
+        data      []byte
+        entropy   float64
+        printable float64
+    }{
+        {"empty", nil, 0, 0},
+        {"one value", []byte("aaaaaaaa"), 0, 1},
+        {"two values", []byte("abababab"), 1, 1},
+        {"every value", all, 8, 98.0 / 256},
+        {"binary", []byte{0, 1, 2, 3}, 2, 0},
+    }
+    for _, tt := range tests {
+        var h ByteHistogram
+        h.Add(tt.data)
+        if h.Total() != len(tt.data) || !near(h.Entropy(), tt.entropy, 1e-12) || !near(h.Printable(), tt.printable, 1e-12) {
+            t.Errorf("%s: total %d, entropy %g, printable %g; want %d, %g, %g", tt.name,
+                h.Total(), h.Entropy(), h.Printable(), len(tt.data), tt.entropy, tt.printable)
+        }
+    }
+}
@@ -1,9 +1,6 @@
 package stats
 
-import (
-    "math"
-    "time"
-)
+import "time"
 
 type IntStats struct {
     Count int   
@@ -12,6 +9,18 @@ type IntStats struct {
     Min   int
     Max   int 
     Std   float64
+
+    // Quantiles are zero when the series was summarized without a Sketch.
+    Median   float64
+    P25      float64
+    P75      float64
+    P95      float64
+    IQR      float64
+    // Skewness and Kurtosis (excess) describe the shape of the
+    // distribution; CV is Std over Mean.
+    Skewness float64
+    Kurtosis float64
+    CV       float64
 }
 
 type DurationStats struct {
@@ -21,65 +30,32 @@ type DurationStats struct {
     Min   time.Duration 
     Max   time.Duration
     Std   time.Duration
+
+    Median   time.Duration
+    P25      time.Duration
+    P75      time.Duration
+    P95      time.Duration
+    IQR      time.Duration
+    Skewness float64
+    Kurtosis float64
+    CV       float64
 }
 
+// ComputeIntStats summarizes xs, quantiles exactly; see Accumulator for
+// series that are not kept.
 func ComputeIntStats(xs []int) IntStats {
-    s := IntStats{Count: len(xs)}
-    if s.Count == 0 {
-        return s
-    }
-
-    s.Min, s.Max = xs[0], xs[0]
-
-    var sumSquares float64
+    a := Accumulator{Sketch: NewSketch(len(xs))}
     for _, x := range xs {
-        s.Sum += x
-        if x < s.Min {
-            s.Min = x
-        }
-        if x > s.Max {
-            s.Max = x
-        }
+        a.Add(float64(x))
     }
-    s.Mean = float64(s.Sum) / float64(s.Count)
-
-    for _, x := range xs {
-        diff := float64(x) - s.Mean
-        sumSquares += diff * diff
-    }
-    variance := sumSquares / float64(s.Count)
-    s.Std = math.Sqrt(variance)
-
-    return s
+    return a.IntStats()
 }
 
+// ComputeDurationStats summarizes ds, quantiles exactly.
 func ComputeDurationStats(ds []time.Duration) DurationStats {
-    s := DurationStats{Count: len(ds)}
-    if s.Count == 0 {
-        return s
-    }
-
-    s.Min, s.Max = ds[0], ds[0]
-    var sumSquares float64
-    for _, d := range ds {
-        s.Sum += d
-        if d < s.Min {
-            s.Min = d
-        }
-        if d > s.Max {
-            s.Max = d
-        }
-    }
-
-    meanNano := int64(s.Sum) / int64(s.Count)
-    s.Mean = time.Duration(meanNano)
-
+    a := Accumulator{Sketch: NewSketch(len(ds))}
     for _, d := range ds {
-        diff := float64(d - s.Mean)
-        sumSquares += diff * diff
+        a.Add(float64(d))
     }
-    variance := sumSquares / float64(s.Count)
-    s.Std = time.Duration(math.Sqrt(variance))
-
-    return s
-}
\ No newline at end of file
+    return a.DurationStats()
+}
@@ -0,0 +1,81 @@
+package tlsfp
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "regexp"
+    "strings"
+)
+
+var (
+    md5Pattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+    ja4Pattern = regexp.MustCompile(`^[tqd][0-9a-z]{2}[di][0-9]{4}[0-9a-z]{2}_[0-9a-f]{12}_[0-9a-f]{12}$`)
+)
+
+// Blocklist maps known-bad fingerprints to a description, such as the
+// malware family they belong to.
+type Blocklist map[string]string
+
+// Match is a blocklisted fingerprint found in a handshake.
+type Match struct {
+    // Kind is "ja3", "ja3s" or "ja4".
+    Kind        string
+    Fingerprint string
+    Description string
+}
+
+func (m Match) String() string {
+    s := m.Kind + " " + m.Fingerprint
+    if m.Description != "" {
+        s += " (" + m.Description + ")"
+    }
+    return s
+}
+
+// LoadBlocklist reads a blocklist file. Each line holds a JA3 or JA3S MD5
+// hash or a JA4 fingerprint, optionally followed by a description; blank
+// lines and lines starting with # are skipped.
+func LoadBlocklist(path string) (Blocklist, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, fmt.Errorf("open blocklist: %w", err)
+    }
+    defer f.Close()
+
+    bl := make(Blocklist)
+    sc := bufio.NewScanner(f)
+    for n := 1; sc.Scan(); n++ {
+        line := strings.TrimSpace(sc.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        fp, desc, _ := strings.Cut(line, " ")
+        fp = strings.ToLower(fp)
+        if !md5Pattern.MatchString(fp) && !ja4Pattern.MatchString(fp) {
+            return nil, fmt.Errorf("%s:%d: %q is not a JA3, JA3S or JA4 fingerprint", path, n, fp)
+        }
+        bl[fp] = strings.TrimSpace(desc)
+    }
+    if err := sc.Err(); err != nil {
+        return nil, fmt.Errorf("read blocklist: %w", err)
+    }
+    return bl, nil
+}
+
+// Match returns every fingerprint of fp that is on the blocklist.
+func (bl Blocklist) Match(fp *Fingerprint) []Match {
+    if fp == nil {
+        return nil
+    }
+    var matches []Match
+    for _, c := range []struct{ kind, fp string }{{"ja3", fp.JA3}, {"ja3s", fp.JA3S}, {"ja4", fp.JA4}} {
+        if c.fp == "" {
+            continue
+        }
+        if desc, ok := bl[c.fp]; ok {
+            matches = append(matches, Match{Kind: c.kind, Fingerprint: c.fp, Description: desc})
+        }
+    }
+    return matches
+}
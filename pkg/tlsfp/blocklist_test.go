@@ -0,0 +1,43 @@
+package tlsfp
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestBlocklist(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "blocklist.txt")
+    list := "# known bad\n\nADA70206E40642A3E4461F35503241D5 old client\n" +
+        "t13d1516h2_8daaf6152771_e5627efa2ab1\n"
+    if err := os.WriteFile(path, []byte(list), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    bl, err := LoadBlocklist(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    fp := &Fingerprint{JA3: "ada70206e40642a3e4461f35503241d5", JA3S: "ada70206e40642a3e4461f35503241d5",
+        JA4: "t13d1516h2_8daaf6152771_e5627efa2ab1"}
+    var got []string
+    for _, m := range bl.Match(fp) {
+        got = append(got, m.String())
+    }
+    want := "ja3 ada70206e40642a3e4461f35503241d5 (old client)|ja3s ada70206e40642a3e4461f35503241d5 (old client)|" +
+        "ja4 t13d1516h2_8daaf6152771_e5627efa2ab1"
+    if strings.Join(got, "|") != want {
+        t.Errorf("matches = %q\nwant %q", got, want)
+    }
+    if bl.Match(&Fingerprint{}) != nil || bl.Match(nil) != nil {
+        t.Error("an empty fingerprint matched")
+    }
+
+    if err := os.WriteFile(path, []byte("ok\n"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := LoadBlocklist(path); err == nil || !strings.Contains(err.Error(), "blocklist.txt:1") {
+        t.Errorf("LoadBlocklist error = %v", err)
+    }
+}
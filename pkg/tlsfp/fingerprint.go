@@ -0,0 +1,229 @@
+package tlsfp
+
+import (
+    "crypto/md5"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "hash"
+    "slices"
+    "strconv"
+    "strings"
+)
+
+// Fingerprint summarizes the TLS handshake of a flow. Either side of the
+// handshake may be missing, leaving its fields empty.
+type Fingerprint struct {
+    SNI          string
+    ALPN         []string
+    // Version is the negotiated version, or the client's highest offer
+    // when no ServerHello was seen.
+    Version      uint16
+    // CipherSuites are offered by the client; CipherSuite is the one the
+    // server chose.
+    CipherSuites []uint16
+    CipherSuite  uint16
+    // JA3 and JA3S are MD5 hashes, JA4 is in its readable a_b_c form.
+    JA3          string
+    JA3S         string
+    JA4          string
+}
+
+// NewFingerprint fingerprints a handshake from whichever hellos were seen.
+// It returns nil if neither was.
+func NewFingerprint(ch *ClientHello, sh *ServerHello) *Fingerprint {
+    if ch == nil && sh == nil {
+        return nil
+    }
+    fp := &Fingerprint{}
+    if ch != nil {
+        fp.SNI = ch.SNI
+        fp.ALPN = ch.ALPN
+        fp.Version = ch.MaxVersion()
+        fp.CipherSuites = ch.CipherSuites
+        fp.JA3 = digest(md5.New, ch.JA3String())
+        fp.JA4 = ch.JA4()
+    }
+    if sh != nil {
+        fp.Version = sh.SelectedVersion()
+        fp.CipherSuite = sh.CipherSuite
+        fp.JA3S = digest(md5.New, sh.JA3SString())
+    }
+    return fp
+}
+
+// VersionName returns the usual name of a TLS protocol version.
+func VersionName(v uint16) string {
+    switch v {
+    case 0:
+        return ""
+    case 0x0300:
+        return "SSL 3.0"
+    case 0x0301:
+        return "TLS 1.0"
+    case 0x0302:
+        return "TLS 1.1"
+    case 0x0303:
+        return "TLS 1.2"
+    case 0x0304:
+        return "TLS 1.3"
+    }
+    return fmt.Sprintf("0x%04x", v)
+}
+
+// isGREASE reports whether v is one of the reserved values clients add to
+// keep servers tolerant of unknown ones (RFC 8701); fingerprints skip them.
+func isGREASE(v uint16) bool {
+    return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+func withoutGREASE(vs []uint16) []uint16 {
+    var out []uint16
+    for _, v := range vs {
+        if !isGREASE(v) {
+            out = append(out, v)
+        }
+    }
+    return out
+}
+
+// MaxVersion returns the highest version the client offers.
+func (ch *ClientHello) MaxVersion() uint16 {
+    v := ch.Version
+    for _, sv := range withoutGREASE(ch.SupportedVersions) {
+        v = max(v, sv)
+    }
+    return v
+}
+
+// SelectedVersion returns the version the server chose.
+func (sh *ServerHello) SelectedVersion() uint16 {
+    if sh.SupportedVersion != 0 {
+        return sh.SupportedVersion
+    }
+    return sh.Version
+}
+
+// JA3String returns the JA3 fingerprint before hashing:
+// version,ciphers,extensions,curves,point formats.
+func (ch *ClientHello) JA3String() string {
+    formats := make([]uint16, len(ch.PointFormats))
+    for i, f := range ch.PointFormats {
+        formats[i] = uint16(f)
+    }
+    return strings.Join([]string{
+        strconv.Itoa(int(ch.Version)),
+        decimals(withoutGREASE(ch.CipherSuites)),
+        decimals(withoutGREASE(ch.Extensions)),
+        decimals(withoutGREASE(ch.Curves)),
+        decimals(formats),
+    }, ",")
+}
+
+// JA3SString returns the JA3S fingerprint before hashing:
+// version,cipher,extensions.
+func (sh *ServerHello) JA3SString() string {
+    return strings.Join([]string{
+        strconv.Itoa(int(sh.Version)),
+        strconv.Itoa(int(sh.CipherSuite)),
+        decimals(sh.Extensions),
+    }, ",")
+}
+
+// JA4 returns the JA4 fingerprint of a ClientHello seen over TCP, such as
+// t13d1516h2_8daaf6152771_e5627efa2ab1.
+func (ch *ClientHello) JA4() string {
+    ciphers := withoutGREASE(ch.CipherSuites)
+    exts := withoutGREASE(ch.Extensions)
+
+    sni := "i"
+    if slices.Contains(exts, extServerName) {
+        sni = "d"
+    }
+    a := fmt.Sprintf("t%s%s%02d%02d%s", ja4Version(ch.MaxVersion()), sni,
+        min(len(ciphers), 99), min(len(exts), 99), ja4ALPN(ch.ALPN))
+
+    // The sorted lists make the fingerprint stable when clients shuffle
+    // their extensions; SNI and ALPN are already covered by part a.
+    var sorted []uint16
+    for _, e := range exts {
+        if e != extServerName && e != extALPN {
+            sorted = append(sorted, e)
+        }
+    }
+    slices.Sort(sorted)
+    c := ""
+    if len(sorted) > 0 {
+        c = hexes(sorted)
+        if sigs := withoutGREASE(ch.SignatureAlgorithms); len(sigs) > 0 {
+            c += "_" + hexes(sigs)
+        }
+    }
+    return a + "_" + ja4Hash(hexes(slices.Sorted(slices.Values(ciphers)))) + "_" + ja4Hash(c)
+}
+
+func ja4Version(v uint16) string {
+    switch v {
+    case 0x0304:
+        return "13"
+    case 0x0303:
+        return "12"
+    case 0x0302:
+        return "11"
+    case 0x0301:
+        return "10"
+    case 0x0300:
+        return "s3"
+    }
+    return "00"
+}
+
+// ja4ALPN returns the first and last character of the first ALPN value,
+// or of its hex form if either is not alphanumeric.
+func ja4ALPN(alpn []string) string {
+    if len(alpn) == 0 || alpn[0] == "" {
+        return "00"
+    }
+    p := alpn[0]
+    first, last := p[0], p[len(p)-1]
+    if !isAlnum(first) || !isAlnum(last) {
+        h := hex.EncodeToString([]byte(p))
+        return h[:1] + h[len(h)-1:]
+    }
+    return string([]byte{first, last})
+}
+
+func isAlnum(c byte) bool {
+    return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// ja4Hash truncates the SHA-256 of s to 12 hex digits; an empty list
+// hashes to zeros.
+func ja4Hash(s string) string {
+    if s == "" {
+        return "000000000000"
+    }
+    return digest(sha256.New, s)[:12]
+}
+
+func digest(newHash func() hash.Hash, s string) string {
+    h := newHash()
+    h.Write([]byte(s))
+    return hex.EncodeToString(h.Sum(nil))
+}
+
+func decimals(vs []uint16) string {
+    parts := make([]string, len(vs))
+    for i, v := range vs {
+        parts[i] = strconv.Itoa(int(v))
+    }
+    return strings.Join(parts, "-")
+}
+
+func hexes(vs []uint16) string {
+    parts := make([]string, len(vs))
+    for i, v := range vs {
+        parts[i] = fmt.Sprintf("%04x", v)
+    }
+    return strings.Join(parts, ",")
+}
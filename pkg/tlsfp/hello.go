@@ -0,0 +1,304 @@
+// Package tlsfp parses TLS ClientHello and ServerHello messages and
+// computes the JA3, JA3S and JA4 fingerprints of a handshake.
+package tlsfp
+
+import (
+    "errors"
+    "fmt"
+)
+
+var (
+    // ErrIncomplete means the data ends before the hello message does; the
+    // parse may succeed once more of the stream has been seen.
+    ErrIncomplete = errors.New("tls: incomplete hello")
+    // ErrNotHello means the data is not a TLS handshake record starting
+    // with the expected hello message.
+    ErrNotHello   = errors.New("tls: not a hello message")
+)
+
+const (
+    recordHandshake  = 0x16
+    typeClientHello  = 1
+    typeServerHello  = 2
+
+    extServerName    = 0x0000
+    extGroups        = 0x000a
+    extPointFormats  = 0x000b
+    extSignatureAlgs = 0x000d
+    extALPN          = 0x0010
+    extVersions      = 0x002b
+)
+
+// ClientHello holds the fields of a ClientHello that fingerprints use.
+// Lists are in the order the client sent them, GREASE values included.
+type ClientHello struct {
+    // Version is the legacy version field of the handshake message.
+    Version             uint16
+    CipherSuites        []uint16
+    Extensions          []uint16
+    Curves              []uint16
+    PointFormats        []uint8
+    SignatureAlgorithms []uint16
+    // SupportedVersions is the supported_versions extension, which
+    // offers TLS 1.3.
+    SupportedVersions   []uint16
+    SNI                 string
+    ALPN                []string
+}
+
+// ServerHello holds the fields of a ServerHello that fingerprints use.
+type ServerHello struct {
+    Version          uint16
+    CipherSuite      uint16
+    Extensions       []uint16
+    // SupportedVersion is the version selected through the
+    // supported_versions extension, zero without one.
+    SupportedVersion uint16
+}
+
+// ParseClientHello parses the ClientHello at the start of a client's TCP
+// stream. Only as much of the stream as the message takes is needed, so
+// later records and truncated ones are fine.
+func ParseClientHello(stream []byte) (*ClientHello, error) {
+    body, err := handshake(stream, typeClientHello)
+    if err != nil {
+        return nil, err
+    }
+    r := reader(body)
+    ch := &ClientHello{}
+    var sessionID, suites, compression reader
+    if !r.u16(&ch.Version) || !r.skip(32) || !r.vec8(&sessionID) ||
+        !r.vec16(&suites) || !r.vec8(&compression) {
+        return nil, malformed("ClientHello")
+    }
+    for len(suites) > 0 {
+        var s uint16
+        if !suites.u16(&s) {
+            return nil, malformed("cipher suites")
+        }
+        ch.CipherSuites = append(ch.CipherSuites, s)
+    }
+    err = extensions(r, func(typ uint16, data reader) bool {
+        ch.Extensions = append(ch.Extensions, typ)
+        switch typ {
+        case extServerName:
+            return ch.parseSNI(data)
+        case extGroups:
+            return data.list16(&ch.Curves)
+        case extPointFormats:
+            var fmts reader
+            if !data.vec8(&fmts) {
+                return false
+            }
+            ch.PointFormats = append(ch.PointFormats, fmts...)
+        case extSignatureAlgs:
+            return data.list16(&ch.SignatureAlgorithms)
+        case extALPN:
+            var protos reader
+            if !data.vec16(&protos) {
+                return false
+            }
+            for len(protos) > 0 {
+                var p reader
+                if !protos.vec8(&p) {
+                    return false
+                }
+                ch.ALPN = append(ch.ALPN, string(p))
+            }
+        case extVersions:
+            var vs reader
+            if !data.vec8(&vs) {
+                return false
+            }
+            for len(vs) > 0 {
+                var v uint16
+                if !vs.u16(&v) {
+                    return false
+                }
+                ch.SupportedVersions = append(ch.SupportedVersions, v)
+            }
+        }
+        return true
+    })
+    if err != nil {
+        return nil, err
+    }
+    return ch, nil
+}
+
+func (ch *ClientHello) parseSNI(data reader) bool {
+    var names reader
+    if !data.vec16(&names) {
+        return false
+    }
+    for len(names) > 0 {
+        var typ uint8
+        var name reader
+        if !names.u8(&typ) || !names.vec16(&name) {
+            return false
+        }
+        if typ == 0 && ch.SNI == "" {
+            ch.SNI = string(name)
+        }
+    }
+    return true
+}
+
+// ParseServerHello parses the ServerHello at the start of a server's TCP
+// stream, which usually shares its record with the certificate.
+func ParseServerHello(stream []byte) (*ServerHello, error) {
+    body, err := handshake(stream, typeServerHello)
+    if err != nil {
+        return nil, err
+    }
+    r := reader(body)
+    sh := &ServerHello{}
+    var sessionID reader
+    if !r.u16(&sh.Version) || !r.skip(32) || !r.vec8(&sessionID) ||
+        !r.u16(&sh.CipherSuite) || !r.skip(1) {
+        return nil, malformed("ServerHello")
+    }
+    err = extensions(r, func(typ uint16, data reader) bool {
+        sh.Extensions = append(sh.Extensions, typ)
+        if typ == extVersions {
+            return data.u16(&sh.SupportedVersion)
+        }
+        return true
+    })
+    if err != nil {
+        return nil, err
+    }
+    return sh, nil
+}
+
+// handshake returns the body of the first handshake message in stream,
+// which must be of type want. The message may span several records; the
+// last of them only needs to be present as far as the message goes.
+func handshake(stream []byte, want uint8) ([]byte, error) {
+    var msg []byte
+    for {
+        if len(stream) == 0 {
+            return nil, ErrIncomplete
+        }
+        if stream[0] != recordHandshake {
+            return nil, ErrNotHello
+        }
+        if len(stream) < 5 {
+            return nil, ErrIncomplete
+        }
+        n := int(stream[3])<<8 | int(stream[4])
+        frag := stream[5:]
+        if len(frag) > n {
+            frag = frag[:n]
+        }
+        msg = append(msg, frag...)
+        stream = stream[5+len(frag):]
+
+        if len(msg) > 0 && msg[0] != want {
+            return nil, ErrNotHello
+        }
+        if len(msg) >= 4 {
+            size := int(msg[1])<<16 | int(msg[2])<<8 | int(msg[3])
+            if len(msg) >= 4+size {
+                return msg[4 : 4+size], nil
+            }
+        }
+        if len(frag) < n {
+            // The record itself is cut short.
+            return nil, ErrIncomplete
+        }
+    }
+}
+
+// extensions calls fn for every extension in the rest of a hello message,
+// which may have none at all.
+func extensions(r reader, fn func(typ uint16, data reader) bool) error {
+    if len(r) == 0 {
+        return nil
+    }
+    var exts reader
+    if !r.vec16(&exts) {
+        return malformed("extensions")
+    }
+    for len(exts) > 0 {
+        var typ uint16
+        var data reader
+        if !exts.u16(&typ) || !exts.vec16(&data) {
+            return malformed("extensions")
+        }
+        if !fn(typ, data) {
+            return malformed(fmt.Sprintf("extension %d", typ))
+        }
+    }
+    return nil
+}
+
+func malformed(what string) error {
+    return fmt.Errorf("tls: malformed %s", what)
+}
+
+// reader consumes big-endian fields from a message. Every method reports
+// whether there was enough data.
+type reader []byte
+
+func (r *reader) skip(n int) bool {
+    if len(*r) < n {
+        return false
+    }
+    *r = (*r)[n:]
+    return true
+}
+
+func (r *reader) u8(v *uint8) bool {
+    if len(*r) < 1 {
+        return false
+    }
+    *v = (*r)[0]
+    *r = (*r)[1:]
+    return true
+}
+
+func (r *reader) u16(v *uint16) bool {
+    if len(*r) < 2 {
+        return false
+    }
+    *v = uint16((*r)[0])<<8 | uint16((*r)[1])
+    *r = (*r)[2:]
+    return true
+}
+
+// vec8 and vec16 read a vector with a one or two byte length prefix.
+func (r *reader) vec8(v *reader) bool {
+    var n uint8
+    return r.u8(&n) && r.vec(int(n), v)
+}
+
+func (r *reader) vec16(v *reader) bool {
+    var n uint16
+    return r.u16(&n) && r.vec(int(n), v)
+}
+
+func (r *reader) vec(n int, v *reader) bool {
+    if len(*r) < n {
+        return false
+    }
+    *v = (*r)[:n]
+    *r = (*r)[n:]
+    return true
+}
+
+// list16 reads a vector of 16-bit values.
+func (r *reader) list16(vs *[]uint16) bool {
+    var l reader
+    if !r.vec16(&l) {
+        return false
+    }
+    for len(l) > 0 {
+        var v uint16
+        if !l.u16(&v) {
+            return false
+        }
+        *vs = append(*vs, v)
+    }
+    return true
+}
@@ -0,0 +1,235 @@
+package tlsfp
+
+import (
+    "errors"
+    "reflect"
+    "testing"
+)
+
+// ext is one extension of a hello under construction.
+type ext struct {
+    typ  uint16
+    data []byte
+}
+
+func u16(v uint16) []byte { return []byte{byte(v >> 8), byte(v)} }
+
+func vec8(b []byte) []byte  { return append([]byte{byte(len(b))}, b...) }
+func vec16(b []byte) []byte { return append(u16(uint16(len(b))), b...) }
+
+func list16(vs ...uint16) []byte {
+    var b []byte
+    for _, v := range vs {
+        b = append(b, u16(v)...)
+    }
+    return b
+}
+
+func sniExt(name string) ext {
+    return ext{extServerName, vec16(append([]byte{0}, vec16([]byte(name))...))}
+}
+
+func alpnExt(protos ...string) ext {
+    var b []byte
+    for _, p := range protos {
+        b = append(b, vec8([]byte(p))...)
+    }
+    return ext{extALPN, vec16(b)}
+}
+
+func extBlock(exts []ext) []byte {
+    var b []byte
+    for _, e := range exts {
+        b = append(b, u16(e.typ)...)
+        b = append(b, vec16(e.data)...)
+    }
+    return vec16(b)
+}
+
+func clientHelloBody(version uint16, suites []uint16, exts []ext) []byte {
+    b := u16(version)
+    b = append(b, make([]byte, 32)...)           // random
+    b = append(b, vec8([]byte{1, 2, 3, 4})...) // session id
+    b = append(b, vec16(list16(suites...))...)
+    b = append(b, vec8([]byte{0})...) // null compression
+    if exts != nil {
+        b = append(b, extBlock(exts)...)
+    }
+    return b
+}
+
+// record wraps a handshake message of type typ into one TLS record.
+func record(typ byte, body []byte) []byte {
+    msg := append([]byte{typ, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}, body...)
+    return append([]byte{recordHandshake, 3, 1, byte(len(msg) >> 8), byte(len(msg))}, msg...)
+}
+
+// chromeHello is the ClientHello of the JA4 documentation example, with
+// GREASE values mixed in as Chrome sends them.
+func chromeHello() []byte {
+    return record(typeClientHello, clientHelloBody(0x0303,
+        []uint16{0x2a2a, 0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xc02c, 0xc030, 0xcca9, 0xcca8,
+            0xc013, 0xc014, 0x009c, 0x009d, 0x002f, 0x0035},
+        []ext{
+            {0x3a3a, nil},
+            sniExt("www.example.com"),
+            {0x0017, nil},
+            {0xff01, []byte{0}},
+            {extGroups, vec16(list16(0x4a4a, 0x001d, 0x0017, 0x0018))},
+            {extPointFormats, vec8([]byte{0})},
+            {0x0023, nil},
+            alpnExt("h2", "http/1.1"),
+            {0x0005, []byte{1, 0, 0, 0, 0}},
+            {extSignatureAlgs, vec16(list16(0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601))},
+            {0x0012, nil},
+            {0x0033, vec16(nil)},
+            {0x002d, vec8([]byte{1})},
+            {extVersions, vec8(list16(0x5a5a, 0x0304, 0x0303))},
+            {0x001b, []byte{2, 0, 2}},
+            {0x4469, vec16(vec8([]byte("h2")))},
+            {0x0015, make([]byte, 8)},
+        }))
+}
+
+func TestParseClientHello(t *testing.T) {
+    ch, err := ParseClientHello(chromeHello())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if ch.Version != 0x0303 || ch.SNI != "www.example.com" || ch.MaxVersion() != 0x0304 {
+        t.Errorf("version %#x, sni %q, max version %#x", ch.Version, ch.SNI, ch.MaxVersion())
+    }
+    if !reflect.DeepEqual(ch.ALPN, []string{"h2", "http/1.1"}) {
+        t.Errorf("alpn = %q", ch.ALPN)
+    }
+    if len(ch.CipherSuites) != 16 || ch.CipherSuites[0] != 0x2a2a || len(ch.Extensions) != 17 {
+        t.Errorf("%d suites, %d extensions; GREASE must be kept", len(ch.CipherSuites), len(ch.Extensions))
+    }
+    if !reflect.DeepEqual(ch.Curves, []uint16{0x4a4a, 0x001d, 0x0017, 0x0018}) || !reflect.DeepEqual(ch.PointFormats, []uint8{0}) {
+        t.Errorf("curves %x, point formats %x", ch.Curves, ch.PointFormats)
+    }
+    if want := "t13d1516h2_8daaf6152771_e5627efa2ab1"; ch.JA4() != want {
+        t.Errorf("JA4 = %s, want %s", ch.JA4(), want)
+    }
+}
+
+func TestJA3(t *testing.T) {
+    // The example of the JA3 documentation.
+    ch := &ClientHello{
+        Version:      769,
+        CipherSuites: []uint16{47, 53, 5, 10, 49161, 49162, 49171, 49172, 50, 56, 19, 4},
+        Extensions:   []uint16{0, 10, 11},
+        Curves:       []uint16{23, 24, 25},
+        PointFormats: []uint8{0},
+    }
+    if want := "769,47-53-5-10-49161-49162-49171-49172-50-56-19-4,0-10-11,23-24-25,0"; ch.JA3String() != want {
+        t.Errorf("JA3 string = %s, want %s", ch.JA3String(), want)
+    }
+    fp := NewFingerprint(ch, nil)
+    if fp.JA3 != "ada70206e40642a3e4461f35503241d5" || fp.JA3S != "" || fp.Version != 769 {
+        t.Errorf("fingerprint = %+v", fp)
+    }
+    // GREASE values do not change it.
+    ch.CipherSuites = append([]uint16{0x0a0a}, ch.CipherSuites...)
+    ch.Extensions = append(ch.Extensions, 0xfafa)
+    if NewFingerprint(ch, nil).JA3 != fp.JA3 {
+        t.Error("GREASE values changed the JA3 hash")
+    }
+}
+
+func TestJA4Parts(t *testing.T) {
+    tests := []struct {
+        name string
+        ch   ClientHello
+        want string
+    }{
+        {"no sni, no alpn, no extensions", ClientHello{Version: 0x0301, CipherSuites: []uint16{0x002f}},
+            "t10i010000_" + ja4Hash("002f") + "_000000000000"},
+        {"alpn hex form", ClientHello{Version: 0x0303, Extensions: []uint16{extALPN}, ALPN: []string{"\x01x"}},
+            "t12i000108_000000000000_000000000000"},
+        {"unknown version", ClientHello{Version: 0x7f17, Extensions: []uint16{extServerName, 0x000a}},
+            "t00d0002" + "00_000000000000_" + ja4Hash("000a")},
+    }
+    for _, tt := range tests {
+        if got := tt.ch.JA4(); got != tt.want {
+            t.Errorf("%s: JA4 = %s, want %s", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestParseServerHello(t *testing.T) {
+    body := u16(0x0303)
+    body = append(body, make([]byte, 32)...)
+    body = append(body, vec8(nil)...)
+    body = append(body, u16(0x1301)...)
+    body = append(body, 0)
+    body = append(body, extBlock([]ext{{extVersions, u16(0x0304)}, {0x0033, make([]byte, 36)}})...)
+    // The certificate follows in the same record.
+    stream := append(record(typeServerHello, body), 0x16, 3, 3, 0, 10)
+
+    sh, err := ParseServerHello(stream)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if sh.SelectedVersion() != 0x0304 || sh.CipherSuite != 0x1301 || sh.JA3SString() != "771,4865,43-51" {
+        t.Errorf("server hello = %+v, JA3S string %s", sh, sh.JA3SString())
+    }
+    fp := NewFingerprint(nil, sh)
+    if fp.Version != 0x0304 || fp.JA3S == "" || fp.JA3 != "" || VersionName(fp.Version) != "TLS 1.3" {
+        t.Errorf("fingerprint = %+v", fp)
+    }
+}
+
+func TestParseErrors(t *testing.T) {
+    full := chromeHello()
+
+    // Every cut short prefix of the hello is incomplete, not malformed.
+    for n := 0; n < len(full); n++ {
+        if _, err := ParseClientHello(full[:n]); !errors.Is(err, ErrIncomplete) {
+            t.Fatalf("%d of %d bytes: %v, want ErrIncomplete", n, len(full), err)
+        }
+    }
+
+    // The message may span records, split anywhere.
+    msg := full[5:]
+    for _, at := range []int{1, 4, 40, len(msg) - 1} {
+        split := append([]byte{recordHandshake, 3, 1, byte(at >> 8), byte(at)}, msg[:at]...)
+        rest := len(msg) - at
+        split = append(split, recordHandshake, 3, 1, byte(rest>>8), byte(rest))
+        split = append(split, msg[at:]...)
+        if ch, err := ParseClientHello(split); err != nil || ch.SNI != "www.example.com" {
+            t.Errorf("split at %d: %v", at, err)
+        }
+    }
+
+    tests := []struct {
+        name   string
+        stream []byte
+        err    error
+    }{
+        {"http", []byte("GET / HTTP/1.1\r\n"), ErrNotHello},
+        {"server hello", record(typeServerHello, make([]byte, 40)), ErrNotHello},
+        {"alert record", []byte{0x15, 3, 3, 0, 2, 2, 40}, ErrNotHello},
+    }
+    for _, tt := range tests {
+        if _, err := ParseClientHello(tt.stream); !errors.Is(err, tt.err) {
+            t.Errorf("%s: %v, want %v", tt.name, err, tt.err)
+        }
+    }
+
+    malformed := [][]byte{
+        record(typeClientHello, []byte{3, 3, 0}),
+        record(typeClientHello, clientHelloBody(0x0303, []uint16{1}, []ext{{extServerName, []byte{0, 9, 0}}})),
+        record(typeClientHello, append(clientHelloBody(0x0303, []uint16{1}, nil), 0, 9, 0, 0)),
+    }
+    for i, stream := range malformed {
+        if _, err := ParseClientHello(stream); err == nil || errors.Is(err, ErrIncomplete) || errors.Is(err, ErrNotHello) {
+            t.Errorf("malformed hello %d: %v", i, err)
+        }
+    }
+
+    // A hello without extensions is fine.
+    if ch, err := ParseClientHello(record(typeClientHello, clientHelloBody(0x0301, []uint16{0x002f}, nil))); err != nil || len(ch.Extensions) != 0 {
+        t.Errorf("hello without extensions: %+v, %v", ch, err)
+    }
+}
@@ -0,0 +1,181 @@
+package verdict
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// Severity grades how urgent a detection is.
+type Severity int
+
+const (
+    None Severity = iota
+    Low
+    Medium
+    High
+    Critical
+)
+
+var severityNames = []string{"none", "low", "medium", "high", "critical"}
+
+func (s Severity) String() string {
+    if s < None || s > Critical {
+        return "unknown"
+    }
+    return severityNames[s]
+}
+
+// ParseSeverity parses a severity name such as "high".
+func ParseSeverity(name string) (Severity, error) {
+    name = strings.ToLower(strings.TrimSpace(name))
+    for i, n := range severityNames {
+        if n == name {
+            return Severity(i), nil
+        }
+    }
+    return None, fmt.Errorf("unknown severity %q, want one of %s", name, strings.Join(severityNames, ", "))
+}
+
+// Labels given to scored flows.
+const (
+    LabelBenign    = "benign"
+    LabelMalicious = "malicious"
+)
+
+// Band assigns Severity to probabilities at or above Min.
+type Band struct {
+    Severity Severity
+    Min      float64
+}
+
+// Bands maps a probability to a severity. It is kept sorted by Min.
+type Bands []Band
+
+// ParseBands parses a band list such as
+// "low=0.5,medium=0.7,high=0.85,critical=0.95". Higher severities must
+// start at higher probabilities.
+func ParseBands(spec string) (Bands, error) {
+    m := make(map[string]float64)
+    for _, part := range strings.Split(spec, ",") {
+        part = strings.TrimSpace(part)
+        if part == "" {
+            continue
+        }
+        name, val, ok := strings.Cut(part, "=")
+        if !ok {
+            return nil, fmt.Errorf("bad severity band %q, want name=probability", part)
+        }
+        p, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+        if err != nil {
+            return nil, fmt.Errorf("bad severity band %q: %w", part, err)
+        }
+        m[name] = p
+    }
+    return NewBands(m)
+}
+
+// NewBands builds Bands from severity names to minimum probabilities.
+func NewBands(m map[string]float64) (Bands, error) {
+    var b Bands
+    for name, p := range m {
+        sev, err := ParseSeverity(name)
+        if err != nil {
+            return nil, err
+        }
+        if sev == None {
+            return nil, fmt.Errorf("severity band cannot be %q", name)
+        }
+        if p < 0 || p > 1 {
+            return nil, fmt.Errorf("severity band %s=%v outside [0, 1]", name, p)
+        }
+        b = append(b, Band{Severity: sev, Min: p})
+    }
+    sort.Slice(b, func(i, j int) bool { return b[i].Severity < b[j].Severity })
+    for i := 1; i < len(b); i++ {
+        if b[i].Min <= b[i-1].Min {
+            return nil, fmt.Errorf("severity band %s must start above %s (%v <= %v)",
+                b[i].Severity, b[i-1].Severity, b[i].Min, b[i-1].Min)
+        }
+    }
+    return b, nil
+}
+
+func (b Bands) String() string {
+    parts := make([]string, len(b))
+    for i, band := range b {
+        parts[i] = fmt.Sprintf("%s=%g", band.Severity, band.Min)
+    }
+    return strings.Join(parts, ",")
+}
+
+// Policy turns a model probability into a label and a severity. Flows
+// above Threshold are labeled malicious. Severity comes from Bands when
+// they are set, which may also grade flows below the threshold; a
+// malicious flow below every band is Low. Without bands every malicious
+// flow is High.
+type Policy struct {
+    Threshold float64
+    Bands     Bands
+    // AlertLabel is the severity from which an alert labels a flow
+    // malicious; weaker alerts only raise its severity. None lets every
+    // alert label the flow.
+    AlertLabel Severity
+}
+
+func (p Policy) Label(prob float64) string {
+    if prob > p.Threshold {
+        return LabelMalicious
+    }
+    return LabelBenign
+}
+
+func (p Policy) Severity(prob float64) Severity {
+    if len(p.Bands) == 0 {
+        if prob > p.Threshold {
+            return High
+        }
+        return None
+    }
+    sev := None
+    for _, band := range p.Bands {
+        if prob >= band.Min {
+            sev = band.Severity
+        }
+    }
+    if prob > p.Threshold {
+        sev = max(sev, Low)
+    }
+    return sev
+}
+
+// Alert is a detection made outside the model, such as a blocklisted TLS
+// fingerprint.
+type Alert struct {
+    // Source names the detector, e.g. "tls-blocklist".
+    Source   string
+    Detail   string
+    Severity Severity
+}
+
+func (a Alert) String() string {
+    return a.Source + ": " + a.Detail
+}
+
+// Verdict labels and grades a flow from its probability and alerts. The
+// flow is at no less than the severity of any alert, and malicious if one
+// of them reaches AlertLabel. A malicious flow is always at least Low.
+func (p Policy) Verdict(prob float64, alerts []Alert) (string, Severity) {
+    label, sev := p.Label(prob), p.Severity(prob)
+    for _, a := range alerts {
+        if a.Severity >= p.AlertLabel {
+            label = LabelMalicious
+        }
+        sev = max(sev, a.Severity)
+    }
+    if label == LabelMalicious {
+        sev = max(sev, Low)
+    }
+    return label, sev
+}
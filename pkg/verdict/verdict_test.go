@@ -0,0 +1,79 @@
+package verdict
+
+import "testing"
+
+func TestPolicyVerdict(t *testing.T) {
+    bands, err := ParseBands("low=0.3,medium=0.7,high=0.9")
+    if err != nil {
+        t.Fatal(err)
+    }
+    medium := Alert{Source: "dga", Severity: Medium}
+    high := Alert{Source: "ioc", Severity: High}
+
+    tests := []struct {
+        name      string
+        policy    Policy
+        prob      float64
+        alerts    []Alert
+        wantLabel string
+        wantSev   Severity
+    }{
+        {"benign", Policy{Threshold: 0.5}, 0.2, nil, LabelBenign, None},
+        {"malicious without bands is high", Policy{Threshold: 0.5}, 0.6, nil, LabelMalicious, High},
+        {"band below threshold", Policy{Threshold: 0.5, Bands: bands}, 0.4, nil, LabelBenign, Low},
+        {"band above threshold", Policy{Threshold: 0.5, Bands: bands}, 0.95, nil, LabelMalicious, High},
+        {"malicious below every band", Policy{Threshold: 0.1, Bands: bands}, 0.2, nil, LabelMalicious, Low},
+        {"zero threshold below every band", Policy{Bands: bands}, 0.01, nil, LabelMalicious, Low},
+        {"weak alert raises severity only", Policy{Threshold: 0.5, AlertLabel: High}, 0.1, []Alert{medium}, LabelBenign, Medium},
+        {"strong alert labels", Policy{Threshold: 0.5, AlertLabel: High}, 0.1, []Alert{medium, high}, LabelMalicious, High},
+        {"every alert labels at none", Policy{Threshold: 0.5}, 0.1, []Alert{medium}, LabelMalicious, Medium},
+        {"alert without severity labels at none", Policy{Threshold: 0.5, Bands: bands}, 0.1, []Alert{{Source: "rule"}}, LabelMalicious, Low},
+        {"alert never lowers severity", Policy{Threshold: 0.5, Bands: bands, AlertLabel: High}, 0.95, []Alert{medium}, LabelMalicious, High},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            label, sev := tt.policy.Verdict(tt.prob, tt.alerts)
+            if label != tt.wantLabel || sev != tt.wantSev {
+                t.Errorf("Verdict(%v) = %s, %s; want %s, %s", tt.prob, label, sev, tt.wantLabel, tt.wantSev)
+            }
+        })
+    }
+}
+
+func TestParseBands(t *testing.T) {
+    tests := []struct {
+        spec string
+        want string
+        ok   bool
+    }{
+        {"low=0.5,medium=0.7,high=0.85,critical=0.95", "low=0.5,medium=0.7,high=0.85,critical=0.95", true},
+        {" high=0.9 , low=0.2 ", "low=0.2,high=0.9", true},
+        {"low=0.7,medium=0.5", "", false},
+        {"none=0.1", "", false},
+        {"low=1.5", "", false},
+        {"low", "", false},
+        {"urgent=0.5", "", false},
+    }
+    for _, tt := range tests {
+        b, err := ParseBands(tt.spec)
+        if (err == nil) != tt.ok {
+            t.Errorf("ParseBands(%q) error = %v, want ok %v", tt.spec, err, tt.ok)
+            continue
+        }
+        if tt.ok && b.String() != tt.want {
+            t.Errorf("ParseBands(%q) = %s, want %s", tt.spec, b, tt.want)
+        }
+    }
+}
+
+func TestParseSeverity(t *testing.T) {
+    for _, s := range []Severity{None, Low, Medium, High, Critical} {
+        got, err := ParseSeverity(" " + s.String() + " ")
+        if err != nil || got != s {
+            t.Errorf("ParseSeverity(%q) = %v, %v", s, got, err)
+        }
+    }
+    if _, err := ParseSeverity("severe"); err == nil {
+        t.Error("ParseSeverity accepted \"severe\"")
+    }
+}
@@ -0,0 +1,163 @@
+#!/usr/bin/env python3
+"""
+bundle.py
+
+Packs a trained model into a single self-describing bundle file that
+PacketSentry's internal/ml.LoadBundle reads: feature names with units and
+scaler parameters, the decision threshold and optional severity bands,
+training metadata, the model itself and a SHA-256 checksum of all of it.
+
+Run directly to convert a legacy parameter directory (weights.txt,
+intercept.txt, mean.txt, std.txt, features.txt, optional metadata.json and
+trees.json) into a bundle:
+
+    python3 scripts/ml/bundle.py -p ml/parameters -o ml/model.json
+"""
+
+import argparse
+import datetime
+import hashlib
+import json
+import logging
+import os
+import subprocess
+
+FORMAT = "packetsentry-model"
+VERSION = 1
+
+
+# UNITS_COMMAND prints every feature column PacketSentry computes with its
+# unit, one per line.
+UNITS_COMMAND = ["go", "run", "./cmd", "features", "-list"]
+
+
+def load_units(path=None):
+    """Units of the feature columns as pkg/features defines them, read from
+    path, a saved copy of `packetsentry features -list`, or else from
+    running UNITS_COMMAND in the repository root."""
+    if path:
+        with open(path) as f:
+            text = f.read()
+    else:
+        text = subprocess.run(UNITS_COMMAND, check=True, capture_output=True,
+                              text=True).stdout
+    units = {}
+    for line in text.splitlines():
+        fields = line.split()
+        if len(fields) == 2:
+            units[fields[0]] = fields[1]
+    if not units:
+        raise ValueError("no feature units found")
+    return units
+
+
+def build_payload(model_type, feature_names, model, units, means=None, stds=None,
+                  threshold=0.5, severity_bands=None, training=None, **meta):
+    features = []
+    for i, name in enumerate(feature_names):
+        if name not in units:
+            raise ValueError("PacketSentry has no feature %r" % name)
+        spec = {"name": name, "unit": units[name]}
+        if means is not None:
+            spec["mean"] = float(means[i])
+            spec["std"] = float(stds[i])
+        features.append(spec)
+
+    payload = {
+        "model_type": model_type,
+        "features": features,
+        "threshold": float(threshold),
+        "training": training or {},
+        "model": model,
+    }
+    if severity_bands:
+        payload["severity_bands"] = severity_bands
+    payload.update(meta)
+    return payload
+
+
+def write_bundle(payload, path):
+    """Writes payload wrapped in the bundle envelope. The checksum covers
+    the payload bytes exactly as they appear in the file."""
+    body = json.dumps(payload, sort_keys=True, indent=2)
+    checksum = "sha256:" + hashlib.sha256(body.encode("utf-8")).hexdigest()
+    os.makedirs(os.path.dirname(path) or ".", exist_ok=True)
+    with open(path, "w") as f:
+        f.write('{\n"format": "%s",\n"version": %d,\n"checksum": "%s",\n"payload": %s\n}\n'
+                % (FORMAT, VERSION, checksum, body))
+    logging.info("Wrote model bundle %s (%s)", path, checksum)
+
+
+def now():
+    return datetime.datetime.now(datetime.timezone.utc).strftime("%Y-%m-%dT%H:%M:%SZ")
+
+
+def read_lines(path):
+    with open(path) as f:
+        return [l.strip() for l in f if l.strip()]
+
+
+def read_floats(path):
+    return [float(v) for v in read_lines(path)]
+
+
+def parse_bands(spec):
+    """Parses "low=0.5,medium=0.7,..." into a severity -> probability map."""
+    bands = {}
+    for part in (spec or "").split(","):
+        if part.strip():
+            name, value = part.split("=", 1)
+            bands[name.strip()] = float(value)
+    return bands
+
+
+def pack_directory(params_dir, units, threshold, severity_bands=None):
+    names = read_lines(os.path.join(params_dir, "features.txt"))
+    meta = {"model_type": "logistic_regression"}
+    meta_path = os.path.join(params_dir, "metadata.json")
+    if os.path.exists(meta_path):
+        with open(meta_path) as f:
+            meta = json.load(f)
+    model_type = meta.pop("model_type")
+    trees_file = meta.pop("trees_file", "trees.json")
+    training = {
+        "source": params_dir,
+        "notes": "converted from a parameter directory on " + now(),
+    }
+
+    if model_type == "logistic_regression":
+        model = {
+            "weights": read_floats(os.path.join(params_dir, "weights.txt")),
+            "intercept": read_floats(os.path.join(params_dir, "intercept.txt"))[0],
+        }
+        return build_payload(
+            model_type, names, model, units,
+            means=read_floats(os.path.join(params_dir, "mean.txt")),
+            stds=read_floats(os.path.join(params_dir, "std.txt")),
+            threshold=threshold, severity_bands=severity_bands,
+            training=training,
+        )
+
+    with open(os.path.join(params_dir, trees_file)) as f:
+        model = json.load(f)
+    return build_payload(model_type, names, model, units, threshold=threshold,
+                         severity_bands=severity_bands, training=training, **meta)
+
+
+def main():
+    p = argparse.ArgumentParser(description="Pack a parameter directory into a model bundle.")
+    p.add_argument("--params-dir", "-p", default="ml/parameters")
+    p.add_argument("--output", "-o", default="ml/model.json")
+    p.add_argument("--threshold", type=float, default=0.5)
+    p.add_argument("--units", default="",
+                   help="output of `packetsentry features -list`; run from the source tree if not given")
+    p.add_argument("--severity-bands", default="",
+                   help='e.g. "low=0.5,medium=0.7,high=0.85,critical=0.95"')
+    args = p.parse_args()
+    logging.basicConfig(level=logging.INFO, format="%(asctime)s %(levelname)s %(message)s")
+    write_bundle(pack_directory(args.params_dir, load_units(args.units), args.threshold,
+                                parse_bands(args.severity_bands)), args.output)
+
+
+if __name__ == "__main__":
+    main()
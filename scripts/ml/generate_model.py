@@ -5,9 +5,18 @@ generate_model.py
 Reads a labeled flows CSV, trains a logistic regression,
 and writes model parameters (weights, intercept, means, stds)
 to the ml/parameters directory.
+
+With --model-type random_forest, gradient_boosting or xgboost it trains a
+tree ensemble instead and writes trees.json in the format internal/ml
+loads. metadata.json records which kind of model the directory holds.
+
+Either way the model is also packed into a single bundle file (--bundle,
+ml/model.json by default) that carries the feature names, units, scaler,
+threshold and training metadata; see bundle.py.
 """
 
 import argparse
+import json
 import logging
 import os
 import sys
@@ -16,6 +25,8 @@ import numpy as np
 import pandas as pd
 from sklearn.linear_model import LogisticRegression
 
+from bundle import build_payload, load_units, now, parse_bands, write_bundle
+
 def parse_args():
     p = argparse.ArgumentParser(
         description="Train logistic-regression model on flow features and output parameters."
@@ -32,6 +43,31 @@ def parse_args():
         "--random-state", type=int, default=42,
         help="Random seed for reproducibility."
     )
+    p.add_argument(
+        "--model-type", default="logistic_regression",
+        choices=["logistic_regression", "random_forest", "gradient_boosting", "xgboost"],
+        help="Kind of model to train."
+    )
+    p.add_argument(
+        "--n-estimators", type=int, default=100,
+        help="Number of trees for ensemble models."
+    )
+    p.add_argument(
+        "--bundle", default="ml/model.json",
+        help="Path of the model bundle to write."
+    )
+    p.add_argument(
+        "--threshold", type=float, default=0.5,
+        help="Decision threshold stored in the bundle."
+    )
+    p.add_argument(
+        "--severity-bands", default="",
+        help='Severity bands stored in the bundle, e.g. "low=0.5,medium=0.7,high=0.85,critical=0.95".'
+    )
+    p.add_argument(
+        "--units", default="",
+        help="Output of `packetsentry features -list` giving the feature units; run from the source tree if not given."
+    )
     return p.parse_args()
 
 def setup_logging():
@@ -59,6 +95,11 @@ def main():
     feature_names = df.drop(columns=['label']).columns.tolist()
     logging.info("Loaded %d samples with %d features", X.shape[0], X.shape[1])
 
+    os.makedirs(args.output_dir, exist_ok=True)
+    if args.model_type != "logistic_regression":
+        train_ensemble(args, X, y, feature_names)
+        return
+
     # 2) Compute training-set means & stds
     means = X.mean(axis=0)
     stds = X.std(axis=0, ddof=0)
@@ -77,8 +118,6 @@ def main():
     intercept = clf.intercept_[0]
     logging.info("Trained logistic regression (intercept=%.4f)", intercept)
 
-    os.makedirs(args.output_dir, exist_ok=True)
-
     def write_array(arr, path):
         """
         Saves arr (a NumPy array or a Python list) to `path` with one float per line,
@@ -97,11 +136,120 @@ def main():
     write_array(stds,        os.path.join(args.output_dir, "std.txt"))
 
 
-    with open(os.path.join(args.output_dir, "features.txt"), "w") as f:
+    write_features(args.output_dir, feature_names)
+    write_json({"model_type": "logistic_regression"}, os.path.join(args.output_dir, "metadata.json"))
+
+    write_bundle(build_payload(
+        "logistic_regression", feature_names,
+        {"weights": weights.tolist(), "intercept": float(intercept)},
+        load_units(args.units), means=means, stds=stds, threshold=args.threshold,
+        severity_bands=parse_bands(args.severity_bands),
+        training=training_info(args, X),
+    ), args.bundle)
+
+    logging.info("Model parameter generation complete.")
+
+def training_info(args, X):
+    return {
+        "trained_at": now(),
+        "source": args.input_csv,
+        "samples": int(X.shape[0]),
+        "notes": "%s, random_state=%d" % (args.model_type, args.random_state),
+    }
+
+def write_json(obj, path):
+    with open(path, "w") as f:
+        json.dump(obj, f, indent=2)
+        f.write("\n")
+    logging.info("Wrote %s", path)
+
+def write_features(output_dir, feature_names):
+    with open(os.path.join(output_dir, "features.txt"), "w") as f:
         for name in feature_names:
             f.write(f"{name}\n")
     logging.info("Wrote feature list to features.txt")
 
+def sklearn_tree(tree, value):
+    """Flattens a fitted sklearn tree_ into the arrays internal/ml expects,
+    with one output value per node."""
+    return {
+        "children_left": tree.children_left.tolist(),
+        "children_right": tree.children_right.tolist(),
+        "feature": tree.feature.tolist(),
+        "threshold": tree.threshold.tolist(),
+        "value": [float(v) for v in value],
+    }
+
+def train_ensemble(args, X, y, feature_names):
+    """Trains a tree ensemble on unscaled features and writes trees.json
+    and metadata.json. Trees split on raw values, so no scaler is saved."""
+    meta = {"trees_file": "trees.json"}
+
+    if args.model_type == "random_forest":
+        from sklearn.ensemble import RandomForestClassifier
+        clf = RandomForestClassifier(
+            n_estimators=args.n_estimators,
+            random_state=args.random_state,
+            class_weight="balanced",
+        )
+        clf.fit(X, y)
+        trees = []
+        for est in clf.estimators_:
+            counts = est.tree_.value[:, 0, :]
+            # Leaf probability of the malicious class (column 1).
+            trees.append(sklearn_tree(est.tree_, counts[:, 1] / counts.sum(axis=1)))
+        dump = {"trees": trees}
+        meta.update(model_type="random_forest", format="sklearn")
+
+    elif args.model_type == "gradient_boosting":
+        from sklearn.ensemble import GradientBoostingClassifier
+        clf = GradientBoostingClassifier(
+            n_estimators=args.n_estimators,
+            random_state=args.random_state,
+        )
+        clf.fit(X, y)
+        trees = [sklearn_tree(est[0].tree_, est[0].tree_.value[:, 0, 0]) for est in clf.estimators_]
+        dump = {"trees": trees}
+        # Recover the init estimator's log-odds from one decision value.
+        tree_sum = sum(est[0].predict(X[:1])[0] for est in clf.estimators_)
+        base_margin = clf.decision_function(X[:1])[0] - clf.learning_rate * tree_sum
+        meta.update(
+            model_type="gradient_boosting",
+            format="sklearn",
+            base_margin=float(base_margin),
+            learning_rate=float(clf.learning_rate),
+        )
+
+    else:
+        import xgboost as xgb
+        clf = xgb.XGBClassifier(
+            n_estimators=args.n_estimators,
+            random_state=args.random_state,
+            objective="binary:logistic",
+        )
+        clf.fit(X, y)
+        booster = clf.get_booster()
+        dump = [json.loads(t) for t in booster.get_dump(dump_format="json")]
+        config = json.loads(booster.save_config())
+        base_score = float(config["learner"]["learner_model_param"]["base_score"])
+        meta.update(
+            model_type="gradient_boosting",
+            format="xgboost",
+            base_margin=float(np.log(base_score / (1 - base_score))),
+        )
+
+    logging.info("Trained %s with %d trees", args.model_type, args.n_estimators)
+    write_json(dump, os.path.join(args.output_dir, "trees.json"))
+    write_json(meta, os.path.join(args.output_dir, "metadata.json"))
+    write_features(args.output_dir, feature_names)
+
+    bundle_meta = {k: v for k, v in meta.items() if k not in ("model_type", "trees_file")}
+    write_bundle(build_payload(
+        meta["model_type"], feature_names, dump, load_units(args.units),
+        threshold=args.threshold,
+        severity_bands=parse_bands(args.severity_bands),
+        training=training_info(args, X), **bundle_meta,
+    ), args.bundle)
     logging.info("Model parameter generation complete.")
 
 if __name__ == "__main__":